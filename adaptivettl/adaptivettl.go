@@ -0,0 +1,212 @@
+// Package adaptivettl tunes the lifetime of every cache entry from its observed hits, granting
+// frequently read keys longer TTLs, up to a cap, and letting rarely read ones expire sooner, to
+// maximise the hit ratio within a memory budget.
+package adaptivettl
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+const defaultHalfSaturation = 10
+
+// Pass summarises a tuning pass
+type Pass struct {
+	Scanned   int     // entries inspected
+	Extended  int     // hot entries granted a longer lifetime
+	Shortened int     // cold entries cut down to the minimum lifetime
+	Scale     float64 // factor applied to the extra lifetime of hot entries to honour the budget
+}
+
+// entry is an inspected cache entry awaiting its new lifetime
+type entry struct {
+	info  cache.EntryInfo
+	delta uint64 // hits since the previous pass
+}
+
+// Tuner periodically adjusts the TTLs of a cache from the access metadata reported by Inspect
+type Tuner struct {
+	c        cache.Cache
+	min, max time.Duration
+	half     uint64
+	budget   int64
+	logger   cache.Logger
+
+	tuning  sync.Mutex        // serialises passes
+	prev    map[string]uint64 // hit counts seen by the previous pass
+	mutex   sync.Mutex
+	running bool
+}
+
+type Option func(*Tuner)
+
+// New -
+// Initialises a new Tuner on top of c, granting lifetimes between min and max. c must implement
+// cache.Scanner, cache.Inspector and cache.Toucher and track accesses, e.g. a MemCache with
+// TrackKeyHits or a RedisCache with TrackAccess
+func New(c cache.Cache, min, max time.Duration, opts ...Option) *Tuner {
+	t := &Tuner{
+		c:      c,
+		min:    min,
+		max:    max,
+		half:   defaultHalfSaturation,
+		logger: cache.NopLogger{},
+		prev:   map[string]uint64{},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// HalfSaturation -
+// Functional option to specify how many hits between two passes earn an entry half of the extra
+// lifetime between min and max, defaults to 10. The lifetime grows as hits/(hits+n) of that range
+func HalfSaturation(n uint64) Option {
+	return func(t *Tuner) {
+		t.half = n
+	}
+}
+
+// Budget -
+// Functional option to specify the bytes the cache values may take up, when the inspected entries
+// exceed it the extra lifetime granted to hot entries shrinks in proportion, unlimited when zero
+func Budget(bytes int64) Option {
+	return func(t *Tuner) {
+		t.budget = bytes
+	}
+}
+
+// Logger -
+// Functional option to log tuning passes and their failures, defaults to a no-op logger
+func Logger(l cache.Logger) Option {
+	return func(t *Tuner) {
+		t.logger = l
+	}
+}
+
+// Tune -
+// Runs a single pass: inspects every entry, then extends the lifetime of entries read since the
+// previous pass according to their hits and cuts entries read by no one down to min. Entries
+// without an expiry are left as is, and nothing is shortened while no entry reports any hit, as
+// that means accesses are not tracked. Concurrent passes run one after the other
+func (t *Tuner) Tune(ctx context.Context) (Pass, error) {
+	sc, ok := t.c.(cache.Scanner)
+	if !ok {
+		return Pass{}, cache.ErrNotSupported
+	}
+	in, ok := t.c.(cache.Inspector)
+	if !ok {
+		return Pass{}, cache.ErrNotSupported
+	}
+	touch, ok := t.c.(cache.Toucher)
+	if !ok {
+		return Pass{}, cache.ErrNotSupported
+	}
+
+	t.tuning.Lock()
+	defer t.tuning.Unlock()
+	var (
+		entries []entry
+		total   int64
+		tracked bool
+	)
+	seen := map[string]uint64{}
+	err := sc.Scan(ctx, func(key string) error {
+		info, err := in.Inspect(key)
+		if err != nil {
+			return nil // gone since it was scanned
+		}
+		seen[key] = info.Hits
+		total += int64(info.Size)
+		tracked = tracked || info.Hits > 0
+		if info.TTL <= 0 {
+			return nil // never expires or already stale
+		}
+		delta := info.Hits
+		if prev, ok := t.prev[key]; ok && prev <= info.Hits {
+			delta = info.Hits - prev
+		}
+		entries = append(entries, entry{info: info, delta: delta})
+		return nil
+	})
+	if err != nil {
+		return Pass{}, err
+	}
+	t.prev = seen
+
+	pass := Pass{Scanned: len(seen), Scale: 1}
+	if t.budget > 0 && total > t.budget {
+		pass.Scale = float64(t.budget) / float64(total)
+	}
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return pass, err
+		}
+		ttl := t.lifetime(e.delta, pass.Scale)
+		switch {
+		case e.delta > 0 && ttl > e.info.TTL:
+			if err := touch.Touch(e.info.Key, ttl); err != nil {
+				continue
+			}
+			pass.Extended++
+		case e.delta == 0 && tracked && e.info.TTL > t.min:
+			if err := touch.Touch(e.info.Key, t.min); err != nil {
+				continue
+			}
+			pass.Shortened++
+		}
+	}
+	t.logger.Debug("adaptive ttl pass", "scanned", pass.Scanned, "extended", pass.Extended, "shortened", pass.Shortened, "scale", pass.Scale)
+	return pass, nil
+}
+
+// lifetime -
+// Returns the lifetime earned by hits since the previous pass, the extra lifetime above min scaled
+func (t *Tuner) lifetime(hits uint64, scale float64) time.Duration {
+	if hits == 0 {
+		return t.min
+	}
+	share := float64(hits) / float64(hits+t.half)
+	return t.min + time.Duration(float64(t.max-t.min)*share*scale)
+}
+
+// Run -
+// Tunes the cache every interval until ctx is done, logging failed passes. A non positive
+// interval, or a call made while the tuner already runs, is logged and nothing is tuned
+func (t *Tuner) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		t.logger.Error("adaptive ttl tuning not started, interval must be positive", "interval", interval)
+		return
+	}
+	t.mutex.Lock()
+	if t.running {
+		t.mutex.Unlock()
+		t.logger.Error("adaptive ttl tuning not started, the tuner is already running")
+		return
+	}
+	t.running = true
+	t.mutex.Unlock()
+	defer func() {
+		t.mutex.Lock()
+		t.running = false
+		t.mutex.Unlock()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := t.Tune(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				t.logger.Error("adaptive ttl pass failed", "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
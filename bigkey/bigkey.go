@@ -0,0 +1,210 @@
+// Package bigkey flags oversized cache values, on write and by periodically sampling existing
+// entries, before they blow up the memory of redis.
+package bigkey
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Sources of a BigKey
+const (
+	SourcePut  = "put"  // the value was flagged while being written
+	SourceScan = "scan" // the entry was flagged by a scan of the cache
+)
+
+// BigKey is a cache entry exceeding the size threshold of a Reporter
+type BigKey struct {
+	Key    string
+	Size   int64  // bytes of the value on write, or of the whole entry as measured by the cache on scans
+	Source string // SourcePut or SourceScan
+}
+
+// memoryUsager is implemented by caches measuring the memory held by an entry, e.g. RedisCache
+// through MEMORY USAGE
+type memoryUsager interface {
+	MemoryUsage(key string) (int64, error)
+}
+
+// Reporter wraps a cache, flagging the values exceeding a size threshold
+type Reporter struct {
+	cache.Wrapper
+	threshold  int64
+	onBigKey   func(BigKey)
+	sampleRate float64
+	logger     cache.Logger
+	found      *prometheus.CounterVec // big keys found per source, nil unless Metrics is used
+	largest    prometheus.Gauge       // size of the largest entry found by the last scan
+}
+
+type Option func(*Reporter)
+
+// New -
+// Wraps c, flagging every value of more than threshold bytes, use the returned Reporter in place
+// of c. Writes are never refused, see MaxValueSize of the adaptors to do so
+func New(c cache.Cache, threshold int64, opts ...Option) *Reporter {
+	r := &Reporter{
+		Wrapper:    cache.Wrapper{Cache: c},
+		threshold:  threshold,
+		sampleRate: 1,
+		logger:     cache.NopLogger{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// OnBigKey -
+// Functional option registering a callback invoked for every big key found, it must not block
+func OnBigKey(fn func(BigKey)) Option {
+	return func(r *Reporter) {
+		r.onBigKey = fn
+	}
+}
+
+// SampleRate -
+// Functional option to specify the fraction of keys measured by a scan (e.g. 0.01 for 1%), so
+// scanning a large redis costs a fraction of the MEMORY USAGE calls, defaults to every key
+func SampleRate(fraction float64) Option {
+	return func(r *Reporter) {
+		r.sampleRate = fraction
+	}
+}
+
+// Logger -
+// Functional option to log big keys and failed scans, defaults to a no-op logger
+func Logger(l cache.Logger) Option {
+	return func(r *Reporter) {
+		r.logger = l
+	}
+}
+
+// Metrics -
+// Functional option exporting the go_cache_big_keys_total counter, labelled by source, and the
+// go_cache_big_key_largest_bytes gauge holding the largest entry found by the last scan
+func Metrics(reg prometheus.Registerer) Option {
+	return func(r *Reporter) {
+		r.found = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "go_cache",
+			Name:      "big_keys_total",
+			Help:      "Number of cache values found exceeding the big key threshold.",
+		}, []string{"source"})
+		if err := reg.Register(r.found); err != nil {
+			if already, ok := err.(prometheus.AlreadyRegisteredError); ok {
+				r.found = already.ExistingCollector.(*prometheus.CounterVec)
+			}
+		}
+		r.largest = prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "go_cache",
+			Name:      "big_key_largest_bytes",
+			Help:      "Size of the largest cache entry found by the last big key scan.",
+		})
+		if err := reg.Register(r.largest); err != nil {
+			if already, ok := err.(prometheus.AlreadyRegisteredError); ok {
+				r.largest = already.ExistingCollector.(prometheus.Gauge)
+			}
+		}
+	}
+}
+
+// report -
+// Hands a big key to the callback, the logger and the metrics
+func (r *Reporter) report(b BigKey) {
+	r.logger.Info("big cache key", "key", b.Key, "size", b.Size, "source", b.Source)
+	if r.found != nil {
+		r.found.WithLabelValues(b.Source).Inc()
+	}
+	if r.onBigKey != nil {
+		r.onBigKey(b)
+	}
+}
+
+// Scan -
+// Measures a sample of the cache entries, through MEMORY USAGE on redis and the value size reported
+// by Inspect otherwise, and returns those exceeding the threshold after reporting them. Entries are
+// measured without being read, so scans neither count as hits nor extend sliding lifetimes. The
+// cache must implement cache.Scanner, and either MemoryUsage or cache.Inspector
+func (r *Reporter) Scan(ctx context.Context) ([]BigKey, error) {
+	sc, ok := r.Cache.(cache.Scanner)
+	if !ok {
+		return nil, cache.ErrNotSupported
+	}
+	usager, measured := r.Cache.(memoryUsager)
+	inspector, inspected := r.Cache.(cache.Inspector)
+	if !measured && !inspected {
+		return nil, cache.ErrNotSupported
+	}
+	var (
+		found   []BigKey
+		largest int64
+	)
+	err := sc.Scan(ctx, func(key string) error {
+		if r.sampleRate < 1 && rand.Float64() >= r.sampleRate {
+			return nil
+		}
+		var size int64
+		if measured {
+			n, err := usager.MemoryUsage(key)
+			if err != nil {
+				return nil // expired since it was scanned
+			}
+			size = n
+		} else {
+			info, err := inspector.Inspect(key)
+			if err != nil {
+				return nil
+			}
+			size = int64(info.Size)
+		}
+		if size > largest {
+			largest = size
+		}
+		if size > r.threshold {
+			b := BigKey{Key: key, Size: size, Source: SourceScan}
+			found = append(found, b)
+			r.report(b)
+		}
+		return nil
+	})
+	if r.largest != nil && err == nil {
+		r.largest.Set(float64(largest))
+	}
+	return found, err
+}
+
+// Run -
+// Scans the cache every interval until ctx is done, logging failed scans. A non positive
+// interval is logged and nothing is scanned
+func (r *Reporter) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		r.logger.Error("big key scans not started, interval must be positive", "interval", interval)
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := r.Scan(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				r.logger.Error("big key scan failed", "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Put -
+// Saves the value, reporting it when it exceeds the threshold
+func (r *Reporter) Put(key string, val []byte) error {
+	if int64(len(val)) > r.threshold {
+		r.report(BigKey{Key: key, Size: int64(len(val)), Source: SourcePut})
+	}
+	return r.Cache.Put(key, val)
+}
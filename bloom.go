@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// BloomGuard answers Gets for keys which were never written from an in-process bloom filter, so
+// high-miss-rate workloads skip the round trip to a slow backend such as redis. The filter has no
+// false negatives for keys written through the guard, Add or Seed, and false positives merely fall
+// through to the cache. Keys written by other processes must be announced through Add or Seed,
+// otherwise their Gets miss.
+type BloomGuard struct {
+	Wrapper
+	k       uint64 // hash functions per key
+	mutex   sync.RWMutex
+	bits    []uint64
+	skipped atomic.Uint64 // Gets answered by the filter
+
+	flushing sync.RWMutex // excludes Puts while Flush empties the filter and the cache
+}
+
+// WithBloomGuard -
+// Wraps c in a bloom filter sized for expected keys at the given false positive rate, e.g. 0.01.
+// The filter is not persisted, call Seed after a restart when c outlives the process
+func WithBloomGuard(c Cache, expected int, falsePositive float64) *BloomGuard {
+	if expected < 1 {
+		expected = 1
+	}
+	if falsePositive <= 0 || falsePositive >= 1 {
+		falsePositive = 0.01
+	}
+	m := math.Ceil(-float64(expected) * math.Log(falsePositive) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(expected)*math.Ln2))
+	return &BloomGuard{
+		Wrapper: Wrapper{Cache: c},
+		k:       uint64(k),
+		bits:    make([]uint64, (uint64(m)+63)/64),
+	}
+}
+
+// locations -
+// Calls fn with the bit index of every hash of key, by double hashing a single xxhash
+func (b *BloomGuard) locations(key string, fn func(i uint64)) {
+	h := xxhash.Sum64String(key)
+	h1, h2 := h&math.MaxUint32, h>>32|1
+	n := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < b.k; i++ {
+		fn((h1 + i*h2) % n)
+	}
+}
+
+// Add -
+// Records that key may hold a value, e.g. when another process announces a write
+func (b *BloomGuard) Add(key string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.locations(key, func(i uint64) {
+		b.bits[i/64] |= 1 << (i % 64)
+	})
+}
+
+// MayContain -
+// Reports whether key may have been written, false guarantees it never was
+func (b *BloomGuard) MayContain(key string) bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	found := true
+	b.locations(key, func(i uint64) {
+		found = found && b.bits[i/64]&(1<<(i%64)) != 0
+	})
+	return found
+}
+
+// Seed -
+// Adds every key of the cache to the filter, c must implement Scanner
+func (b *BloomGuard) Seed(ctx context.Context) error {
+	sc, ok := b.Cache.(Scanner)
+	if !ok {
+		return ErrNotSupported
+	}
+	return sc.Scan(ctx, func(key string) error {
+		b.Add(key)
+		return nil
+	})
+}
+
+// Skipped -
+// Returns how many Gets were answered by the filter without reaching the cache
+func (b *BloomGuard) Skipped() uint64 {
+	return b.skipped.Load()
+}
+
+// Put -
+// Records key in the filter, then saves the value
+func (b *BloomGuard) Put(key string, val []byte) error {
+	b.flushing.RLock()
+	defer b.flushing.RUnlock()
+	b.Add(key)
+	return b.Cache.Put(key, val)
+}
+
+// Get -
+// Fetches the value of key, failing with ErrKeyNotFound without reaching the cache when key was
+// never written
+func (b *BloomGuard) Get(key string) ([]byte, error) {
+	if !b.MayContain(key) {
+		b.skipped.Add(1)
+		return nil, fmt.Errorf("unable to retrieve %s, it was never written: %w", key, ErrKeyNotFound)
+	}
+	return b.Cache.Get(key)
+}
+
+// IsWarm -
+// Determines if key is cached inside the time window, without reaching the cache when key was
+// never written
+func (b *BloomGuard) IsWarm(key string) bool {
+	if !b.MayContain(key) {
+		b.skipped.Add(1)
+		return false
+	}
+	return b.Cache.IsWarm(key)
+}
+
+// Flush -
+// Empties the filter, then the cache, while holding back Puts so that no value written meanwhile
+// can be missing from the filter
+func (b *BloomGuard) Flush() error {
+	b.flushing.Lock()
+	defer b.flushing.Unlock()
+	b.mutex.Lock()
+	for i := range b.bits {
+		b.bits[i] = 0
+	}
+	b.mutex.Unlock()
+	return b.Cache.Flush()
+}
@@ -1,5 +1,7 @@
 package cache
 
+import "context"
+
 // Cache is the interface that operates the cache data.
 type Cache interface {
 	// Put puts value into cache with key and expire time.
@@ -14,6 +16,9 @@ type Cache interface {
 	Flush() error
 	// FlushStale flushes all stale cached items, older than the time window
 	FlushStale() error
-	// RunCleaner runs a process inside a go routine to flush stale cache items outside of the time window
-	RunCleaner()
+	// RunCleaner runs a process inside a go routine to flush stale cache items outside of the time window,
+	// the process stops once ctx is done or the cache is closed
+	RunCleaner(ctx context.Context)
+	// Close stops the cleaner and releases the resources held by the cache, it is safe to call more than once.
+	Close() error
 }
@@ -0,0 +1,256 @@
+// Package cacheadmin provides an embeddable HTTP handler to inspect and operate a cache: statistics,
+// keys by prefix with their remaining lifetime, single entries, deletes and flushes.
+package cacheadmin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+const defaultLimit = 100
+
+// errLimitReached stops the key scan once enough keys were collected
+var errLimitReached = errors.New("limit reached")
+
+// warmther is implemented by caches reporting the time left before an item goes stale
+type warmther interface {
+	Warmth(key string) (time.Duration, bool)
+}
+
+// lener is implemented by caches reporting their entry count
+type lener interface {
+	Len() int
+}
+
+// Admin serves the admin endpoints of a cache:
+//
+//	GET    /stats          operation counters, hit ratio and entry count
+//	GET    /keys?prefix=p  keys starting with p and their remaining lifetime, up to limit keys
+//	GET    /keys/{key}     a single entry, add ?raw=1 for the bare value
+//	DELETE /keys/{key}     deletes an entry
+//	POST   /flush          empties the cache
+//	POST   /flush-stale    flushes the stale items
+//
+// Every request must carry the token as a bearer token in its Authorization header.
+type Admin struct {
+	c        cache.Cache
+	token    string
+	readOnly bool
+	logger   cache.Logger
+}
+
+type Option func(*Admin)
+
+// New -
+// Initialises a new Admin of c protected by token. Mount it with http.StripPrefix, e.g.
+// mux.Handle("/admin/cache/", http.StripPrefix("/admin/cache", admin)). An empty token refuses
+// every request
+func New(c cache.Cache, token string, opts ...Option) *Admin {
+	a := &Admin{
+		c:      c,
+		token:  token,
+		logger: cache.NopLogger{},
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// ReadOnly -
+// Functional option refusing deletes and flushes, leaving the inspection endpoints
+func ReadOnly(enabled bool) Option {
+	return func(a *Admin) {
+		a.readOnly = enabled
+	}
+}
+
+// Logger -
+// Functional option to log the deletes and flushes requested through the handler
+func Logger(l cache.Logger) Option {
+	return func(a *Admin) {
+		a.logger = l
+	}
+}
+
+// ServeHTTP -
+// Authenticates the request and routes it to its endpoint
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if a.token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(a.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "invalid or missing token")
+		return
+	}
+	path := r.URL.EscapedPath()
+	switch {
+	case path == "/stats" && r.Method == http.MethodGet:
+		a.stats(w)
+	case path == "/keys" && r.Method == http.MethodGet:
+		a.keys(w, r)
+	case strings.HasPrefix(path, "/keys/"):
+		key, err := url.PathUnescape(strings.TrimPrefix(path, "/keys/"))
+		if err != nil || key == "" {
+			writeError(w, http.StatusBadRequest, "invalid key")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			a.entry(w, r, key)
+		case http.MethodDelete:
+			a.operate(w, "delete", key, func() error { return a.c.Delete(key) })
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	case path == "/flush" && r.Method == http.MethodPost:
+		a.operate(w, "flush", "", a.c.Flush)
+	case path == "/flush-stale" && r.Method == http.MethodPost:
+		a.operate(w, "flush_stale", "", a.c.FlushStale)
+	default:
+		writeError(w, http.StatusNotFound, "unknown endpoint")
+	}
+}
+
+// stats -
+// Writes the operation counters, hit ratio and entry count
+func (a *Admin) stats(w http.ResponseWriter) {
+	res := map[string]any{}
+	if r, ok := a.c.(cache.StatsReporter); ok {
+		stats := r.Stats()
+		res["hits"] = stats.Hits
+		res["misses"] = stats.Misses
+		res["puts"] = stats.Puts
+		res["deletes"] = stats.Deletes
+		res["evictions"] = stats.Evictions
+		res["stale_flushes"] = stats.StaleFlushes
+		res["hit_ratio"] = stats.HitRatio()
+	}
+	if l, ok := a.c.(lener); ok {
+		res["entries"] = l.Len()
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// keyInfo describes a key listed by the keys endpoint
+type keyInfo struct {
+	Key string  `json:"key"`
+	TTL *string `json:"ttl,omitempty"` // remaining lifetime, absent when unknown or pinned
+}
+
+// keys -
+// Writes the keys starting with the prefix query parameter, up to the limit query parameter
+func (a *Admin) keys(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.c.(cache.Scanner)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "cache cannot list its keys")
+		return
+	}
+	prefix := r.URL.Query().Get("prefix")
+	limit := defaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	keys := []keyInfo{}
+	err := s.Scan(r.Context(), func(key string) error {
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		keys = append(keys, keyInfo{Key: key, TTL: a.ttl(key)})
+		if len(keys) >= limit {
+			return errLimitReached
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimitReached) {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"keys": keys, "truncated": errors.Is(err, errLimitReached)})
+}
+
+// entry -
+// Writes a single entry, as JSON or as the bare value when the raw query parameter is set
+func (a *Admin) entry(w http.ResponseWriter, r *http.Request, key string) {
+	val, err := a.c.Get(key)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if raw, _ := strconv.ParseBool(r.URL.Query().Get("raw")); raw {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(val)
+		return
+	}
+	res := map[string]any{
+		"key":  key,
+		"size": len(val),
+	}
+	// printable values are shown as text, others are base64 encoded by encoding/json
+	if utf8.Valid(val) {
+		res["value"] = string(val)
+	} else {
+		res["value_base64"] = val
+	}
+	if ttl := a.ttl(key); ttl != nil {
+		res["ttl"] = *ttl
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// operate -
+// Runs a delete or flush unless the handler is read-only
+func (a *Admin) operate(w http.ResponseWriter, op, key string, fn func() error) {
+	if a.readOnly {
+		writeError(w, http.StatusForbidden, "admin handler is read-only")
+		return
+	}
+	if err := fn(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	a.logger.Info("cache admin operation", "op", op, "key", key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ttl -
+// Returns the remaining lifetime of key, nil when the cache cannot report it or the key is pinned
+func (a *Admin) ttl(key string) *string {
+	wt, ok := a.c.(warmther)
+	if !ok {
+		return nil
+	}
+	d, ok := wt.Warmth(key)
+	if !ok || d == 0 {
+		return nil
+	}
+	s := d.Round(time.Millisecond).String()
+	return &s
+}
+
+// writeJSON -
+// Writes v as the JSON body of the response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError -
+// Writes a JSON error body
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
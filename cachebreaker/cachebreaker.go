@@ -0,0 +1,350 @@
+// Package cachebreaker keeps an application working through a redis outage: after repeated
+// failures it stops calling redis for a while and serves from a bounded local cache instead,
+// probing redis periodically to recover.
+package cachebreaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+	"github.com/pedreviljoen/go-cache/memory"
+	"github.com/pedreviljoen/go-cache/redis"
+)
+
+const (
+	defaultThreshold    = 5
+	defaultCooldown     = time.Second * 5
+	defaultFallbackSize = 10000
+)
+
+// ErrFallbackFull is returned by writes the fallback cache has no room for while the breaker is open.
+var ErrFallbackFull = errors.New("cachebreaker: fallback cache full")
+
+// ErrOpen is returned by flushes which could not reach the primary cache as the breaker is open.
+var ErrOpen = errors.New("cachebreaker: circuit open, primary cache skipped")
+
+// State of a circuit breaker
+type State int
+
+const (
+	// Closed calls the primary cache.
+	Closed State = iota
+	// Open serves from the fallback cache without calling the primary.
+	Open
+	// HalfOpen lets a single probe through to the primary to decide whether it recovered.
+	HalfOpen
+)
+
+// String -
+// Returns the lower case name of the state
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Breaker is a cache.Cache calling a primary cache until it fails threshold consecutive times, then
+// serving from a fallback cache for the cooldown before probing the primary again. The fallback is
+// flushed when the primary recovers, writes made during the outage are not replayed onto the primary
+type Breaker struct {
+	primary      cache.Cache
+	fallback     cache.Cache
+	fallbackSize int
+	threshold    int
+	cooldown     time.Duration
+	isFailure    func(error) bool
+	onState      func(from, to State)
+	logger       cache.Logger
+
+	mutex     sync.Mutex
+	state     State
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+type Option func(*Breaker)
+
+// New -
+// Wraps primary, typically a redis cache, with a circuit breaker falling back onto an in-memory cache
+func New(primary cache.Cache, opts ...Option) *Breaker {
+	b := &Breaker{
+		primary:      primary,
+		fallbackSize: defaultFallbackSize,
+		threshold:    defaultThreshold,
+		cooldown:     defaultCooldown,
+		isFailure:    failure,
+		logger:       cache.NopLogger{},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.fallback == nil {
+		b.fallback = memory.New()
+	}
+	return b
+}
+
+// Threshold -
+// Functional option to specify how many consecutive failures open the breaker, defaults to 5
+func Threshold(n int) Option {
+	return func(b *Breaker) {
+		if n > 0 {
+			b.threshold = n
+		}
+	}
+}
+
+// Cooldown -
+// Functional option to specify how long the breaker stays open before probing the primary, defaults to 5 seconds
+func Cooldown(d time.Duration) Option {
+	return func(b *Breaker) {
+		b.cooldown = d
+	}
+}
+
+// Fallback -
+// Functional option to specify the cache serving while the breaker is open, defaults to a memory cache
+func Fallback(c cache.Cache) Option {
+	return func(b *Breaker) {
+		b.fallback = c
+	}
+}
+
+// FallbackSize -
+// Functional option to specify how many items the fallback may hold, writes beyond it fail with
+// ErrFallbackFull. Only enforced for fallbacks reporting their size through Len, defaults to 10000
+func FallbackSize(n int) Option {
+	return func(b *Breaker) {
+		b.fallbackSize = n
+	}
+}
+
+// IsFailure -
+// Functional option to specify which errors of the primary count as failures, defaults to
+// connection failures, timeouts and temporary redis conditions, see redis.IsTransient
+func IsFailure(fn func(error) bool) Option {
+	return func(b *Breaker) {
+		b.isFailure = fn
+	}
+}
+
+// OnStateChange -
+// Functional option to specify a callback invoked whenever the breaker changes state. It runs
+// without holding the breaker, so it may call State or the cache
+func OnStateChange(fn func(from, to State)) Option {
+	return func(b *Breaker) {
+		b.onState = fn
+	}
+}
+
+// Logger -
+// Functional option to specify the logger receiving state changes
+func Logger(l cache.Logger) Option {
+	return func(b *Breaker) {
+		b.logger = l
+	}
+}
+
+// failure -
+// Default failure predicate, a missing key is not a failure
+func failure(err error) bool {
+	return redis.IsTransient(err) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// State -
+// Returns the current state of the breaker
+func (b *Breaker) State() State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.state
+}
+
+// allow -
+// Reports whether a call may go to the primary, letting a single probe through once the cooldown passed
+func (b *Breaker) allow() bool {
+	var notify func()
+	defer func() {
+		if notify != nil {
+			notify()
+		}
+	}()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch b.state {
+	case Closed:
+		return true
+	case Open:
+		if time.Now().Before(b.openUntil) {
+			return false
+		}
+		notify = b.transition(HalfOpen)
+	}
+	if b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record -
+// Records the outcome of a call to the primary
+func (b *Breaker) record(err error) {
+	failed := err != nil && b.isFailure(err)
+	var notify func()
+	b.mutex.Lock()
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		if b.state != Closed {
+			closed := b.transition(Closed)
+			notify = func() {
+				closed()
+				b.fallback.Flush() // local copies may be stale once the primary serves again
+			}
+		}
+	} else {
+		b.failures++
+		if b.state == HalfOpen || b.failures >= b.threshold {
+			b.openUntil = time.Now().Add(b.cooldown)
+			if b.state != Open {
+				notify = b.transition(Open)
+			}
+		}
+	}
+	b.mutex.Unlock()
+	if notify != nil {
+		notify()
+	}
+}
+
+// transition -
+// Moves the breaker to state, the caller must hold the lock. Returns the function logging the
+// change and calling OnState, to be called once the lock is released so the callback may use
+// the breaker
+func (b *Breaker) transition(to State) func() {
+	from := b.state
+	b.state = to
+	return func() {
+		b.logger.Info("cache circuit breaker state changed", "from", from.String(), "to", to.String())
+		if b.onState != nil {
+			b.onState(from, to)
+		}
+	}
+}
+
+// room -
+// Reports whether the fallback may take another item
+func (b *Breaker) room() bool {
+	l, ok := b.fallback.(interface{ Len() int })
+	return !ok || b.fallbackSize <= 0 || l.Len() < b.fallbackSize
+}
+
+// Put -
+// Saves the value inside the primary, or the fallback while the breaker is open
+func (b *Breaker) Put(key string, val []byte) error {
+	if b.allow() {
+		err := b.primary.Put(key, val)
+		b.record(err)
+		if err == nil || !b.isFailure(err) {
+			return err
+		}
+	}
+	if !b.fallback.IsWarm(key) && !b.room() {
+		return ErrFallbackFull
+	}
+	return b.fallback.Put(key, val)
+}
+
+// Get -
+// Fetches the value from the primary, or the fallback while the breaker is open
+func (b *Breaker) Get(key string) ([]byte, error) {
+	if b.allow() {
+		val, err := b.primary.Get(key)
+		b.record(err)
+		if err == nil || !b.isFailure(err) {
+			return val, err
+		}
+	}
+	return b.fallback.Get(key)
+}
+
+// Delete -
+// Deletes the value from the primary, or the fallback while the breaker is open
+func (b *Breaker) Delete(key string) error {
+	if b.allow() {
+		err := b.primary.Delete(key)
+		b.record(err)
+		if err == nil || !b.isFailure(err) {
+			return err
+		}
+	}
+	return b.fallback.Delete(key)
+}
+
+// IsWarm -
+// Determines if the primary, or the fallback while the breaker is open, holds a value for key
+func (b *Breaker) IsWarm(key string) bool {
+	if b.State() == Closed {
+		return b.primary.IsWarm(key)
+	}
+	return b.fallback.IsWarm(key)
+}
+
+// Flush -
+// Empties both the primary and the fallback, returns ErrOpen when the primary was skipped
+// as the breaker is open, so callers know the primary still holds its values
+func (b *Breaker) Flush() error {
+	ferr := b.fallback.Flush()
+	if !b.allow() {
+		return ErrOpen
+	}
+	err := b.primary.Flush()
+	b.record(err)
+	if err != nil {
+		return err
+	}
+	return ferr
+}
+
+// FlushStale -
+// Flushes the stale items of the primary and the fallback, returns ErrOpen when the primary
+// was skipped as the breaker is open
+func (b *Breaker) FlushStale() error {
+	ferr := b.fallback.FlushStale()
+	if !b.allow() {
+		return ErrOpen
+	}
+	err := b.primary.FlushStale()
+	b.record(err)
+	if err != nil {
+		return err
+	}
+	return ferr
+}
+
+// RunCleaner -
+// Runs the cleaners of the primary and the fallback
+func (b *Breaker) RunCleaner(ctx context.Context) {
+	b.primary.RunCleaner(ctx)
+	b.fallback.RunCleaner(ctx)
+}
+
+// Close -
+// Closes the primary and the fallback
+func (b *Breaker) Close() error {
+	err := b.primary.Close()
+	if ferr := b.fallback.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
@@ -0,0 +1,194 @@
+// Package cachebus keeps in-memory caches of several replicas coherent by publishing
+// their writes over redis pub/sub and applying the writes of the other replicas.
+package cachebus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/pedreviljoen/go-cache"
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultTopic = "go-cache:bus"
+
+// event is the message published for every write
+type event struct {
+	Origin string   `json:"origin"`
+	Op     cache.Op `json:"op"`
+	Key    string   `json:"key,omitempty"`
+	Value  []byte   `json:"value,omitempty"`
+}
+
+// Bus wraps a local cache, every Put, Delete and Flush is applied locally and published
+// so that the local caches of the other replicas subscribed to the same topic apply it too.
+// Reads and stale flushes only concern the local cache, staleness is local so it is not published
+type Bus struct {
+	cache.Wrapper
+	client redis.UniversalClient
+	topic  string
+	origin string // identifies the events published by this bus
+	logger cache.Logger
+
+	mutex  sync.Mutex
+	pubsub *redis.PubSub
+	done   chan struct{}
+}
+
+type Option func(*Bus)
+
+// New -
+// Initialises a new Bus publishing the writes of local through client
+func New(client redis.UniversalClient, local cache.Cache, opts ...Option) *Bus {
+	id := make([]byte, 8)
+	rand.Read(id)
+	b := &Bus{
+		Wrapper: cache.Wrapper{Cache: local},
+		client:  client,
+		topic:   defaultTopic,
+		origin:  hex.EncodeToString(id),
+		logger:  cache.NopLogger{},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Topic -
+// Functional option to specify the pub/sub channel the events are exchanged on, defaults to "go-cache:bus"
+func Topic(t string) Option {
+	return func(b *Bus) {
+		b.topic = t
+	}
+}
+
+// Logger -
+// Functional option to specify the logger receiving malformed events and failures to apply them
+func Logger(l cache.Logger) Option {
+	return func(b *Bus) {
+		b.logger = l
+	}
+}
+
+// Run -
+// Subscribes to the topic and applies the events published by other replicas to the local
+// cache in a separate go routine, until ctx is done or the bus is closed
+func (b *Bus) Run(ctx context.Context) error {
+	pubsub := b.client.Subscribe(ctx, b.topic)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+	done := make(chan struct{})
+	b.mutex.Lock()
+	b.pubsub = pubsub
+	b.done = done
+	b.mutex.Unlock()
+
+	go func() {
+		defer close(done)
+		ch := pubsub.Channel()
+		for {
+			select {
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				b.apply(msg.Payload)
+			case <-ctx.Done():
+				pubsub.Close()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// apply -
+// Applies a single event published by another replica to the local cache
+func (b *Bus) apply(payload string) {
+	var e event
+	if err := json.Unmarshal([]byte(payload), &e); err != nil {
+		b.logger.Error("cache bus received malformed event", "error", err)
+		return
+	}
+	if e.Origin == b.origin {
+		return
+	}
+	var err error
+	switch e.Op {
+	case cache.OpPut:
+		err = b.Cache.Put(e.Key, e.Value)
+	case cache.OpDelete:
+		// stale entries are deleted too, the local cache may still serve them
+		if err = b.Cache.Delete(e.Key); errors.Is(err, cache.ErrKeyNotFound) {
+			err = nil
+		}
+	case cache.OpFlush:
+		err = b.Cache.Flush()
+	}
+	if err != nil {
+		b.logger.Error("cache bus failed to apply event", "op", e.Op, "key", e.Key, "error", err)
+	}
+}
+
+// publish -
+// Publishes a write of the local cache to the other replicas
+func (b *Bus) publish(op cache.Op, key string, value []byte) error {
+	payload, err := json.Marshal(event{Origin: b.origin, Op: op, Key: key, Value: value})
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(context.Background(), b.topic, payload).Err()
+}
+
+// Put -
+// Saves the value inside the local cache and publishes it to the other replicas
+func (b *Bus) Put(key string, val []byte) error {
+	if err := b.Cache.Put(key, val); err != nil {
+		return err
+	}
+	return b.publish(cache.OpPut, key, val)
+}
+
+// Delete -
+// Deletes the value from the local cache and from the caches of the other replicas. The delete is
+// published even when the local cache holds no value for key, as other replicas may still hold one
+func (b *Bus) Delete(key string) error {
+	err := b.Cache.Delete(key)
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		err = nil
+	}
+	if perr := b.publish(cache.OpDelete, key, nil); perr != nil {
+		return perr
+	}
+	return err
+}
+
+// Flush -
+// Empties the local cache and the caches of the other replicas
+func (b *Bus) Flush() error {
+	if err := b.Cache.Flush(); err != nil {
+		return err
+	}
+	return b.publish(cache.OpFlush, "", nil)
+}
+
+// Close -
+// Stops applying the events of other replicas and closes the local cache
+func (b *Bus) Close() error {
+	b.mutex.Lock()
+	pubsub, done := b.pubsub, b.done
+	b.pubsub = nil
+	b.mutex.Unlock()
+	if pubsub != nil {
+		pubsub.Close()
+		<-done
+	}
+	return b.Cache.Close()
+}
@@ -0,0 +1,26 @@
+// Package cachecodec provides cache.Codec implementations and helpers relying on third party encodings,
+// for use with cache.WithCodec.
+package cachecodec
+
+import (
+	"github.com/pedreviljoen/go-cache"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgPack encodes values with MessagePack, more compact and faster than JSON
+var MsgPack cache.Codec = msgpackCodec{}
+
+// msgpackCodec implements cache.Codec with MessagePack
+type msgpackCodec struct{}
+
+// Marshal -
+// Encodes v as MessagePack
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+// Unmarshal -
+// Decodes MessagePack into v
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
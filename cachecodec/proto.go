@@ -0,0 +1,75 @@
+package cachecodec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/pedreviljoen/go-cache"
+	"google.golang.org/protobuf/proto"
+)
+
+// ProtoCodec encodes protocol buffer messages, values must implement proto.Message. Deterministic
+// marshaling orders map entries so equal messages always produce the same bytes
+type ProtoCodec struct {
+	Deterministic bool
+}
+
+// Marshal -
+// Encodes the proto.Message v
+func (p ProtoCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a proto.Message", v)
+	}
+	return proto.MarshalOptions{Deterministic: p.Deterministic}.Marshal(m)
+}
+
+// Unmarshal -
+// Decodes data into the proto.Message v
+func (p ProtoCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// PutProto -
+// Marshals m and saves it under key inside c
+func PutProto(c cache.Cache, key string, m proto.Message) error {
+	data, err := proto.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("unable to encode value: %w", err)
+	}
+	return c.Put(key, data)
+}
+
+// GetProto -
+// Fetches the value of key from c and unmarshals it into m
+func GetProto(c cache.Cache, key string, m proto.Message) error {
+	data, err := c.Get(key)
+	if err != nil {
+		return err
+	}
+	if err := proto.Unmarshal(data, m); err != nil {
+		return fmt.Errorf("unable to decode value: %w", err)
+	}
+	return nil
+}
+
+// ProtoKey -
+// Derives a stable cache key from a message, typically a request, as prefix followed by the hex SHA-256
+// of its deterministic encoding. Equal messages, including their maps, always produce the same key
+func ProtoKey(prefix string, m proto.Message) (string, error) {
+	if m == nil {
+		return "", errors.New("unable to derive a cache key from a nil message")
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("unable to derive a cache key: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return prefix + hex.EncodeToString(sum[:]), nil
+}
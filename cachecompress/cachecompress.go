@@ -0,0 +1,63 @@
+// Package cachecompress provides snappy and zstd implementations of cache.Compressor,
+// for use with cache.WithCompression.
+package cachecompress
+
+import (
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pedreviljoen/go-cache"
+)
+
+// Snappy compresses values in the snappy block format, favouring speed over ratio
+var Snappy cache.Compressor = snappyCompressor{}
+
+// snappyCompressor implements cache.Compressor with snappy
+type snappyCompressor struct{}
+
+// Compress -
+// Compresses src into the snappy block format
+func (snappyCompressor) Compress(src []byte) ([]byte, error) {
+	return s2.EncodeSnappy(nil, src), nil
+}
+
+// Decompress -
+// Decompresses a snappy block
+func (snappyCompressor) Decompress(src []byte) ([]byte, error) {
+	return s2.Decode(nil, src)
+}
+
+// zstdCompressor implements cache.Compressor with zstd
+type zstdCompressor struct {
+	enc *zstd.Encoder
+	dec *zstd.Decoder
+}
+
+// Zstd -
+// Returns a compressor using zstd at the given level, favouring ratio, the encoder and decoder
+// are shared and safe for concurrent use
+func Zstd(level zstd.EncoderLevel) (cache.Compressor, error) {
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+	if err != nil {
+		return nil, err
+	}
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &zstdCompressor{
+		enc: enc,
+		dec: dec,
+	}, nil
+}
+
+// Compress -
+// Compresses src into a zstd frame
+func (z *zstdCompressor) Compress(src []byte) ([]byte, error) {
+	return z.enc.EncodeAll(src, nil), nil
+}
+
+// Decompress -
+// Decompresses a zstd frame
+func (z *zstdCompressor) Decompress(src []byte) ([]byte, error) {
+	return z.dec.DecodeAll(src, nil)
+}
@@ -0,0 +1,154 @@
+// Package cachedebug exposes the live internals of a cache over HTTP, to be mounted next to
+// /debug/pprof, e.g. http.Handle("/debug/cache", cachedebug.Handler(c)).
+package cachedebug
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+const defaultTopKeys = 20
+
+// lener is implemented by caches reporting their entry count
+type lener interface {
+	Len() int
+}
+
+// topKeyser is implemented by caches tracking their hottest keys, e.g. MemCache with TrackKeyHits
+type topKeyser interface {
+	TopKeys(n int) []cache.KeyHits
+}
+
+// cleanerReporter is implemented by caches reporting their last cleaner run
+type cleanerReporter interface {
+	CleanerRun() (time.Time, time.Duration)
+}
+
+// memoryReporter is implemented by caches estimating the memory held by their entries
+type memoryReporter interface {
+	MemoryUsage() int64
+}
+
+// debugHandler serves the internals of a cache
+type debugHandler struct {
+	c        cache.Cache
+	topKeys  int
+	showKeys bool
+}
+
+type Option func(*debugHandler)
+
+// TopKeys -
+// Functional option to specify how many of the hottest keys are listed, defaults to 20. Requests
+// may override it with the top query parameter
+func TopKeys(n int) Option {
+	return func(h *debugHandler) {
+		h.topKeys = n
+	}
+}
+
+// ShowKeys -
+// Functional option listing the hottest keys as they are rather than by a truncated sha256 of
+// each, which keeps session IDs and other secrets used as keys out of the response. Only enable
+// it when the handler is reachable by trusted operators alone
+func ShowKeys(show bool) Option {
+	return func(h *debugHandler) {
+		h.showKeys = show
+	}
+}
+
+// Handler -
+// Returns a handler answering GET requests with a JSON document holding whatever c reports of its
+// entry count, operation counters and hit ratio, hottest keys, last cleaner run and its duration,
+// and memory estimate. Sections the cache cannot report are left out, MemCache reports them all
+// once TrackKeyHits is enabled. The handler is unauthenticated, like /debug/pprof, so hot keys are
+// listed by hash unless ShowKeys is set
+func Handler(c cache.Cache, opts ...Option) http.Handler {
+	h := &debugHandler{
+		c:       c,
+		topKeys: defaultTopKeys,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// ServeHTTP -
+// Writes the internals of the cache as JSON
+func (h *debugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	top := h.topKeys
+	if v := r.URL.Query().Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid top parameter", http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+
+	res := map[string]any{}
+	if l, ok := h.c.(lener); ok {
+		res["entries"] = l.Len()
+	}
+	if r, ok := h.c.(cache.StatsReporter); ok {
+		stats := r.Stats()
+		res["hits"] = stats.Hits
+		res["misses"] = stats.Misses
+		res["hit_ratio"] = stats.HitRatio()
+		res["puts"] = stats.Puts
+		res["deletes"] = stats.Deletes
+		res["evictions"] = stats.Evictions
+		res["stale_flushes"] = stats.StaleFlushes
+	}
+	if t, ok := h.c.(topKeyser); ok && top > 0 {
+		// a nil result means the cache does not track hits
+		if hot := t.TopKeys(top); hot != nil {
+			keys := make([]map[string]any, 0, len(hot))
+			for _, k := range hot {
+				keys = append(keys, map[string]any{"key": h.key(k.Key), "hits": k.Hits})
+			}
+			res["top_keys"] = keys
+		}
+	}
+	if cr, ok := h.c.(cleanerReporter); ok {
+		last, took := cr.CleanerRun()
+		cleaner := map[string]any{"last_run": nil}
+		if !last.IsZero() {
+			cleaner["last_run"] = last.UTC().Format(time.RFC3339Nano)
+			cleaner["last_duration"] = took.String()
+		}
+		res["cleaner"] = cleaner
+	}
+	if m, ok := h.c.(memoryReporter); ok {
+		res["memory_bytes"] = m.MemoryUsage()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(res)
+}
+
+// key -
+// Returns the key as listed in the response, the first 8 bytes of its sha256 unless ShowKeys is set.
+// Operators can still recognise a suspected key by hashing it
+func (h *debugHandler) key(key string) string {
+	if h.showKeys {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
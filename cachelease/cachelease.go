@@ -0,0 +1,191 @@
+// Package cachelease protects expensive loads from stampedes across processes: when several
+// replicas miss the same key, only the holder of the fill lease loads the value while the others
+// wait for it to appear in the cache, or serve a stale copy meanwhile.
+package cachelease
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+	"github.com/pedreviljoen/go-cache/lock"
+)
+
+const (
+	defaultLeaseTTL     = time.Second * 10
+	defaultWait         = time.Second * 5
+	defaultPollInterval = time.Millisecond * 50
+	staleSuffix         = ":go-cache:stale"
+)
+
+// Filler reads through a cache, coordinating the loads of missing keys with a lease held in a
+// lock.Locker shared by every process, and with singleflight inside the process.
+type Filler struct {
+	c            cache.Cache
+	locker       *lock.Locker
+	leaseTTL     time.Duration
+	wait         time.Duration
+	pollInterval time.Duration
+	staleTTL     time.Duration
+	logger       cache.Logger
+
+	fills cache.Flight
+}
+
+type Option func(*Filler)
+
+// New -
+// Initialises a new Filler on top of c, holding leases through lock.New(c) unless overridden
+// with Locker, so redis caches coordinate every process sharing the server. Fails when c is
+// neither a redis nor a memory cache and no Locker is given
+func New(c cache.Cache, opts ...Option) (*Filler, error) {
+	f := &Filler{
+		c:            c,
+		leaseTTL:     defaultLeaseTTL,
+		wait:         defaultWait,
+		pollInterval: defaultPollInterval,
+		logger:       cache.NopLogger{},
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.locker == nil {
+		l, err := lock.New(c, lock.KeyPrefix("go-cache:lease:"))
+		if err != nil {
+			return nil, err
+		}
+		f.locker = l
+	}
+	return f, nil
+}
+
+// Locker -
+// Functional option to specify the locker holding the fill leases
+func Locker(l *lock.Locker) Option {
+	return func(f *Filler) {
+		f.locker = l
+	}
+}
+
+// LeaseTTL -
+// Functional option to specify how long a lease is held at most, it should exceed the duration
+// of a load. Defaults to 10 seconds
+func LeaseTTL(d time.Duration) Option {
+	return func(f *Filler) {
+		f.leaseTTL = d
+	}
+}
+
+// Wait -
+// Functional option to specify how long a process missing the lease waits for the value to
+// appear before loading it itself, defaults to 5 seconds
+func Wait(d time.Duration) Option {
+	return func(f *Filler) {
+		f.wait = d
+	}
+}
+
+// PollInterval -
+// Functional option to specify how often a waiting process checks the cache, defaults to 50ms
+func PollInterval(d time.Duration) Option {
+	return func(f *Filler) {
+		f.pollInterval = d
+	}
+}
+
+// ServeStale -
+// Functional option keeping a copy of every loaded value for d, served to the processes
+// waiting for the lease holder instead of making them wait. Requires a cache implementing
+// cache.Toucher, as the memory and redis caches do, for the copy to outlive the value
+func ServeStale(d time.Duration) Option {
+	return func(f *Filler) {
+		f.staleTTL = d
+	}
+}
+
+// Logger -
+// Functional option to log lease and cache failures, which fall back to loading the value
+func Logger(l cache.Logger) Option {
+	return func(f *Filler) {
+		f.logger = l
+	}
+}
+
+// Get -
+// Returns the cached value of key or, on a miss, the value loaded by the holder of the fill lease.
+// The process acquiring the lease calls load and caches its result, the others serve the stale
+// copy when there is one, or wait for the value until the wait timeout and then call load themselves.
+// Callers of the same key inside the process share one fill, which is only cancelled once every one
+// of them has given up, each caller returning as soon as its own ctx is done
+func (f *Filler) Get(ctx context.Context, key string, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	if val, err := f.c.Get(key); err == nil {
+		return val, nil
+	}
+	v, err := f.fills.Do(ctx, key, func(ctx context.Context) (any, error) {
+		return f.fill(ctx, key, load)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+// fill -
+// Loads the value of key under the lease, or waits for its holder
+func (f *Filler) fill(ctx context.Context, key string, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	lease, err := f.locker.TryAcquire(ctx, key, f.leaseTTL)
+	switch {
+	case err == nil:
+		defer lease.Release(context.Background())
+		// the previous holder may have filled the key between the miss and the lease
+		if val, err := f.c.Get(key); err == nil {
+			return val, nil
+		}
+		return f.load(ctx, key, load)
+	case !errors.Is(err, lock.ErrNotAcquired):
+		f.logger.Error("unable to acquire fill lease, loading without it", "key", key, "error", err)
+		return f.load(ctx, key, load)
+	}
+
+	if f.staleTTL > 0 {
+		if val, err := f.c.Get(key + staleSuffix); err == nil {
+			return val, nil
+		}
+	}
+	deadline := time.NewTimer(f.wait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(f.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			f.logger.Info("fill lease holder too slow, loading without the lease", "key", key)
+			return f.load(ctx, key, load)
+		case <-ticker.C:
+			if val, err := f.c.Get(key); err == nil {
+				return val, nil
+			}
+		}
+	}
+}
+
+// load -
+// Calls load and caches its result, along with its stale copy when enabled
+func (f *Filler) load(ctx context.Context, key string, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	val, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.c.Put(key, val); err != nil {
+		f.logger.Error("unable to cache loaded value", "key", key, "error", err)
+	}
+	if t, ok := f.c.(cache.Toucher); ok && f.staleTTL > 0 {
+		if err := f.c.Put(key+staleSuffix, val); err == nil {
+			t.Touch(key+staleSuffix, f.staleTTL)
+		}
+	}
+	return val, nil
+}
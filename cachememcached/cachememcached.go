@@ -0,0 +1,163 @@
+// Package cachememcached serves a cache over the memcached text protocol, so legacy clients and
+// tooling can use an embedded MemCache without running memcached.
+package cachememcached
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+const (
+	maxKeyLen   = 250
+	maxValueLen = 512 << 20
+	version     = "1.6.0-go-cache"
+)
+
+// Server accepts memcached text protocol connections and runs their commands against a cache.
+// Supported commands are get, gets, set, add, replace, append, prepend, cas, delete, incr, decr,
+// touch, flush_all, stats, version, verbosity and quit. Client flags are not stored and are
+// always returned as 0, and items saved with an exptime of 0 live for the window of the cache.
+type Server struct {
+	c      cache.Cache
+	clock  cache.Clock
+	logger cache.Logger
+
+	mutex     sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+type Option func(*Server)
+
+// New -
+// Initialises a new Server on top of c, typically a MemCache. gets, cas, add, replace, append,
+// prepend, incr, decr and touch require the matching capabilities of the cache, as MemCache provides
+func New(c cache.Cache, opts ...Option) *Server {
+	s := &Server{
+		c:         c,
+		clock:     cache.SystemClock{},
+		logger:    cache.NopLogger{},
+		listeners: map[net.Listener]struct{}{},
+		conns:     map[net.Conn]struct{}{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Clock -
+// Functional option to specify the clock resolving absolute exptimes, defaults to the system clock
+func Clock(clock cache.Clock) Option {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// Logger -
+// Functional option to log connection failures
+func Logger(l cache.Logger) Option {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// ListenAndServe -
+// Listens on the TCP address addr, e.g. "localhost:11211", and serves connections until Close
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve -
+// Serves the connections accepted by l until Close, always returning a non-nil error
+func (s *Server) Serve(l net.Listener) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		l.Close()
+		return net.ErrClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mutex.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mutex.Lock()
+			closed := s.closed
+			s.mutex.Unlock()
+			if closed {
+				return net.ErrClosed
+			}
+			return err
+		}
+		s.mutex.Lock()
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mutex.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close -
+// Stops the listeners, closes every connection and waits for them to finish. The cache is left open
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// serveConn -
+// Reads commands from conn and writes their replies until the client quits or fails
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		s.mutex.Unlock()
+		s.wg.Done()
+	}()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.logger.Error("memcached connection failed", "remote", conn.RemoteAddr().String(), "error", err)
+			}
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			w.WriteString("ERROR\r\n")
+		} else if quit := s.exec(r, w, args); quit {
+			w.Flush()
+			return
+		}
+		// replies of pipelined commands are flushed together
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
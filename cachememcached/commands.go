@@ -0,0 +1,426 @@
+package cachememcached
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+// relativeLimit is the largest exptime read as seconds from now, larger exptimes are unix timestamps
+const relativeLimit = 60 * 60 * 24 * 30
+
+// adder is implemented by caches saving a value only when the key is missing
+type adder interface {
+	Add(key string, val []byte) error
+}
+
+// replacer is implemented by caches saving a value only when the key exists
+type replacer interface {
+	Replace(key string, val []byte) error
+}
+
+// appender is implemented by caches appending to a value in place
+type appender interface {
+	Append(key string, data []byte) error
+}
+
+// counter is implemented by caches holding integer counters
+type counter interface {
+	Increment(key string, delta int64) (int64, error)
+	Decrement(key string, delta int64) (int64, error)
+}
+
+// lener is implemented by caches reporting their entry count
+type lener interface {
+	Len() int
+}
+
+// exec -
+// Runs a command and writes its reply, reporting whether the connection must be closed, either
+// because the client quit or because the stream can no longer be parsed
+func (s *Server) exec(r *bufio.Reader, w *bufio.Writer, args []string) bool {
+	name := args[0]
+	args = args[1:]
+	switch name {
+	case "get", "gets":
+		if len(args) == 0 {
+			w.WriteString("ERROR\r\n")
+			return false
+		}
+		s.get(w, args, name == "gets")
+	case "set", "add", "replace", "append", "prepend", "cas":
+		return s.store(r, w, name, args)
+	case "delete":
+		key, noreply, ok := parseKey(args, 1)
+		if !ok {
+			w.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return false
+		}
+		reply := "DELETED"
+		if !s.c.IsWarm(key) {
+			reply = "NOT_FOUND"
+		}
+		if err := s.c.Delete(key); err != nil && reply == "DELETED" {
+			reply = "SERVER_ERROR " + err.Error()
+		}
+		writeReply(w, reply, noreply)
+	case "incr", "decr":
+		key, noreply, ok := parseKey(args, 2)
+		delta, err := strconv.ParseUint(arg(args, 1), 10, 63)
+		if !ok || err != nil {
+			w.WriteString("CLIENT_ERROR invalid numeric delta argument\r\n")
+			return false
+		}
+		writeReply(w, s.incr(key, int64(delta), name == "decr"), noreply)
+	case "touch":
+		key, noreply, ok := parseKey(args, 2)
+		exptime, err := strconv.ParseInt(arg(args, 1), 10, 64)
+		if !ok || err != nil {
+			w.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return false
+		}
+		writeReply(w, s.touch(key, exptime), noreply)
+	case "flush_all":
+		noreply := len(args) > 0 && args[len(args)-1] == "noreply"
+		if noreply {
+			args = args[:len(args)-1]
+		}
+		if len(args) > 0 && args[0] != "0" {
+			writeReply(w, "CLIENT_ERROR delayed flush_all is not supported", noreply)
+			return false
+		}
+		reply := "OK"
+		if err := s.c.Flush(); err != nil {
+			reply = "SERVER_ERROR " + err.Error()
+		}
+		writeReply(w, reply, noreply)
+	case "stats":
+		if len(args) > 0 {
+			// only the general statistics are available
+			w.WriteString("END\r\n")
+			return false
+		}
+		s.stats(w)
+	case "version":
+		w.WriteString("VERSION " + version + "\r\n")
+	case "verbosity":
+		writeReply(w, "OK", len(args) > 0 && args[len(args)-1] == "noreply")
+	case "quit":
+		return true
+	default:
+		w.WriteString("ERROR\r\n")
+	}
+	return false
+}
+
+// get -
+// Writes a VALUE line for every key holding a value, including its cas unique for gets
+func (s *Server) get(w *bufio.Writer, keys []string, cas bool) {
+	vp, versioned := s.c.(cache.VersionedPutter)
+	if cas && !versioned {
+		w.WriteString("SERVER_ERROR cache does not support cas\r\n")
+		return
+	}
+	for _, key := range keys {
+		if !cas {
+			val, err := s.c.Get(key)
+			if err != nil {
+				continue
+			}
+			writeValue(w, key, val, "")
+			continue
+		}
+		val, version, err := vp.GetVersion(key)
+		if err != nil {
+			continue
+		}
+		if _, err := strconv.ParseUint(string(version), 10, 64); err != nil {
+			w.WriteString("SERVER_ERROR cache versions are not numeric\r\n")
+			return
+		}
+		writeValue(w, key, val, string(version))
+	}
+	w.WriteString("END\r\n")
+}
+
+// store -
+// Runs a storage command: <command> <key> <flags> <exptime> <bytes> [<cas unique>] [noreply],
+// followed by the data block
+func (s *Server) store(r *bufio.Reader, w *bufio.Writer, name string, args []string) bool {
+	n := 4
+	if name == "cas" {
+		n = 5
+	}
+	size, err := strconv.Atoi(arg(args, 3))
+	if err != nil || size < 0 || size > maxValueLen {
+		// the data block cannot be skipped without knowing its size
+		w.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return true
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return true
+	}
+	if string(data[size:]) != "\r\n" {
+		w.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		return true
+	}
+	key, noreply, ok := parseKey(args, n)
+	_, errFlags := strconv.ParseUint(args[1], 10, 32)
+	exptime, errExp := strconv.ParseInt(args[2], 10, 64)
+	if !ok || errFlags != nil || errExp != nil {
+		w.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return false
+	}
+	val := data[:size:size]
+
+	switch name {
+	case "set":
+		err = s.c.Put(key, val)
+	case "add":
+		if a, ok := s.c.(adder); ok {
+			err = a.Add(key, val)
+		} else {
+			err = cache.ErrNotSupported
+		}
+	case "replace":
+		if rp, ok := s.c.(replacer); ok {
+			err = rp.Replace(key, val)
+		} else {
+			err = cache.ErrNotSupported
+		}
+	case "append":
+		err = s.append(key, val)
+	case "prepend":
+		err = s.prepend(key, val)
+	case "cas":
+		err = s.cas(key, val, args[4])
+	}
+	switch {
+	case errors.Is(err, cache.ErrKeyExists), errors.Is(err, cache.ErrKeyNotFound) && name != "cas":
+		writeReply(w, "NOT_STORED", noreply)
+		return false
+	case errors.Is(err, cache.ErrKeyNotFound):
+		writeReply(w, "NOT_FOUND", noreply)
+		return false
+	case errors.Is(err, cache.ErrVersionMismatch):
+		writeReply(w, "EXISTS", noreply)
+		return false
+	case errors.Is(err, cache.ErrValueTooLarge):
+		writeReply(w, "SERVER_ERROR object too large for cache", noreply)
+		return false
+	case err != nil:
+		writeReply(w, "SERVER_ERROR "+err.Error(), noreply)
+		return false
+	}
+	// append and prepend leave the lifetime of the item as is
+	if name != "append" && name != "prepend" {
+		if err := s.expire(key, exptime); err != nil {
+			writeReply(w, "SERVER_ERROR "+err.Error(), noreply)
+			return false
+		}
+	}
+	writeReply(w, "STORED", noreply)
+	return false
+}
+
+// append -
+// Appends data to the value of an existing key
+func (s *Server) append(key string, data []byte) error {
+	a, ok := s.c.(appender)
+	if !ok {
+		return cache.ErrNotSupported
+	}
+	if !s.c.IsWarm(key) {
+		return cache.ErrKeyNotFound
+	}
+	return a.Append(key, data)
+}
+
+// prepend -
+// Prepends data to the value of an existing key, retrying when the value changes underneath
+func (s *Server) prepend(key string, data []byte) error {
+	vp, ok := s.c.(cache.VersionedPutter)
+	if !ok {
+		return cache.ErrNotSupported
+	}
+	for {
+		val, version, err := vp.GetVersion(key)
+		if err != nil {
+			return cache.ErrKeyNotFound
+		}
+		err = vp.PutIfVersion(key, append(append([]byte(nil), data...), val...), version)
+		if !errors.Is(err, cache.ErrVersionMismatch) {
+			return err
+		}
+	}
+}
+
+// cas -
+// Saves the value only if the key is still at the cas unique read by gets
+func (s *Server) cas(key string, val []byte, unique string) error {
+	vp, ok := s.c.(cache.VersionedPutter)
+	if !ok {
+		return cache.ErrNotSupported
+	}
+	if _, err := strconv.ParseUint(unique, 10, 64); err != nil {
+		return errors.New("invalid cas unique")
+	}
+	if !s.c.IsWarm(key) {
+		return cache.ErrKeyNotFound
+	}
+	return vp.PutIfVersion(key, val, cache.Version(unique))
+}
+
+// incr -
+// Adds delta to or subtracts it from the counter of key, decrements stop at 0 as in memcached
+func (s *Server) incr(key string, delta int64, decr bool) string {
+	c, ok := s.c.(counter)
+	if !ok {
+		return "SERVER_ERROR cache does not support counters"
+	}
+	if !s.c.IsWarm(key) {
+		return "NOT_FOUND"
+	}
+	var (
+		n   int64
+		err error
+	)
+	if decr {
+		n, err = c.Decrement(key, delta)
+		if err == nil && n < 0 {
+			n, err = c.Increment(key, -n)
+		}
+	} else {
+		n, err = c.Increment(key, delta)
+	}
+	if err != nil {
+		return "CLIENT_ERROR cannot increment or decrement non-numeric value"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// touch -
+// Updates the lifetime of an existing key
+func (s *Server) touch(key string, exptime int64) string {
+	t, ok := s.c.(cache.Toucher)
+	if !ok {
+		return "SERVER_ERROR cache does not support touch"
+	}
+	if !s.c.IsWarm(key) {
+		return "NOT_FOUND"
+	}
+	err := s.expire(key, exptime)
+	if exptime == 0 {
+		// a zero exptime resets the item to the cache window
+		err = t.Touch(key, 0)
+	}
+	if err != nil {
+		return "NOT_FOUND"
+	}
+	return "TOUCHED"
+}
+
+// expire -
+// Applies a memcached exptime to key: 0 keeps the cache window, up to 30 days counts seconds from
+// now, anything larger is a unix timestamp and an exptime in the past removes the item
+func (s *Server) expire(key string, exptime int64) error {
+	if exptime == 0 {
+		return nil
+	}
+	ttl := time.Duration(exptime) * time.Second
+	if exptime > relativeLimit {
+		ttl = time.Unix(exptime, 0).Sub(s.clock.Now())
+	}
+	if ttl <= 0 {
+		return s.c.Delete(key)
+	}
+	t, ok := s.c.(cache.Toucher)
+	if !ok {
+		return cache.ErrNotSupported
+	}
+	return t.Touch(key, ttl)
+}
+
+// stats -
+// Writes the general statistics of the cache
+func (s *Server) stats(w *bufio.Writer) {
+	stat := func(name string, val any) {
+		w.WriteString("STAT " + name + " ")
+		switch v := val.(type) {
+		case string:
+			w.WriteString(v)
+		case int64:
+			w.WriteString(strconv.FormatInt(v, 10))
+		case uint64:
+			w.WriteString(strconv.FormatUint(v, 10))
+		}
+		w.WriteString("\r\n")
+	}
+	stat("pid", int64(os.Getpid()))
+	stat("time", s.clock.Now().Unix())
+	stat("version", version)
+	if l, ok := s.c.(lener); ok {
+		stat("curr_items", int64(l.Len()))
+	}
+	if r, ok := s.c.(cache.StatsReporter); ok {
+		st := r.Stats()
+		stat("cmd_get", st.Hits+st.Misses)
+		stat("get_hits", st.Hits)
+		stat("get_misses", st.Misses)
+		stat("cmd_set", st.Puts)
+		stat("delete_hits", st.Deletes)
+		stat("evictions", st.Evictions)
+	}
+	w.WriteString("END\r\n")
+}
+
+// parseKey -
+// Validates that args hold at least n arguments, optionally followed by noreply, and returns the
+// key heading them
+func parseKey(args []string, n int) (string, bool, bool) {
+	noreply := len(args) == n+1 && args[n] == "noreply"
+	if len(args) != n && !noreply {
+		return "", false, false
+	}
+	key := args[0]
+	if len(key) > maxKeyLen || strings.IndexFunc(key, func(r rune) bool { return r < 0x21 || r == 0x7f }) >= 0 {
+		return "", false, false
+	}
+	return key, noreply, true
+}
+
+// arg -
+// Returns the argument at i, or an empty string when missing
+func arg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+// writeReply -
+// Writes a single line reply unless the client asked for noreply
+func writeReply(w *bufio.Writer, reply string, noreply bool) {
+	if !noreply {
+		w.WriteString(reply + "\r\n")
+	}
+}
+
+// writeValue -
+// Writes a VALUE block, flags are always 0
+func writeValue(w *bufio.Writer, key string, val []byte, cas string) {
+	w.WriteString("VALUE " + key + " 0 " + strconv.Itoa(len(val)))
+	if cas != "" {
+		w.WriteString(" " + cas)
+	}
+	w.WriteString("\r\n")
+	w.Write(val)
+	w.WriteString("\r\n")
+}
@@ -0,0 +1,172 @@
+// Package cachemetrics exports Prometheus metrics for any cache.Cache implementation.
+package cachemetrics
+
+import (
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "go_cache"
+
+// lener is implemented by caches which can report their entry count.
+type lener interface {
+	Len() int
+}
+
+// Collector wraps a cache, recording the latency of every operation, and implements
+// prometheus.Collector exporting hit ratio, entry count, latency and cleaner statistics.
+// Every metric carries a "cache" label holding the name of the instance.
+type Collector struct {
+	cache.Wrapper
+	name    string
+	stats   cache.Counters // used when c does not report its own statistics
+	latency *prometheus.HistogramVec
+
+	hits         *prometheus.Desc
+	misses       *prometheus.Desc
+	hitRatio     *prometheus.Desc
+	entries      *prometheus.Desc
+	puts         *prometheus.Desc
+	deletes      *prometheus.Desc
+	evictions    *prometheus.Desc
+	staleFlushes *prometheus.Desc
+}
+
+// Instrument -
+// Wraps c in a Collector labelled with name, use the returned Collector in place of c
+// and register it with a prometheus.Registerer
+func Instrument(c cache.Cache, name string) *Collector {
+	labels := prometheus.Labels{"cache": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", metric), help, nil, labels)
+	}
+	return &Collector{
+		Wrapper: cache.Wrapper{Cache: c},
+		name:    name,
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "operation_duration_seconds",
+			Help:        "Latency of cache operations.",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(0.00001, 4, 10),
+		}, []string{"operation"}),
+		hits:         desc("hits_total", "Number of Get calls which found a value."),
+		misses:       desc("misses_total", "Number of Get calls which found no value."),
+		hitRatio:     desc("hit_ratio", "Fraction of Get calls which found a value."),
+		entries:      desc("entries", "Number of entries held by the cache."),
+		puts:         desc("puts_total", "Number of values written."),
+		deletes:      desc("deletes_total", "Number of values deleted."),
+		evictions:    desc("evictions_total", "Number of values removed by the cache itself."),
+		staleFlushes: desc("cleaner_runs_total", "Number of stale flushes run by the cleaner or explicitly."),
+	}
+}
+
+// Describe -
+// Sends the descriptors of every exported metric
+func (m *Collector) Describe(ch chan<- *prometheus.Desc) {
+	m.latency.Describe(ch)
+	ch <- m.hits
+	ch <- m.misses
+	ch <- m.hitRatio
+	ch <- m.puts
+	ch <- m.deletes
+	ch <- m.evictions
+	ch <- m.staleFlushes
+	if _, ok := m.Cache.(lener); ok {
+		ch <- m.entries
+	}
+}
+
+// Collect -
+// Sends the current value of every exported metric
+func (m *Collector) Collect(ch chan<- prometheus.Metric) {
+	m.latency.Collect(ch)
+	s := m.snapshot()
+	counter := func(d *prometheus.Desc, v uint64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, float64(v))
+	}
+	counter(m.hits, s.Hits)
+	counter(m.misses, s.Misses)
+	counter(m.puts, s.Puts)
+	counter(m.deletes, s.Deletes)
+	counter(m.evictions, s.Evictions)
+	counter(m.staleFlushes, s.StaleFlushes)
+	ch <- prometheus.MustNewConstMetric(m.hitRatio, prometheus.GaugeValue, s.HitRatio())
+	if l, ok := m.Cache.(lener); ok {
+		ch <- prometheus.MustNewConstMetric(m.entries, prometheus.GaugeValue, float64(l.Len()))
+	}
+}
+
+// snapshot -
+// Returns the statistics of the wrapped cache, falling back to the ones recorded by the Collector
+func (m *Collector) snapshot() cache.Stats {
+	if r, ok := m.Cache.(cache.StatsReporter); ok {
+		return r.Stats()
+	}
+	return m.stats.Snapshot()
+}
+
+// observe -
+// Records the time elapsed since start against op
+func (m *Collector) observe(op string, start time.Time) {
+	m.latency.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+// Put -
+// Saves the value inside the wrapped cache
+func (m *Collector) Put(key string, val []byte) error {
+	defer m.observe("put", time.Now())
+	err := m.Cache.Put(key, val)
+	if err == nil {
+		m.stats.Puts.Add(1)
+	}
+	return err
+}
+
+// Get -
+// Fetches the value from the wrapped cache
+func (m *Collector) Get(key string) ([]byte, error) {
+	defer m.observe("get", time.Now())
+	val, err := m.Cache.Get(key)
+	if err != nil {
+		m.stats.Misses.Add(1)
+	} else {
+		m.stats.Hits.Add(1)
+	}
+	return val, err
+}
+
+// Delete -
+// Deletes the value from the wrapped cache
+func (m *Collector) Delete(key string) error {
+	defer m.observe("delete", time.Now())
+	err := m.Cache.Delete(key)
+	if err == nil {
+		m.stats.Deletes.Add(1)
+	}
+	return err
+}
+
+// IsWarm -
+// Determines if the wrapped cache holds a value for key
+func (m *Collector) IsWarm(key string) bool {
+	defer m.observe("is_warm", time.Now())
+	return m.Cache.IsWarm(key)
+}
+
+// Flush -
+// Empties the wrapped cache
+func (m *Collector) Flush() error {
+	defer m.observe("flush", time.Now())
+	return m.Cache.Flush()
+}
+
+// FlushStale -
+// Flushes the stale items of the wrapped cache
+func (m *Collector) FlushStale() error {
+	defer m.observe("flush_stale", time.Now())
+	m.stats.StaleFlushes.Add(1)
+	return m.Cache.FlushStale()
+}
@@ -0,0 +1,234 @@
+// Package cacherefresh reloads popular cache items shortly before they expire, so that
+// latency sensitive callers never pay for a miss on a hot key.
+package cacherefresh
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+const (
+	defaultLead        = time.Second * 5
+	defaultIdle        = time.Minute
+	defaultConcurrency = 4
+)
+
+// warmther is implemented by caches reporting the time left before an item expires
+type warmther interface {
+	Warmth(key string) (time.Duration, bool)
+}
+
+// Refresher reads through a cache, loading missing items, and tracks the keys it is asked for.
+// While running it periodically reloads every key read within the idle period whose remaining
+// lifetime dropped under the lead time
+type Refresher struct {
+	c           cache.Cache
+	load        func(key string) ([]byte, error)
+	lead        time.Duration
+	interval    time.Duration
+	idle        time.Duration
+	concurrency int
+	logger      cache.Logger
+
+	mutex sync.Mutex
+	hot   map[string]time.Time // key -> last read
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+type Option func(*Refresher)
+
+// New -
+// Initialises a new Refresher on top of c, load is called for missing items and to refresh
+// hot items ahead of expiry. Refreshing requires c to report the remaining lifetime of its
+// items, as both the memory and redis caches do
+func New(c cache.Cache, load func(key string) ([]byte, error), opts ...Option) *Refresher {
+	r := &Refresher{
+		c:           c,
+		load:        load,
+		lead:        defaultLead,
+		idle:        defaultIdle,
+		concurrency: defaultConcurrency,
+		logger:      cache.NopLogger{},
+		hot:         map[string]time.Time{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.interval <= 0 {
+		r.interval = r.lead / 2
+	}
+	if r.interval <= 0 { // a lead of a single nanosecond has no half
+		r.interval = r.lead
+	}
+	return r
+}
+
+// Lead -
+// Functional option to specify how long before expiry hot items are reloaded, defaults to 5 seconds.
+// Non-positive durations are ignored
+func Lead(d time.Duration) Option {
+	return func(r *Refresher) {
+		if d > 0 {
+			r.lead = d
+		}
+	}
+}
+
+// Interval -
+// Functional option to specify how often the hot keys are checked, defaults to half the lead time.
+// Non-positive durations are ignored
+func Interval(d time.Duration) Option {
+	return func(r *Refresher) {
+		if d > 0 {
+			r.interval = d
+		}
+	}
+}
+
+// Idle -
+// Functional option to specify for how long a key stays hot after it was last read, defaults to a minute
+func Idle(d time.Duration) Option {
+	return func(r *Refresher) {
+		r.idle = d
+	}
+}
+
+// Concurrency -
+// Functional option to specify how many loads may run at the same time during a refresh, defaults to 4
+func Concurrency(n int) Option {
+	return func(r *Refresher) {
+		if n > 0 {
+			r.concurrency = n
+		}
+	}
+}
+
+// Logger -
+// Functional option to specify the logger receiving failed refreshes
+func Logger(l cache.Logger) Option {
+	return func(r *Refresher) {
+		r.logger = l
+	}
+}
+
+// Get -
+// Fetches the value of key, loading and storing it on a miss, and marks key as hot
+func (r *Refresher) Get(key string) ([]byte, error) {
+	r.mutex.Lock()
+	r.hot[key] = time.Now()
+	r.mutex.Unlock()
+	if val, err := r.c.Get(key); err == nil {
+		return val, nil
+	}
+	return r.reload(key)
+}
+
+// Run -
+// Starts refreshing hot keys in a separate go routine until ctx is done or the refresher is closed,
+// calls made while it is refreshing do nothing. Returns cache.ErrNotSupported when the cache cannot report the remaining lifetime of its items
+func (r *Refresher) Run(ctx context.Context) error {
+	w, ok := r.c.(warmther)
+	if !ok {
+		return cache.ErrNotSupported
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.stop != nil {
+		select {
+		case <-r.done: // stopped by its context, start over
+		default:
+			return nil
+		}
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.run(ctx, w, r.stop, r.done)
+	return nil
+}
+
+// Close -
+// Stops refreshing and waits for the running refresh to finish
+func (r *Refresher) Close() error {
+	r.mutex.Lock()
+	stop, done := r.stop, r.done
+	r.stop, r.done = nil, nil
+	r.mutex.Unlock()
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	<-done
+	return nil
+}
+
+// run -
+// Refreshes the hot keys every interval until stop is closed or ctx is done
+func (r *Refresher) run(ctx context.Context, w warmther, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.refresh(w)
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// refresh -
+// Reloads every hot key expiring within the lead time, forgetting the keys which went idle
+func (r *Refresher) refresh(w warmther) {
+	now := time.Now()
+	var keys []string
+	r.mutex.Lock()
+	for key, last := range r.hot {
+		if now.Sub(last) > r.idle {
+			delete(r.hot, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	r.mutex.Unlock()
+
+	sem := make(chan struct{}, r.concurrency)
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		ttl, ok := w.Warmth(key)
+		if ok && (ttl == 0 || ttl > r.lead) { // a zero ttl never expires
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(key string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if _, err := r.reload(key); err != nil {
+				r.logger.Error("cache refresh failed", "key", key, "error", err)
+			}
+		}(key)
+	}
+	wg.Wait()
+}
+
+// reload -
+// Loads the value of key and stores it inside the cache
+func (r *Refresher) reload(key string) ([]byte, error) {
+	val, err := r.load(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.c.Put(key, val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
@@ -0,0 +1,254 @@
+// Package cacheresp serves a cache over a subset of the Redis protocol (RESP), so redis-cli and
+// existing redis clients can talk to an embedded MemCache during local development.
+package cacheresp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+// maxBulkLen bounds the size of a single argument
+const maxBulkLen = 512 << 20
+
+// maxMultiBulkLen bounds the number of arguments of a command
+const maxMultiBulkLen = 1024 * 1024
+
+// maxLineLen bounds the length of inline commands and of the length headers, as redis does
+const maxLineLen = 64 << 10
+
+// Server accepts RESP connections and runs their commands against a cache. Supported commands are
+// PING, ECHO, GET, SET (with EX, PX, NX and XX), SETNX, DEL, EXISTS, TTL, PTTL, EXPIRE, PERSIST, SCAN
+// (with MATCH and COUNT), KEYS, DBSIZE, FLUSHDB, FLUSHALL, INFO, SELECT 0, COMMAND and QUIT.
+// Keys saved without EX or PX live for the window of the cache rather than forever.
+type Server struct {
+	c      cache.Cache
+	logger cache.Logger
+
+	mutex     sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+type Option func(*Server)
+
+// New -
+// Initialises a new Server on top of c, typically a MemCache. TTL, EXPIRE, PERSIST, SCAN, SET NX
+// and SET XX require the matching capabilities of the cache, as MemCache provides
+func New(c cache.Cache, opts ...Option) *Server {
+	s := &Server{
+		c:         c,
+		logger:    cache.NopLogger{},
+		listeners: map[net.Listener]struct{}{},
+		conns:     map[net.Conn]struct{}{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Logger -
+// Functional option to log connection failures
+func Logger(l cache.Logger) Option {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// ListenAndServe -
+// Listens on the TCP address addr, e.g. "localhost:6379", and serves connections until Close
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve -
+// Serves the connections accepted by l until Close, always returning a non-nil error
+func (s *Server) Serve(l net.Listener) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		l.Close()
+		return net.ErrClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mutex.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mutex.Lock()
+			closed := s.closed
+			s.mutex.Unlock()
+			if closed {
+				return net.ErrClosed
+			}
+			return err
+		}
+		s.mutex.Lock()
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mutex.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close -
+// Stops the listeners, closes every connection and waits for them to finish. The cache is left open
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// serveConn -
+// Reads commands from conn and writes their replies until the client quits or fails
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		s.mutex.Unlock()
+		s.wg.Done()
+	}()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.logger.Error("resp connection failed", "remote", conn.RemoteAddr().String(), "error", err)
+				writeError(w, "ERR Protocol error: "+err.Error())
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := s.exec(w, args)
+		// replies of pipelined commands are flushed together
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// readCommand -
+// Reads a command sent as a RESP array of bulk strings, or as an inline command
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 || n > maxMultiBulkLen {
+		return nil, fmt.Errorf("invalid multibulk length")
+	}
+	var args []string // grown as arguments arrive, the count is not trusted
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("expected '$', got '%.1s'", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, fmt.Errorf("invalid bulk length")
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+// readLine -
+// Reads a line terminated by CRLF or LF, without its terminator, refusing lines longer than maxLineLen
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxLineLen {
+			return "", fmt.Errorf("too big inline request")
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(line), "\r\n"), nil
+	}
+}
+
+// writeSimple -
+// Writes a simple string reply
+func writeSimple(w *bufio.Writer, s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+// writeError -
+// Writes an error reply
+func writeError(w *bufio.Writer, msg string) {
+	w.WriteString("-" + msg + "\r\n")
+}
+
+// writeInt -
+// Writes an integer reply
+func writeInt(w *bufio.Writer, n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+// writeBulk -
+// Writes a bulk string reply
+func writeBulk(w *bufio.Writer, b []byte) {
+	w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+// writeNull -
+// Writes the null bulk string reply
+func writeNull(w *bufio.Writer) {
+	w.WriteString("$-1\r\n")
+}
+
+// writeArrayLen -
+// Writes the header of an array reply of n elements
+func writeArrayLen(w *bufio.Writer, n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
@@ -0,0 +1,402 @@
+package cacheresp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+// warmther is implemented by caches reporting the time left before an item goes stale
+type warmther interface {
+	Warmth(key string) (time.Duration, bool)
+}
+
+// persister is implemented by caches able to pin an item
+type persister interface {
+	Persist(key string) error
+}
+
+// adder is implemented by caches saving a value only when the key is missing
+type adder interface {
+	Add(key string, val []byte) error
+}
+
+// replacer is implemented by caches saving a value only when the key exists
+type replacer interface {
+	Replace(key string, val []byte) error
+}
+
+// lener is implemented by caches reporting their entry count
+type lener interface {
+	Len() int
+}
+
+// exec -
+// Runs a command and writes its reply, reporting whether the client quits
+func (s *Server) exec(w *bufio.Writer, args []string) bool {
+	name := strings.ToUpper(args[0])
+	args = args[1:]
+	switch name {
+	case "PING":
+		if len(args) > 0 {
+			writeBulk(w, []byte(args[0]))
+		} else {
+			writeSimple(w, "PONG")
+		}
+	case "ECHO":
+		if !arity(w, name, args, 1, 1) {
+			return false
+		}
+		writeBulk(w, []byte(args[0]))
+	case "QUIT":
+		writeSimple(w, "OK")
+		return true
+	case "SELECT":
+		if !arity(w, name, args, 1, 1) {
+			return false
+		}
+		if args[0] != "0" {
+			writeError(w, "ERR DB index is out of range")
+			return false
+		}
+		writeSimple(w, "OK")
+	case "COMMAND":
+		writeArrayLen(w, 0)
+	case "GET":
+		if !arity(w, name, args, 1, 1) {
+			return false
+		}
+		val, err := s.c.Get(args[0])
+		if err != nil {
+			writeNull(w)
+			return false
+		}
+		writeBulk(w, val)
+	case "SET":
+		s.set(w, args)
+	case "SETNX":
+		if !arity(w, name, args, 2, 2) {
+			return false
+		}
+		a, ok := s.c.(adder)
+		if !ok {
+			writeError(w, "ERR cache does not support NX")
+			return false
+		}
+		err := a.Add(args[0], []byte(args[1]))
+		if errors.Is(err, cache.ErrKeyExists) {
+			writeInt(w, 0)
+			return false
+		}
+		if err != nil {
+			writeError(w, "ERR "+err.Error())
+			return false
+		}
+		writeInt(w, 1)
+	case "DEL", "UNLINK":
+		if !arity(w, name, args, 1, -1) {
+			return false
+		}
+		var n int64
+		for _, key := range args {
+			if !s.c.IsWarm(key) {
+				s.c.Delete(key) // drops a stale item, missing keys are not an error
+				continue
+			}
+			if err := s.c.Delete(key); err != nil {
+				writeError(w, "ERR "+err.Error())
+				return false
+			}
+			n++
+		}
+		writeInt(w, n)
+	case "EXISTS":
+		if !arity(w, name, args, 1, -1) {
+			return false
+		}
+		var n int64
+		for _, key := range args {
+			if s.c.IsWarm(key) {
+				n++
+			}
+		}
+		writeInt(w, n)
+	case "TTL", "PTTL":
+		if !arity(w, name, args, 1, 1) {
+			return false
+		}
+		wt, ok := s.c.(warmther)
+		if !ok {
+			writeError(w, "ERR cache does not report lifetimes")
+			return false
+		}
+		d, ok := wt.Warmth(args[0])
+		switch {
+		case !ok:
+			writeInt(w, -2)
+		case d == 0:
+			writeInt(w, -1)
+		case name == "TTL":
+			writeInt(w, int64((d+time.Second-1)/time.Second))
+		default:
+			writeInt(w, d.Milliseconds())
+		}
+	case "EXPIRE", "PEXPIRE":
+		if !arity(w, name, args, 2, 2) {
+			return false
+		}
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			writeError(w, "ERR value is not an integer or out of range")
+			return false
+		}
+		unit := time.Second
+		if name == "PEXPIRE" {
+			unit = time.Millisecond
+		}
+		t, ok := s.c.(cache.Toucher)
+		if !ok {
+			writeError(w, "ERR cache cannot set lifetimes")
+			return false
+		}
+		if !s.c.IsWarm(args[0]) || t.Touch(args[0], time.Duration(n)*unit) != nil {
+			writeInt(w, 0)
+			return false
+		}
+		writeInt(w, 1)
+	case "PERSIST":
+		if !arity(w, name, args, 1, 1) {
+			return false
+		}
+		p, ok := s.c.(persister)
+		if !ok {
+			writeError(w, "ERR cache cannot pin items")
+			return false
+		}
+		if !s.c.IsWarm(args[0]) || p.Persist(args[0]) != nil {
+			writeInt(w, 0)
+			return false
+		}
+		writeInt(w, 1)
+	case "SCAN":
+		s.scan(w, args)
+	case "KEYS":
+		if !arity(w, name, args, 1, 1) {
+			return false
+		}
+		keys, err := s.keys(args[0])
+		if err != nil {
+			writeError(w, "ERR "+err.Error())
+			return false
+		}
+		writeArrayLen(w, len(keys))
+		for _, key := range keys {
+			writeBulk(w, []byte(key))
+		}
+	case "DBSIZE":
+		l, ok := s.c.(lener)
+		if !ok {
+			writeError(w, "ERR cache does not report its size")
+			return false
+		}
+		writeInt(w, int64(l.Len()))
+	case "FLUSHDB", "FLUSHALL":
+		if err := s.c.Flush(); err != nil {
+			writeError(w, "ERR "+err.Error())
+			return false
+		}
+		writeSimple(w, "OK")
+	case "INFO":
+		s.info(w)
+	default:
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", strings.ToLower(name)))
+	}
+	return false
+}
+
+// arity -
+// Checks the argument count of a command, writing the error reply when it is wrong. A negative
+// max allows any number of arguments
+func arity(w *bufio.Writer, name string, args []string, min, max int) bool {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		writeError(w, fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return false
+	}
+	return true
+}
+
+// set -
+// Runs SET key value [EX seconds|PX milliseconds] [NX|XX]
+func (s *Server) set(w *bufio.Writer, args []string) {
+	if !arity(w, "SET", args, 2, -1) {
+		return
+	}
+	key, val := args[0], []byte(args[1])
+	var (
+		ttl    time.Duration
+		nx, xx bool
+	)
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); opt {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if i+1 == len(args) {
+				writeError(w, "ERR syntax error")
+				return
+			}
+			i++
+			n, err := strconv.ParseInt(args[i], 10, 64)
+			if err != nil || n <= 0 {
+				writeError(w, "ERR invalid expire time in 'set' command")
+				return
+			}
+			ttl = time.Duration(n) * time.Second
+			if opt == "PX" {
+				ttl = time.Duration(n) * time.Millisecond
+			}
+		default:
+			writeError(w, "ERR syntax error")
+			return
+		}
+	}
+	if nx && xx {
+		writeError(w, "ERR syntax error")
+		return
+	}
+	var err error
+	switch {
+	case nx:
+		a, ok := s.c.(adder)
+		if !ok {
+			writeError(w, "ERR cache does not support NX")
+			return
+		}
+		err = a.Add(key, val)
+	case xx:
+		r, ok := s.c.(replacer)
+		if !ok {
+			writeError(w, "ERR cache does not support XX")
+			return
+		}
+		err = r.Replace(key, val)
+	default:
+		err = s.c.Put(key, val)
+	}
+	if errors.Is(err, cache.ErrKeyExists) || errors.Is(err, cache.ErrKeyNotFound) {
+		writeNull(w)
+		return
+	}
+	if err != nil {
+		writeError(w, "ERR "+err.Error())
+		return
+	}
+	if ttl > 0 {
+		t, ok := s.c.(cache.Toucher)
+		if !ok {
+			writeError(w, "ERR cache cannot set lifetimes")
+			return
+		}
+		if err := t.Touch(key, ttl); err != nil {
+			writeError(w, "ERR "+err.Error())
+			return
+		}
+	}
+	writeSimple(w, "OK")
+}
+
+// scan -
+// Runs SCAN cursor [MATCH pattern] [COUNT count]. The cursor is the offset into the sorted keys
+// matching the pattern, so keys written during an iteration may be missed or returned twice
+func (s *Server) scan(w *bufio.Writer, args []string) {
+	if !arity(w, "SCAN", args, 1, -1) {
+		return
+	}
+	cursor, err := strconv.Atoi(args[0])
+	if err != nil || cursor < 0 {
+		writeError(w, "ERR invalid cursor")
+		return
+	}
+	pattern, count := "*", 10
+	for i := 1; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			writeError(w, "ERR syntax error")
+			return
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count <= 0 {
+				writeError(w, "ERR value is not an integer or out of range")
+				return
+			}
+		default:
+			writeError(w, "ERR syntax error")
+			return
+		}
+	}
+	keys, err := s.keys(pattern)
+	if err != nil {
+		writeError(w, "ERR "+err.Error())
+		return
+	}
+	if cursor > len(keys) {
+		cursor = len(keys)
+	}
+	end, next := cursor+count, cursor+count
+	if end >= len(keys) {
+		end, next = len(keys), 0
+	}
+	writeArrayLen(w, 2)
+	writeBulk(w, []byte(strconv.Itoa(next)))
+	writeArrayLen(w, end-cursor)
+	for _, key := range keys[cursor:end] {
+		writeBulk(w, []byte(key))
+	}
+}
+
+// keys -
+// Returns the sorted keys matching the redis style glob pattern
+func (s *Server) keys(pattern string) ([]string, error) {
+	sc, ok := s.c.(cache.Scanner)
+	if !ok {
+		return nil, errors.New("cache cannot list its keys")
+	}
+	var keys []string
+	err := sc.Scan(context.Background(), func(key string) error {
+		if cache.Match(pattern, key) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// info -
+// Writes the INFO reply holding the server and statistics sections
+func (s *Server) info(w *bufio.Writer) {
+	var b strings.Builder
+	b.WriteString("# Server\r\nredis_version:7.0.0\r\nredis_mode:standalone\r\nserver_name:go-cache\r\n")
+	if r, ok := s.c.(cache.StatsReporter); ok {
+		stats := r.Stats()
+		fmt.Fprintf(&b, "\r\n# Stats\r\nkeyspace_hits:%d\r\nkeyspace_misses:%d\r\nevicted_keys:%d\r\n", stats.Hits, stats.Misses, stats.Evictions)
+	}
+	if l, ok := s.c.(lener); ok {
+		fmt.Fprintf(&b, "\r\n# Keyspace\r\ndb0:keys=%d\r\n", l.Len())
+	}
+	writeBulk(w, []byte(b.String()))
+}
@@ -0,0 +1,282 @@
+// Package cacheserver exposes any cache.Cache over gRPC, so services written in other languages and
+// sidecars can share a cache instance, and provides a Go client implementing cache.Cache. The
+// service is described by cachepb/cache.proto.
+package cacheserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+	"github.com/pedreviljoen/go-cache/cacheserver/cachepb"
+	"github.com/pedreviljoen/go-cache/redis"
+	goredis "github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultTimeout = time.Second * 5
+
+// Server implements the gocache.v1.Cache gRPC service on top of a cache.
+type Server struct {
+	cachepb.UnimplementedCacheServer
+	c cache.Cache
+}
+
+// NewServer -
+// Initialises a new Server exposing c
+func NewServer(c cache.Cache) *Server {
+	return &Server{c: c}
+}
+
+// Register -
+// Registers a Server exposing c with the gRPC server s
+func Register(s *grpc.Server, c cache.Cache) {
+	cachepb.RegisterCacheServer(s, NewServer(c))
+}
+
+// statusError -
+// Converts an error of the cache to a gRPC status: a missing key is reported with the NotFound
+// code, a timeout or a transient backend failure with Unavailable and any other failure with Internal
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, cache.ErrKeyNotFound) || errors.Is(err, goredis.Nil):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, context.DeadlineExceeded) || redis.IsTransient(err):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// Get -
+// Returns the value of the key, a miss is reported with the NotFound code
+func (s *Server) Get(ctx context.Context, req *cachepb.GetRequest) (*cachepb.GetResponse, error) {
+	val, err := s.c.Get(req.GetKey())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &cachepb.GetResponse{Value: val}, nil
+}
+
+// Put -
+// Saves the value of the key
+func (s *Server) Put(ctx context.Context, req *cachepb.PutRequest) (*cachepb.PutResponse, error) {
+	if err := s.c.Put(req.GetKey(), req.GetValue()); err != nil {
+		return nil, statusError(err)
+	}
+	return &cachepb.PutResponse{}, nil
+}
+
+// Delete -
+// Deletes the value of the key, a missing key is reported with the NotFound code
+func (s *Server) Delete(ctx context.Context, req *cachepb.DeleteRequest) (*cachepb.DeleteResponse, error) {
+	if err := s.c.Delete(req.GetKey()); err != nil {
+		return nil, statusError(err)
+	}
+	return &cachepb.DeleteResponse{}, nil
+}
+
+// IsWarm -
+// Reports whether the key holds a value inside the time window
+func (s *Server) IsWarm(ctx context.Context, req *cachepb.IsWarmRequest) (*cachepb.IsWarmResponse, error) {
+	return &cachepb.IsWarmResponse{Warm: s.c.IsWarm(req.GetKey())}, nil
+}
+
+// Flush -
+// Empties the cache
+func (s *Server) Flush(ctx context.Context, req *cachepb.FlushRequest) (*cachepb.FlushResponse, error) {
+	if err := s.c.Flush(); err != nil {
+		return nil, statusError(err)
+	}
+	return &cachepb.FlushResponse{}, nil
+}
+
+// FlushStale -
+// Flushes the stale items of the cache
+func (s *Server) FlushStale(ctx context.Context, req *cachepb.FlushStaleRequest) (*cachepb.FlushStaleResponse, error) {
+	if err := s.c.FlushStale(); err != nil {
+		return nil, statusError(err)
+	}
+	return &cachepb.FlushStaleResponse{}, nil
+}
+
+// Stats -
+// Returns the operation counters of the cache, or the Unimplemented code when it keeps none
+func (s *Server) Stats(ctx context.Context, req *cachepb.StatsRequest) (*cachepb.StatsResponse, error) {
+	r, ok := s.c.(cache.StatsReporter)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "cache does not report statistics")
+	}
+	stats := r.Stats()
+	return &cachepb.StatsResponse{
+		Hits:         stats.Hits,
+		Misses:       stats.Misses,
+		Puts:         stats.Puts,
+		Deletes:      stats.Deletes,
+		Evictions:    stats.Evictions,
+		StaleFlushes: stats.StaleFlushes,
+	}, nil
+}
+
+// Client is a cache.Cache backed by a remote Server.
+type Client struct {
+	rpc     cachepb.CacheClient
+	conn    *grpc.ClientConn // closed by Close when the client dialed it
+	timeout time.Duration
+	stats   cache.Counters
+}
+
+type Option func(*Client)
+
+// Timeout -
+// Functional option to specify the deadline of every call, defaults to 5 seconds
+func Timeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// NewClient -
+// Initialises a new Client calling the server through conn, which the caller keeps ownership of
+func NewClient(conn grpc.ClientConnInterface, opts ...Option) *Client {
+	c := &Client{
+		rpc:     cachepb.NewCacheClient(conn),
+		timeout: defaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Dial -
+// Connects to the server at target and returns a Client owning the connection, closed by Close
+func Dial(target string, dialOpts []grpc.DialOption, opts ...Option) (*Client, error) {
+	conn, err := grpc.Dial(target, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	c := NewClient(conn, opts...)
+	c.conn = conn
+	return c, nil
+}
+
+// clientError -
+// Converts the NotFound code returned by the server back to an error wrapping cache.ErrKeyNotFound
+func clientError(key string, err error) error {
+	if status.Code(err) == codes.NotFound {
+		return fmt.Errorf("unable to find %s on the cache server: %w", key, cache.ErrKeyNotFound)
+	}
+	return err
+}
+
+// ctx -
+// Returns the context of a call, bounded by the timeout
+func (c *Client) ctx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), c.timeout)
+}
+
+// Put -
+// Saves the value on the server
+func (c *Client) Put(key string, val []byte) error {
+	ctx, cancel := c.ctx()
+	defer cancel()
+	if _, err := c.rpc.Put(ctx, &cachepb.PutRequest{Key: key, Value: val}); err != nil {
+		return err
+	}
+	c.stats.Puts.Add(1)
+	return nil
+}
+
+// Get -
+// Fetches the value from the server, a miss returns an error wrapping cache.ErrKeyNotFound
+func (c *Client) Get(key string) ([]byte, error) {
+	ctx, cancel := c.ctx()
+	defer cancel()
+	res, err := c.rpc.Get(ctx, &cachepb.GetRequest{Key: key})
+	if err != nil {
+		c.stats.Misses.Add(1)
+		return nil, clientError(key, err)
+	}
+	c.stats.Hits.Add(1)
+	return res.GetValue(), nil
+}
+
+// Delete -
+// Deletes the value from the server, a missing key returns an error wrapping cache.ErrKeyNotFound
+func (c *Client) Delete(key string) error {
+	ctx, cancel := c.ctx()
+	defer cancel()
+	if _, err := c.rpc.Delete(ctx, &cachepb.DeleteRequest{Key: key}); err != nil {
+		return clientError(key, err)
+	}
+	c.stats.Deletes.Add(1)
+	return nil
+}
+
+// IsWarm -
+// Determines if the key holds a value inside the time window of the server, failed calls report false
+func (c *Client) IsWarm(key string) bool {
+	ctx, cancel := c.ctx()
+	defer cancel()
+	res, err := c.rpc.IsWarm(ctx, &cachepb.IsWarmRequest{Key: key})
+	return err == nil && res.GetWarm()
+}
+
+// Flush -
+// Empties the cache of the server
+func (c *Client) Flush() error {
+	ctx, cancel := c.ctx()
+	defer cancel()
+	_, err := c.rpc.Flush(ctx, &cachepb.FlushRequest{})
+	return err
+}
+
+// FlushStale -
+// Flushes the stale items of the cache of the server
+func (c *Client) FlushStale() error {
+	ctx, cancel := c.ctx()
+	defer cancel()
+	_, err := c.rpc.FlushStale(ctx, &cachepb.FlushStaleRequest{})
+	return err
+}
+
+// RunCleaner -
+// Does nothing, the server is responsible for cleaning its cache
+func (c *Client) RunCleaner(ctx context.Context) {}
+
+// Close -
+// Closes the connection when the client dialed it
+func (c *Client) Close() error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
+
+// Stats -
+// Returns the counters of the calls made by this client, see ServerStats for those of the server
+func (c *Client) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// ServerStats -
+// Returns the operation counters of the cache of the server
+func (c *Client) ServerStats(ctx context.Context) (cache.Stats, error) {
+	res, err := c.rpc.Stats(ctx, &cachepb.StatsRequest{})
+	if err != nil {
+		return cache.Stats{}, err
+	}
+	return cache.Stats{
+		Hits:         res.GetHits(),
+		Misses:       res.GetMisses(),
+		Puts:         res.GetPuts(),
+		Deletes:      res.GetDeletes(),
+		Evictions:    res.GetEvictions(),
+		StaleFlushes: res.GetStaleFlushes(),
+	}, nil
+}
@@ -0,0 +1,219 @@
+// Package cacheshadow supports migrating between cache backends gradually: writes go to both the
+// current and the new backend while reads are served by the current one and compared against the
+// new one, so divergence is measured before reads are cut over.
+package cacheshadow
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+// Stats is a snapshot of the comparisons made by a Shadow
+type Stats struct {
+	Compared     uint64 // reads served by the primary which were compared with the shadow
+	Matches      uint64 // compared reads returning the same value from both caches
+	Mismatches   uint64 // compared reads returning different values
+	ShadowMisses uint64 // compared reads which the shadow could not serve
+	ShadowErrors uint64 // writes which failed on the shadow only
+}
+
+// MatchRatio -
+// Returns the fraction of compared reads for which both caches agreed, 0 when nothing was compared
+func (s Stats) MatchRatio() float64 {
+	if s.Compared == 0 {
+		return 0
+	}
+	return float64(s.Matches) / float64(s.Compared)
+}
+
+// Shadow is a cache.Cache writing to a primary and a shadow cache and reading from the primary only.
+// Failures of the shadow are counted and logged but never returned
+type Shadow struct {
+	primary cache.Cache
+	shadow  cache.Cache
+
+	compareRate  float64
+	onDivergence func(key string, primary, shadow []byte)
+	logger       cache.Logger
+
+	mutex sync.Mutex // guards rnd
+	rnd   *rand.Rand
+
+	compared     atomic.Uint64
+	matches      atomic.Uint64
+	mismatches   atomic.Uint64
+	shadowMisses atomic.Uint64
+	shadowErrors atomic.Uint64
+}
+
+type Option func(*Shadow)
+
+// New -
+// Wraps primary, which keeps serving reads, mirroring every write onto shadow
+func New(primary, shadow cache.Cache, opts ...Option) *Shadow {
+	s := &Shadow{
+		primary:     primary,
+		shadow:      shadow,
+		compareRate: 1,
+		logger:      cache.NopLogger{},
+		rnd:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// CompareRate -
+// Functional option to specify the fraction (0 to 1) of successful reads compared with the shadow,
+// defaults to every read. Comparing adds the latency of a shadow read to the caller
+func CompareRate(p float64) Option {
+	return func(s *Shadow) {
+		s.compareRate = p
+	}
+}
+
+// OnDivergence -
+// Functional option to specify a callback invoked for every compared read on which the caches
+// disagree, shadow is nil when the shadow could not serve the read
+func OnDivergence(fn func(key string, primary, shadow []byte)) Option {
+	return func(s *Shadow) {
+		s.onDivergence = fn
+	}
+}
+
+// Logger -
+// Functional option to specify the logger receiving failed shadow writes
+func Logger(l cache.Logger) Option {
+	return func(s *Shadow) {
+		s.logger = l
+	}
+}
+
+// Stats -
+// Returns a snapshot of the comparison counters
+func (s *Shadow) Stats() Stats {
+	return Stats{
+		Compared:     s.compared.Load(),
+		Matches:      s.matches.Load(),
+		Mismatches:   s.mismatches.Load(),
+		ShadowMisses: s.shadowMisses.Load(),
+		ShadowErrors: s.shadowErrors.Load(),
+	}
+}
+
+// mirror -
+// Records a failed write on the shadow
+func (s *Shadow) mirror(op cache.Op, key string, err error) {
+	if err == nil {
+		return
+	}
+	s.shadowErrors.Add(1)
+	s.logger.Error("shadow cache write failed", "op", op, "key", key, "error", err)
+}
+
+// compare -
+// Reports whether a read should be compared with the shadow
+func (s *Shadow) compare() bool {
+	if s.compareRate >= 1 {
+		return true
+	}
+	if s.compareRate <= 0 {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.rnd.Float64() < s.compareRate
+}
+
+// Put -
+// Saves the value inside both caches, only a failure of the primary is returned
+func (s *Shadow) Put(key string, val []byte) error {
+	if err := s.primary.Put(key, val); err != nil {
+		return err
+	}
+	s.mirror(cache.OpPut, key, s.shadow.Put(key, val))
+	return nil
+}
+
+// Get -
+// Fetches the value from the primary, comparing it with the value held by the shadow
+func (s *Shadow) Get(key string) ([]byte, error) {
+	val, err := s.primary.Get(key)
+	if err != nil || !s.compare() {
+		return val, err
+	}
+	s.compared.Add(1)
+	shadowVal, serr := s.shadow.Get(key)
+	switch {
+	case serr != nil:
+		s.shadowMisses.Add(1)
+		shadowVal = nil
+	case bytes.Equal(val, shadowVal):
+		s.matches.Add(1)
+		return val, nil
+	default:
+		s.mismatches.Add(1)
+	}
+	if s.onDivergence != nil {
+		s.onDivergence(key, val, shadowVal)
+	}
+	return val, nil
+}
+
+// Delete -
+// Deletes the value from both caches, only a failure of the primary is returned
+func (s *Shadow) Delete(key string) error {
+	err := s.primary.Delete(key)
+	s.shadow.Delete(key) // the shadow may never have seen the key
+	return err
+}
+
+// IsWarm -
+// Determines if the primary holds a value for key
+func (s *Shadow) IsWarm(key string) bool {
+	return s.primary.IsWarm(key)
+}
+
+// Flush -
+// Empties both caches, only a failure of the primary is returned
+func (s *Shadow) Flush() error {
+	if err := s.primary.Flush(); err != nil {
+		return err
+	}
+	s.mirror(cache.OpFlush, "", s.shadow.Flush())
+	return nil
+}
+
+// FlushStale -
+// Flushes the stale items of both caches, only a failure of the primary is returned
+func (s *Shadow) FlushStale() error {
+	if err := s.primary.FlushStale(); err != nil {
+		return err
+	}
+	s.mirror(cache.OpFlushStale, "", s.shadow.FlushStale())
+	return nil
+}
+
+// RunCleaner -
+// Runs the cleaners of both caches
+func (s *Shadow) RunCleaner(ctx context.Context) {
+	s.primary.RunCleaner(ctx)
+	s.shadow.RunCleaner(ctx)
+}
+
+// Close -
+// Closes both caches, returning the failure of the primary first
+func (s *Shadow) Close() error {
+	err := s.primary.Close()
+	if serr := s.shadow.Close(); err == nil {
+		err = serr
+	}
+	return err
+}
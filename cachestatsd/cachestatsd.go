@@ -0,0 +1,147 @@
+// Package cachestatsd periodically emits cache statistics to a StatsD or Datadog agent.
+package cachestatsd
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+const (
+	defaultPrefix   = "cache."
+	defaultInterval = time.Second * 10
+)
+
+// Emitter sends the counters of a cache to a StatsD endpoint over UDP on a fixed interval.
+// Counters are sent as deltas since the previous flush, tags use the DogStatsD format.
+type Emitter struct {
+	conn     net.Conn
+	source   cache.StatsReporter
+	prefix   string
+	tags     []string
+	interval time.Duration
+
+	mu   sync.Mutex
+	prev cache.Stats
+	stop chan struct{}
+	once sync.Once
+	run  sync.Once
+}
+
+type Option func(*Emitter)
+
+// New -
+// Initialises a new Emitter sending the statistics of source to the StatsD agent listening on addr
+func New(addr string, source cache.StatsReporter, opts ...Option) (*Emitter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	e := &Emitter{
+		conn:     conn,
+		source:   source,
+		prefix:   defaultPrefix,
+		interval: defaultInterval,
+		stop:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e, nil
+}
+
+// Prefix -
+// Functional option to specify the prefix of every metric name, defaults to "cache."
+func Prefix(p string) Option {
+	return func(e *Emitter) {
+		e.prefix = p
+	}
+}
+
+// Tags -
+// Functional option to attach tags, such as "cache:sessions", to every metric
+func Tags(tags ...string) Option {
+	return func(e *Emitter) {
+		e.tags = append(e.tags, tags...)
+	}
+}
+
+// Interval -
+// Functional option to specify how often the statistics are flushed, defaults to 10 seconds.
+// Non-positive durations are ignored
+func Interval(d time.Duration) Option {
+	return func(e *Emitter) {
+		if d > 0 {
+			e.interval = d
+		}
+	}
+}
+
+// Run -
+// Starts flushing the statistics in a separate go routine until Stop is called, later calls
+// do nothing
+func (e *Emitter) Run() {
+	e.run.Do(func() {
+		go func() {
+			ticker := time.NewTicker(e.interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					e.Flush()
+				case <-e.stop:
+					return
+				}
+			}
+		}()
+	})
+}
+
+// Stop -
+// Stops the flushing go routine and closes the connection, it is safe to call more than once
+func (e *Emitter) Stop() error {
+	var err error
+	e.once.Do(func() {
+		close(e.stop)
+		err = e.conn.Close()
+	})
+	return err
+}
+
+// Flush -
+// Sends the counter deltas since the previous flush and the current hit ratio in a single packet
+func (e *Emitter) Flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	cur := e.source.Stats()
+	prev := e.prev
+	e.prev = cur
+
+	var b strings.Builder
+	e.write(&b, "hits", cur.Hits-prev.Hits, "c")
+	e.write(&b, "misses", cur.Misses-prev.Misses, "c")
+	e.write(&b, "puts", cur.Puts-prev.Puts, "c")
+	e.write(&b, "deletes", cur.Deletes-prev.Deletes, "c")
+	e.write(&b, "evictions", cur.Evictions-prev.Evictions, "c")
+	e.write(&b, "stale_flushes", cur.StaleFlushes-prev.StaleFlushes, "c")
+	e.write(&b, "hit_ratio", cur.HitRatio(), "g")
+	_, err := e.conn.Write([]byte(b.String()))
+	return err
+}
+
+// write -
+// Appends a single metric line in the StatsD format
+func (e *Emitter) write(b *strings.Builder, name string, value any, kind string) {
+	if b.Len() > 0 {
+		b.WriteByte('\n')
+	}
+	fmt.Fprintf(b, "%s%s:%v|%s", e.prefix, name, value, kind)
+	if len(e.tags) > 0 {
+		b.WriteString("|#")
+		b.WriteString(strings.Join(e.tags, ","))
+	}
+}
@@ -0,0 +1,239 @@
+// Package cachetest provides a deterministic fake cache.Cache for use in tests.
+package cachetest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+// ErrNotFound is returned by Get when the fake holds no value for the key.
+var ErrNotFound = errors.New("cachetest: key not found")
+
+// Call records a single operation performed against a Fake.
+type Call struct {
+	Op    cache.Op
+	Key   string
+	Value []byte // value passed to Put
+}
+
+// Fake is an in-memory cache.Cache without any expiry which records every call and can
+// be programmed to fail, it is safe for concurrent use
+type Fake struct {
+	mutex sync.Mutex
+	data  map[string][]byte
+	calls []Call
+	errs  map[cache.Op]error
+	stats cache.Counters
+}
+
+// New -
+// Initialises a new, empty Fake
+func New() *Fake {
+	return &Fake{
+		data: map[string][]byte{},
+		errs: map[cache.Op]error{},
+	}
+}
+
+// SetError -
+// Makes every subsequent call of op fail with err, a nil err clears the failure
+func (f *Fake) SetError(op cache.Op, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err == nil {
+		delete(f.errs, op)
+		return
+	}
+	f.errs[op] = err
+}
+
+// Calls -
+// Returns every recorded call in order
+func (f *Fake) Calls() []Call {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// CallCount -
+// Returns how many times op was called
+func (f *Fake) CallCount(op cache.Op) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	n := 0
+	for _, c := range f.calls {
+		if c.Op == op {
+			n++
+		}
+	}
+	return n
+}
+
+// Reset -
+// Clears the stored values, recorded calls and programmed errors
+func (f *Fake) Reset() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.data = map[string][]byte{}
+	f.calls = nil
+	f.errs = map[cache.Op]error{}
+}
+
+// AssertCalled -
+// Fails the test unless op was called with key
+func (f *Fake) AssertCalled(t testing.TB, op cache.Op, key string) {
+	t.Helper()
+	if !f.called(op, key) {
+		t.Errorf("cachetest: expected %s(%q) to be called", op, key)
+	}
+}
+
+// AssertNotCalled -
+// Fails the test if op was called with key
+func (f *Fake) AssertNotCalled(t testing.TB, op cache.Op, key string) {
+	t.Helper()
+	if f.called(op, key) {
+		t.Errorf("cachetest: expected %s(%q) not to be called", op, key)
+	}
+}
+
+// AssertCallCount -
+// Fails the test unless op was called exactly n times
+func (f *Fake) AssertCallCount(t testing.TB, op cache.Op, n int) {
+	t.Helper()
+	if got := f.CallCount(op); got != n {
+		t.Errorf("cachetest: expected %s to be called %d times, got %d", op, n, got)
+	}
+}
+
+// AssertStored -
+// Fails the test unless key currently holds want
+func (f *Fake) AssertStored(t testing.TB, key string, want []byte) {
+	t.Helper()
+	f.mutex.Lock()
+	got, ok := f.data[key]
+	f.mutex.Unlock()
+	if !ok {
+		t.Errorf("cachetest: expected %q to be stored", key)
+		return
+	}
+	if string(got) != string(want) {
+		t.Errorf("cachetest: expected %q to hold %q, got %q", key, want, got)
+	}
+}
+
+func (f *Fake) called(op cache.Op, key string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, c := range f.calls {
+		if c.Op == op && c.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// record -
+// Records the call and returns the programmed error of op, the caller must hold the lock
+func (f *Fake) record(op cache.Op, key string, val []byte) error {
+	f.calls = append(f.calls, Call{Op: op, Key: key, Value: val})
+	return f.errs[op]
+}
+
+// Put -
+// Stores the value
+func (f *Fake) Put(key string, val []byte) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record(cache.OpPut, key, val); err != nil {
+		return err
+	}
+	f.data[key] = val
+	f.stats.Puts.Add(1)
+	return nil
+}
+
+// Get -
+// Fetches the stored value, returning ErrNotFound when there is none
+func (f *Fake) Get(key string) ([]byte, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record(cache.OpGet, key, nil); err != nil {
+		return nil, err
+	}
+	val, ok := f.data[key]
+	if !ok {
+		f.stats.Misses.Add(1)
+		return nil, ErrNotFound
+	}
+	f.stats.Hits.Add(1)
+	return val, nil
+}
+
+// Delete -
+// Removes the stored value
+func (f *Fake) Delete(key string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record(cache.OpDelete, key, nil); err != nil {
+		return err
+	}
+	if _, ok := f.data[key]; ok {
+		delete(f.data, key)
+		f.stats.Deletes.Add(1)
+	}
+	return nil
+}
+
+// IsWarm -
+// Reports whether a value is stored, values never go stale
+func (f *Fake) IsWarm(key string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record(cache.OpIsWarm, key, nil); err != nil {
+		return false
+	}
+	_, ok := f.data[key]
+	return ok
+}
+
+// Flush -
+// Removes every stored value
+func (f *Fake) Flush() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record(cache.OpFlush, "", nil); err != nil {
+		return err
+	}
+	f.data = map[string][]byte{}
+	return nil
+}
+
+// FlushStale -
+// Records the call, values never go stale
+func (f *Fake) FlushStale() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.stats.StaleFlushes.Add(1)
+	return f.record(cache.OpFlushStale, "", nil)
+}
+
+// RunCleaner -
+// Does nothing, values never go stale
+func (f *Fake) RunCleaner(ctx context.Context) {}
+
+// Stats -
+// Returns a snapshot of the operation counters
+func (f *Fake) Stats() cache.Stats {
+	return f.stats.Snapshot()
+}
+
+// Close -
+// Does nothing, the fake holds no resources
+func (f *Fake) Close() error {
+	return nil
+}
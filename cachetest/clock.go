@@ -0,0 +1,44 @@
+package cachetest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a manually advanced cache.Clock, it is safe for concurrent use.
+type Clock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewClock -
+// Initialises a new Clock frozen at now
+func NewClock(now time.Time) *Clock {
+	return &Clock{
+		now: now,
+	}
+}
+
+// Now -
+// Returns the current time of the clock
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Advance -
+// Moves the clock forward by d
+func (c *Clock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set -
+// Moves the clock to t
+func (c *Clock) Set(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = t
+}
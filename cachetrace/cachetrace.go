@@ -0,0 +1,140 @@
+// Package cachetrace emits OpenTelemetry spans for the operations of any cache.Cache implementation.
+// The wrapper is built by cachetrace.Instrument rather than a cache.WithTracing function of the
+// root package, so that only the programs tracing their caches import OpenTelemetry.
+package cachetrace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/pedreviljoen/go-cache"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Cache wraps a cache, emitting a span for every operation. Spans are children of the span
+// carried by the context bound through WithContext, or root spans when none is bound
+type Cache struct {
+	cache.Wrapper
+	tracer  trace.Tracer
+	backend string
+	ctx     context.Context
+}
+
+// Instrument -
+// Wraps c so that every operation emits a span carrying a hash of the key, the backend type
+// and, for reads, whether the key was a hit or a miss. Keys are hashed so that no
+// sensitive identifiers leak into traces
+func Instrument(c cache.Cache, tracer trace.Tracer) *Cache {
+	return &Cache{
+		Wrapper: cache.Wrapper{Cache: c},
+		tracer:  tracer,
+		backend: fmt.Sprintf("%T", c),
+		ctx:     context.Background(),
+	}
+}
+
+// WithContext -
+// Returns a copy of the cache whose spans are started from ctx, typically the context of the
+// request being served, so that cache operations show up inside the trace of the request
+func (t *Cache) WithContext(ctx context.Context) *Cache {
+	cp := *t
+	cp.ctx = ctx
+	return &cp
+}
+
+// start -
+// Starts a span for op with the common attributes
+func (t *Cache) start(op string, attrs ...attribute.KeyValue) trace.Span {
+	_, span := t.tracer.Start(t.ctx, "cache."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("cache.backend", t.backend)),
+		trace.WithAttributes(attrs...),
+	)
+	return span
+}
+
+// end -
+// Records err on span, if any, and ends it
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// keyHash -
+// Returns a short, stable hash of key suitable as a span attribute
+func keyHash(key string) attribute.KeyValue {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return attribute.String("cache.key_hash", strconv.FormatUint(h.Sum64(), 16))
+}
+
+// Put -
+// Saves the value inside the wrapped cache
+func (t *Cache) Put(key string, val []byte) error {
+	span := t.start("put", keyHash(key), attribute.Int("cache.value_size", len(val)))
+	err := t.Cache.Put(key, val)
+	end(span, err)
+	return err
+}
+
+// Get -
+// Fetches the value from the wrapped cache, a failed Get is recorded as a miss and its error is
+// recorded on the span unless it only reports a missing key
+func (t *Cache) Get(key string) ([]byte, error) {
+	span := t.start("get", keyHash(key))
+	val, err := t.Cache.Get(key)
+	span.SetAttributes(attribute.Bool("cache.hit", err == nil))
+	if errors.Is(err, cache.ErrKeyNotFound) || errors.Is(err, redis.Nil) {
+		end(span, nil)
+	} else {
+		end(span, err)
+	}
+	return val, err
+}
+
+// Delete -
+// Deletes the value from the wrapped cache
+func (t *Cache) Delete(key string) error {
+	span := t.start("delete", keyHash(key))
+	err := t.Cache.Delete(key)
+	end(span, err)
+	return err
+}
+
+// IsWarm -
+// Determines if the wrapped cache holds a value for key. IsWarm reports no error, so a failure of
+// the wrapped cache is recorded like a cold key
+func (t *Cache) IsWarm(key string) bool {
+	span := t.start("is_warm", keyHash(key))
+	ok := t.Cache.IsWarm(key)
+	span.SetAttributes(attribute.Bool("cache.hit", ok))
+	end(span, nil)
+	return ok
+}
+
+// Flush -
+// Empties the wrapped cache
+func (t *Cache) Flush() error {
+	span := t.start("flush")
+	err := t.Cache.Flush()
+	end(span, err)
+	return err
+}
+
+// FlushStale -
+// Flushes the stale items of the wrapped cache
+func (t *Cache) FlushStale() error {
+	span := t.start("flush_stale")
+	err := t.Cache.FlushStale()
+	end(span, err)
+	return err
+}
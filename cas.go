@@ -0,0 +1,14 @@
+package cache
+
+// Version is an opaque token describing the state of a cache item, returned alongside a value
+// and checked by PutIfVersion so concurrent writers do not clobber each other. The zero
+// Version stands for a missing key
+type Version string
+
+// VersionedPutter is implemented by caches supporting optimistic concurrency through version tokens.
+type VersionedPutter interface {
+	// GetVersion fetches the value of key along with its current version.
+	GetVersion(key string) ([]byte, Version, error)
+	// PutIfVersion saves val only if key is still at version, returning ErrVersionMismatch otherwise.
+	PutIfVersion(key string, val []byte, version Version) error
+}
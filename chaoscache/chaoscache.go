@@ -0,0 +1,171 @@
+// Package chaoscache wraps any cache.Cache and injects latency, errors and dropped writes,
+// so services can verify they degrade gracefully when the cache misbehaves.
+package chaoscache
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+// ErrInjected is the default error returned by injected failures.
+var ErrInjected = errors.New("chaoscache: injected failure")
+
+// ChaosCache is a fault injecting cache.Cache decorator
+type ChaosCache struct {
+	cache.Wrapper
+
+	mutex      sync.Mutex // guards rnd
+	rnd        *rand.Rand
+	minLatency time.Duration
+	maxLatency time.Duration
+	errorRate  float64
+	dropRate   float64
+	err        error
+}
+
+type Option func(*ChaosCache)
+
+// New -
+// Wraps c, without options no faults are injected
+func New(c cache.Cache, opts ...Option) *ChaosCache {
+	cc := &ChaosCache{
+		Wrapper: cache.Wrapper{Cache: c},
+		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		err:     ErrInjected,
+	}
+	for _, opt := range opts {
+		opt(cc)
+	}
+	return cc
+}
+
+// Latency -
+// Functional option delaying every operation by a random duration between min and max
+func Latency(min, max time.Duration) Option {
+	return func(cc *ChaosCache) {
+		cc.minLatency = min
+		cc.maxLatency = max
+	}
+}
+
+// ErrorRate -
+// Functional option failing the given fraction (0 to 1) of operations
+func ErrorRate(p float64) Option {
+	return func(cc *ChaosCache) {
+		cc.errorRate = p
+	}
+}
+
+// DropRate -
+// Functional option silently discarding the given fraction (0 to 1) of Put calls while reporting success
+func DropRate(p float64) Option {
+	return func(cc *ChaosCache) {
+		cc.dropRate = p
+	}
+}
+
+// Error -
+// Functional option specifying the error returned by injected failures, defaults to ErrInjected
+func Error(err error) Option {
+	return func(cc *ChaosCache) {
+		cc.err = err
+	}
+}
+
+// Seed -
+// Functional option seeding the random source so fault sequences are reproducible
+func Seed(seed int64) Option {
+	return func(cc *ChaosCache) {
+		cc.rnd = rand.New(rand.NewSource(seed))
+	}
+}
+
+// chance -
+// Reports true with probability p
+func (cc *ChaosCache) chance(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	return cc.rnd.Float64() < p
+}
+
+// inject -
+// Sleeps for the configured latency and returns the injected error when the operation should fail
+func (cc *ChaosCache) inject() error {
+	if cc.maxLatency > 0 {
+		d := cc.minLatency
+		if spread := cc.maxLatency - cc.minLatency; spread > 0 {
+			cc.mutex.Lock()
+			d += time.Duration(cc.rnd.Int63n(int64(spread)))
+			cc.mutex.Unlock()
+		}
+		time.Sleep(d)
+	}
+	if cc.chance(cc.errorRate) {
+		return cc.err
+	}
+	return nil
+}
+
+// Put -
+// Saves the value inside the wrapped cache unless the write is dropped or fails
+func (cc *ChaosCache) Put(key string, val []byte) error {
+	if err := cc.inject(); err != nil {
+		return err
+	}
+	if cc.chance(cc.dropRate) {
+		return nil
+	}
+	return cc.Cache.Put(key, val)
+}
+
+// Get -
+// Fetches the value from the wrapped cache unless the call fails
+func (cc *ChaosCache) Get(key string) ([]byte, error) {
+	if err := cc.inject(); err != nil {
+		return nil, err
+	}
+	return cc.Cache.Get(key)
+}
+
+// Delete -
+// Deletes the value from the wrapped cache unless the call fails
+func (cc *ChaosCache) Delete(key string) error {
+	if err := cc.inject(); err != nil {
+		return err
+	}
+	return cc.Cache.Delete(key)
+}
+
+// IsWarm -
+// Determines if the wrapped cache holds a value for key, a failed call reports false
+func (cc *ChaosCache) IsWarm(key string) bool {
+	if err := cc.inject(); err != nil {
+		return false
+	}
+	return cc.Cache.IsWarm(key)
+}
+
+// Flush -
+// Empties the wrapped cache unless the call fails
+func (cc *ChaosCache) Flush() error {
+	if err := cc.inject(); err != nil {
+		return err
+	}
+	return cc.Cache.Flush()
+}
+
+// FlushStale -
+// Flushes the stale items of the wrapped cache unless the call fails
+func (cc *ChaosCache) FlushStale() error {
+	if err := cc.inject(); err != nil {
+		return err
+	}
+	return cc.Cache.FlushStale()
+}
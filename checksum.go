@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Checksum selects the algorithm used by WithChecksum, its value is stored in front of every checksum
+type Checksum byte
+
+const (
+	// CRC32C checksums values with CRC-32 using the Castagnoli polynomial, hardware accelerated on most CPUs.
+	CRC32C Checksum = 1
+	// XXHash checksums values with the 64 bit xxHash, faster on large values.
+	XXHash Checksum = 2
+)
+
+// castagnoli is the CRC-32C table
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// size -
+// Returns the number of bytes of the checksum, 0 for an unknown algorithm
+func (a Checksum) size() int {
+	switch a {
+	case CRC32C:
+		return 4
+	case XXHash:
+		return 8
+	}
+	return 0
+}
+
+// sum -
+// Appends the checksum of val to b
+func (a Checksum) sum(b, val []byte) []byte {
+	switch a {
+	case CRC32C:
+		return binary.BigEndian.AppendUint32(b, crc32.Checksum(val, castagnoli))
+	case XXHash:
+		return binary.BigEndian.AppendUint64(b, xxhash.Sum64(val))
+	}
+	return b
+}
+
+// checksumming verifies the values of the wrapped cache against a stored checksum.
+type checksumming struct {
+	Wrapper
+	algo Checksum
+}
+
+// WithChecksum -
+// Wraps c so that every value is stored with its checksum, verified on Get to catch truncated writes
+// and bit rot, returning ErrCorrupted on mismatch. Values written with either algorithm can be read
+func WithChecksum(c Cache, algo Checksum) Cache {
+	return &checksumming{
+		Wrapper: Wrapper{Cache: c},
+		algo:    algo,
+	}
+}
+
+// Put -
+// Saves the value prefixed by its checksum
+func (s *checksumming) Put(key string, val []byte) error {
+	if s.algo.size() == 0 {
+		return fmt.Errorf("unknown checksum algorithm %d", s.algo)
+	}
+	b := make([]byte, 1, 1+s.algo.size()+len(val))
+	b[0] = byte(s.algo)
+	b = s.algo.sum(b, val)
+	return s.Cache.Put(key, append(b, val...))
+}
+
+// Get -
+// Fetches the value and verifies its checksum, returning ErrCorrupted on mismatch
+func (s *checksumming) Get(key string) ([]byte, error) {
+	data, err := s.Cache.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: %s holds no checksum", ErrCorrupted, key)
+	}
+	algo := Checksum(data[0])
+	n := algo.size()
+	if n == 0 || len(data) < 1+n {
+		return nil, fmt.Errorf("%w: %s holds no checksum", ErrCorrupted, key)
+	}
+	val := data[1+n:]
+	if string(algo.sum(nil, val)) != string(data[1:1+n]) {
+		return nil, fmt.Errorf("%w: checksum mismatch on %s", ErrCorrupted, key)
+	}
+	return val, nil
+}
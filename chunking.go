@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// chunking format headers written in front of every value
+const (
+	wholeValue    byte = 0
+	chunkManifest byte = 1
+)
+
+// chunking splits large values of the wrapped cache across several keys.
+type chunking struct {
+	Wrapper
+	size int
+}
+
+// WithChunking -
+// Wraps c so that values larger than size bytes are split into chunks of size bytes stored under
+// their own keys, with a manifest stored under the original key, working around the value size
+// limits of backends and proxies. Get reassembles the chunks and reports a miss when any of them
+// expired, Delete removes every chunk. Every value is prefixed by a 1 byte format header
+func WithChunking(c Cache, size int) Cache {
+	return &chunking{
+		Wrapper: Wrapper{Cache: c},
+		size:    size,
+	}
+}
+
+// manifest describes a value split into chunks
+type manifest struct {
+	gen    string // distinguishes the chunks of successive writes of a key
+	chunks int
+	length int
+}
+
+// chunkKey -
+// Returns the key of chunk i of a manifest
+func chunkKey(key, gen string, i int) string {
+	return key + ":chunk:" + gen + ":" + strconv.Itoa(i)
+}
+
+// manifest -
+// Fetches the manifest of key, ok is false when the value is stored whole
+func (k *chunking) manifest(key string) (m manifest, val []byte, ok bool, err error) {
+	data, err := k.Cache.Get(key)
+	if err != nil {
+		return m, nil, false, err
+	}
+	if len(data) == 0 {
+		return m, nil, false, errors.New("unable to read chunked value: missing format header")
+	}
+	switch data[0] {
+	case wholeValue:
+		return m, data[1:], false, nil
+	case chunkManifest:
+		rest := data[1:]
+		chunks, n := binary.Uvarint(rest)
+		if n <= 0 {
+			return m, nil, false, errors.New("unable to read chunked value: malformed manifest")
+		}
+		length, l := binary.Uvarint(rest[n:])
+		if l <= 0 {
+			return m, nil, false, errors.New("unable to read chunked value: malformed manifest")
+		}
+		m.chunks, m.length, m.gen = int(chunks), int(length), string(rest[n+l:])
+		return m, nil, true, nil
+	}
+	return m, nil, false, fmt.Errorf("unable to read chunked value: unknown format header %d", data[0])
+}
+
+// deleteChunks -
+// Deletes the chunks of a manifest, ignoring those which already expired
+func (k *chunking) deleteChunks(key string, m manifest) {
+	for i := 0; i < m.chunks; i++ {
+		k.Cache.Delete(chunkKey(key, m.gen, i))
+	}
+}
+
+// Put -
+// Saves the value whole or, when larger than the chunk size, as chunks followed by their manifest.
+// The chunks of the value previously stored under key are deleted afterwards
+func (k *chunking) Put(key string, val []byte) error {
+	old, _, chunked, _ := k.manifest(key)
+	if len(val) <= k.size || k.size <= 0 {
+		if err := k.Cache.Put(key, append([]byte{wholeValue}, val...)); err != nil {
+			return err
+		}
+	} else {
+		m := manifest{
+			gen:    strconv.FormatInt(time.Now().UnixNano(), 36),
+			chunks: (len(val) + k.size - 1) / k.size,
+			length: len(val),
+		}
+		for i := 0; i < m.chunks; i++ {
+			end := (i + 1) * k.size
+			if end > len(val) {
+				end = len(val)
+			}
+			if err := k.Cache.Put(chunkKey(key, m.gen, i), val[i*k.size:end]); err != nil {
+				k.deleteChunks(key, manifest{gen: m.gen, chunks: i})
+				return err
+			}
+		}
+		if err := k.putManifest(key, m); err != nil {
+			k.deleteChunks(key, m)
+			return err
+		}
+	}
+	if chunked {
+		k.deleteChunks(key, old)
+	}
+	return nil
+}
+
+// Get -
+// Fetches the value, reassembling its chunks, a missing chunk is reported as a miss
+func (k *chunking) Get(key string) ([]byte, error) {
+	m, val, chunked, err := k.manifest(key)
+	if err != nil || !chunked {
+		return val, err
+	}
+	out := make([]byte, 0, m.length)
+	for i := 0; i < m.chunks; i++ {
+		chunk, err := k.Cache.Get(chunkKey(key, m.gen, i))
+		if err != nil {
+			return nil, fmt.Errorf("unable to retrieve chunk %d of %s: %w", i, key, err)
+		}
+		out = append(out, chunk...)
+	}
+	if len(out) != m.length {
+		return nil, fmt.Errorf("unable to retrieve %s: chunks hold %d bytes instead of %d", key, len(out), m.length)
+	}
+	return out, nil
+}
+
+// Delete -
+// Deletes the value along with its chunks
+func (k *chunking) Delete(key string) error {
+	if m, _, chunked, err := k.manifest(key); err == nil && chunked {
+		k.deleteChunks(key, m)
+	}
+	return k.Cache.Delete(key)
+}
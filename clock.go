@@ -0,0 +1,16 @@
+package cache
+
+import "time"
+
+// Clock tells the time, it allows expiry to be tested without sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock backed by time.Now, it is the default Clock of the cache adaptors.
+type SystemClock struct{}
+
+// Now returns the current local time.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
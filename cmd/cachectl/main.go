@@ -0,0 +1,340 @@
+// Command cachectl inspects and repairs the state of a cache during incidents. It connects to any
+// backend registered with cache.Open, the cache being selected by the -dsn flag, the CACHE_DSN
+// environment variable or the CACHE_* variables read by cache.FromEnv, in that order.
+//
+// Usage:
+//
+//	cachectl [-dsn url] [-timeout d] <command> [arguments]
+//
+// The commands are:
+//
+//	get <key>                          writes the value of key to stdout
+//	put [-ttl d] <key> [value]         saves value, read from stdin when omitted
+//	delete <key>...                    removes keys
+//	keys [-prefix p] [-match glob] [-limit n]
+//	                                   lists keys
+//	ttl <key>                          prints the time left before key goes stale
+//	flush [-stale]                     empties the cache, or only removes its stale items
+//	stats                              prints the operation counters
+//	copy [-rate n] <destination url>   copies every entry into another cache
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+	_ "github.com/pedreviljoen/go-cache/memory"
+	_ "github.com/pedreviljoen/go-cache/redis"
+)
+
+// errUsage reports invalid command line arguments, the usage having been printed already
+var errUsage = errors.New("invalid usage")
+
+// errMissing reports a key holding no value
+var errMissing = errors.New("key not found")
+
+// warmther is implemented by caches reporting the time left before an item goes stale
+type warmther interface {
+	Warmth(key string) (time.Duration, bool)
+}
+
+// command is a cachectl subcommand
+type command struct {
+	usage string
+	run   func(ctx context.Context, c cache.Cache, fs *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands = map[string]command{
+	"get":    {"get <key>", runGet},
+	"put":    {"put [-ttl d] <key> [value]", runPut},
+	"delete": {"delete <key>...", runDelete},
+	"keys":   {"keys [-prefix p] [-match glob] [-limit n]", runKeys},
+	"ttl":    {"ttl <key>", runTTL},
+	"flush":  {"flush [-stale]", runFlush},
+	"stats":  {"stats", runStats},
+	"copy":   {"copy [-rate n] <destination url>", runCopy},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run -
+// Runs cachectl with the command line args, returning the exit status: 0 on success, 1 on failure
+// and 2 on invalid usage
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("cachectl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dsn := fs.String("dsn", os.Getenv("CACHE_DSN"), "connection URL of the cache, e.g. redis://localhost:6379/0")
+	timeout := fs.Duration("timeout", time.Minute, "time limit of the command")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cachectl [-dsn url] [-timeout d] <command> [arguments]")
+		fmt.Fprintln(stderr, "\ncommands:")
+		for _, name := range []string{"get", "put", "delete", "keys", "ttl", "flush", "stats", "copy"} {
+			fmt.Fprintln(stderr, "  "+commands[name].usage)
+		}
+		fmt.Fprintln(stderr, "\nflags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	name := fs.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "cachectl: unknown command %q\n", name)
+		fs.Usage()
+		return 2
+	}
+
+	c, err := open(*dsn)
+	if err != nil {
+		fmt.Fprintln(stderr, "cachectl:", err)
+		return 1
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	sub := flag.NewFlagSet(name, flag.ContinueOnError)
+	sub.SetOutput(stderr)
+	sub.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cachectl "+cmd.usage)
+		sub.PrintDefaults()
+	}
+	err = cmd.run(ctx, c, sub, fs.Args()[1:], stdin, stdout)
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		return 2
+	default:
+		fmt.Fprintf(stderr, "cachectl %s: %v\n", name, err)
+		return 1
+	}
+}
+
+// open -
+// Opens the cache at dsn, or the one described by the environment when dsn is empty
+func open(dsn string) (cache.Cache, error) {
+	if dsn != "" {
+		return cache.Open(dsn)
+	}
+	cfg, err := cache.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return cache.Open(cfg.URL())
+}
+
+// parse -
+// Parses the flags of a subcommand and checks the number of its remaining arguments, a negative
+// max allowing any number
+func parse(fs *flag.FlagSet, args []string, min, max int) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() < min || (max >= 0 && fs.NArg() > max) {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// runGet -
+// Writes the value of a key to stdout as is
+func runGet(_ context.Context, c cache.Cache, fs *flag.FlagSet, args []string, _ io.Reader, stdout io.Writer) error {
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	val, err := c.Get(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(val)
+	return err
+}
+
+// runPut -
+// Saves a value given as argument or read from stdin, optionally setting its lifetime
+func runPut(_ context.Context, c cache.Cache, fs *flag.FlagSet, args []string, stdin io.Reader, _ io.Writer) error {
+	ttl := fs.Duration("ttl", 0, "lifetime of the value, defaults to the cache window")
+	if err := parse(fs, args, 1, 2); err != nil {
+		return err
+	}
+	key := fs.Arg(0)
+	var val []byte
+	if fs.NArg() == 2 {
+		val = []byte(fs.Arg(1))
+	} else {
+		var err error
+		if val, err = io.ReadAll(stdin); err != nil {
+			return fmt.Errorf("unable to read the value: %w", err)
+		}
+	}
+	if err := c.Put(key, val); err != nil {
+		return err
+	}
+	if *ttl > 0 {
+		t, ok := c.(cache.Toucher)
+		if !ok {
+			return fmt.Errorf("unable to set the lifetime: %w", cache.ErrNotSupported)
+		}
+		return t.Touch(key, *ttl)
+	}
+	return nil
+}
+
+// runDelete -
+// Removes keys, missing keys are not an error
+func runDelete(_ context.Context, c cache.Cache, fs *flag.FlagSet, args []string, _ io.Reader, stdout io.Writer) error {
+	if err := parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+	deleted := 0
+	for _, key := range fs.Args() {
+		if !c.IsWarm(key) {
+			c.Delete(key) // drops a stale item
+			continue
+		}
+		if err := c.Delete(key); err != nil {
+			return fmt.Errorf("unable to delete %s: %w", key, err)
+		}
+		deleted++
+	}
+	fmt.Fprintf(stdout, "deleted %d of %d keys\n", deleted, fs.NArg())
+	return nil
+}
+
+// runKeys -
+// Lists the keys of the cache, one per line
+func runKeys(ctx context.Context, c cache.Cache, fs *flag.FlagSet, args []string, _ io.Reader, stdout io.Writer) error {
+	prefix := fs.String("prefix", "", "only list keys starting with prefix")
+	match := fs.String("match", "", "only list keys matching the redis style glob pattern")
+	limit := fs.Int("limit", 0, "stop after listing n keys, unlimited when zero")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	sc, ok := c.(cache.Scanner)
+	if !ok {
+		return fmt.Errorf("unable to list keys: %w", cache.ErrNotSupported)
+	}
+	errLimit := errors.New("limit reached")
+	listed := 0
+	err := sc.Scan(ctx, func(key string) error {
+		if !strings.HasPrefix(key, *prefix) {
+			return nil
+		}
+		if *match != "" && !cache.Match(*match, key) {
+			return nil
+		}
+		if _, err := fmt.Fprintln(stdout, key); err != nil {
+			return err
+		}
+		listed++
+		if *limit > 0 && listed >= *limit {
+			return errLimit
+		}
+		return nil
+	})
+	if errors.Is(err, errLimit) {
+		return nil
+	}
+	return err
+}
+
+// runTTL -
+// Prints the time left before a key goes stale
+func runTTL(_ context.Context, c cache.Cache, fs *flag.FlagSet, args []string, _ io.Reader, stdout io.Writer) error {
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	key := fs.Arg(0)
+	var (
+		ttl time.Duration
+		ok  bool
+	)
+	switch r := c.(type) {
+	case warmther:
+		ttl, ok = r.Warmth(key)
+	case cache.TTLReader:
+		_, d, err := r.GetWithTTL(key)
+		ttl, ok = d, err == nil && d != 0
+	default:
+		return fmt.Errorf("unable to read lifetimes: %w", cache.ErrNotSupported)
+	}
+	if !ok {
+		return errMissing
+	}
+	if ttl <= 0 {
+		fmt.Fprintln(stdout, "no expiry")
+		return nil
+	}
+	fmt.Fprintln(stdout, ttl.Round(time.Millisecond))
+	return nil
+}
+
+// runFlush -
+// Empties the cache, or only flushes its stale items
+func runFlush(_ context.Context, c cache.Cache, fs *flag.FlagSet, args []string, _ io.Reader, _ io.Writer) error {
+	stale := fs.Bool("stale", false, "only remove stale items")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *stale {
+		return c.FlushStale()
+	}
+	return c.Flush()
+}
+
+// runStats -
+// Prints the operation counters of the cache
+func runStats(_ context.Context, c cache.Cache, fs *flag.FlagSet, args []string, _ io.Reader, stdout io.Writer) error {
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	r, ok := c.(cache.StatsReporter)
+	if !ok {
+		return fmt.Errorf("unable to read statistics: %w", cache.ErrNotSupported)
+	}
+	s := r.Stats()
+	fmt.Fprintf(stdout, "hits          %d\n", s.Hits)
+	fmt.Fprintf(stdout, "misses        %d\n", s.Misses)
+	fmt.Fprintf(stdout, "hit_ratio     %.4f\n", s.HitRatio())
+	fmt.Fprintf(stdout, "puts          %d\n", s.Puts)
+	fmt.Fprintf(stdout, "deletes       %d\n", s.Deletes)
+	fmt.Fprintf(stdout, "evictions     %d\n", s.Evictions)
+	fmt.Fprintf(stdout, "stale_flushes %d\n", s.StaleFlushes)
+	if l, ok := c.(interface{ Len() int }); ok {
+		fmt.Fprintf(stdout, "entries       %d\n", l.Len())
+	}
+	return nil
+}
+
+// runCopy -
+// Copies every entry of the cache into the cache at the destination URL, keeping their lifetimes
+func runCopy(ctx context.Context, c cache.Cache, fs *flag.FlagSet, args []string, _ io.Reader, stdout io.Writer) error {
+	rate := fs.Int("rate", 0, "entries copied per second, unlimited when zero")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	dst, err := cache.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	n, err := cache.Copy(ctx, c, dst, cache.CopyRate(*rate))
+	fmt.Fprintf(stdout, "copied %d entries\n", n)
+	return err
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+// Codec turns values into bytes for caching and back.
+type Codec interface {
+	// Marshal encodes v.
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes data into v, which must be a pointer.
+	Unmarshal(data []byte, v any) error
+}
+
+// JSON encodes values with encoding/json
+var JSON Codec = jsonCodec{}
+
+// Gob encodes values with encoding/gob, types stored behind interfaces must be registered with gob.Register
+var Gob Codec = gobCodec{}
+
+// jsonCodec implements Codec with encoding/json
+type jsonCodec struct{}
+
+// Marshal -
+// Encodes v as JSON
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal -
+// Decodes JSON into v
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// gobCodec implements Codec with encoding/gob
+type gobCodec struct{}
+
+// Marshal -
+// Encodes v as a gob stream
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal -
+// Decodes a gob stream into v
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// ObjectCache caches arbitrary values through a Codec, on top of the byte oriented methods of the wrapped cache
+type ObjectCache struct {
+	Wrapper
+	codec Codec
+}
+
+// WithCodec -
+// Wraps c with PutObject and GetObject, encoding values with codec, for instance cache.JSON,
+// cache.Gob or cachecodec.MsgPack
+func WithCodec(c Cache, codec Codec) *ObjectCache {
+	return &ObjectCache{
+		Wrapper: Wrapper{Cache: c},
+		codec:   codec,
+	}
+}
+
+// PutObject -
+// Encodes v and saves it under key
+func (o *ObjectCache) PutObject(key string, v any) error {
+	data, err := o.codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("unable to encode value: %w", err)
+	}
+	return o.Put(key, data)
+}
+
+// GetObject -
+// Fetches the value of key and decodes it into v, which must be a pointer
+func (o *ObjectCache) GetObject(key string, v any) error {
+	data, err := o.Get(key)
+	if err != nil {
+		return err
+	}
+	if err := o.codec.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("unable to decode value: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// format headers written in front of every value stored through WithCompression
+const (
+	rawValue        byte = 0
+	compressedValue byte = 1
+)
+
+// Compressor compresses and decompresses cached values.
+type Compressor interface {
+	// Compress returns the compressed form of src.
+	Compress(src []byte) ([]byte, error)
+	// Decompress returns the original form of src produced by Compress.
+	Decompress(src []byte) ([]byte, error)
+}
+
+// Gzip compresses values with compress/gzip at the default compression level, more codecs
+// such as snappy and zstd are available in the cachecompress package
+var Gzip Compressor = gzipCompressor{}
+
+// gzipCompressor implements Compressor with compress/gzip
+type gzipCompressor struct{}
+
+// Compress -
+// Gzips src
+func (gzipCompressor) Compress(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress -
+// Gunzips src
+func (gzipCompressor) Decompress(src []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// compressing compresses the values of the wrapped cache.
+type compressing struct {
+	Wrapper
+	codec   Compressor
+	minSize int
+}
+
+// WithCompression -
+// Wraps c so that values of at least minSize bytes are compressed with codec, smaller values are
+// stored as is. Every value is prefixed by a 1 byte format header, so values written without the
+// wrapper cannot be read through it
+func WithCompression(c Cache, codec Compressor, minSize int) Cache {
+	return &compressing{
+		Wrapper: Wrapper{Cache: c},
+		codec:   codec,
+		minSize: minSize,
+	}
+}
+
+// Put -
+// Compresses the value when it reaches the size threshold and saves it
+func (z *compressing) Put(key string, val []byte) error {
+	if len(val) < z.minSize {
+		return z.Cache.Put(key, append([]byte{rawValue}, val...))
+	}
+	compressed, err := z.codec.Compress(val)
+	if err != nil {
+		return fmt.Errorf("unable to compress value: %w", err)
+	}
+	return z.Cache.Put(key, append([]byte{compressedValue}, compressed...))
+}
+
+// Get -
+// Fetches the value, decompressing it when needed
+func (z *compressing) Get(key string) ([]byte, error) {
+	val, err := z.Cache.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(val) == 0 {
+		return nil, fmt.Errorf("unable to decompress value: missing format header")
+	}
+	switch val[0] {
+	case rawValue:
+		return val[1:], nil
+	case compressedValue:
+		out, err := z.codec.Decompress(val[1:])
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress value: %w", err)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unable to decompress value: unknown format header %d", val[0])
+}
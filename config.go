@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config describes a cache in a form suited to configuration files and environment variables.
+type Config struct {
+	Backend       string            // registered driver name, e.g. memory or redis
+	Address       string            // host and port of the server, unused by the memory backend
+	Username      string            // credentials of the server, unused by the memory backend
+	Password      string            // credentials of the server, unused by the memory backend
+	Database      int               // database number of the server, left out of the URL of the memory backend
+	Window        time.Duration     // time window after which items go stale
+	Prefix        string            // namespace of every key, ignored by the memory backend
+	CleanInterval time.Duration     // how often the cleaner runs, defaults to the window
+	CleanJitter   float64           // fraction by which each cleaner interval is randomised
+	Cleaner       bool              // New runs the cleaner until the cache is closed
+	Params        map[string]string // further driver specific query parameters, see Open
+}
+
+// FromEnv -
+// Reads a Config from the CACHE_BACKEND, CACHE_ADDRESS, CACHE_USERNAME, CACHE_PASSWORD, CACHE_DATABASE,
+// CACHE_WINDOW, CACHE_PREFIX, CACHE_CLEAN_INTERVAL, CACHE_CLEAN_JITTER and CACHE_CLEANER environment
+// variables, unset variables keep their zero value and the backend defaults to memory
+func FromEnv() (Config, error) {
+	cfg := Config{
+		Backend:  os.Getenv("CACHE_BACKEND"),
+		Address:  os.Getenv("CACHE_ADDRESS"),
+		Username: os.Getenv("CACHE_USERNAME"),
+		Password: os.Getenv("CACHE_PASSWORD"),
+		Prefix:   os.Getenv("CACHE_PREFIX"),
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = "memory"
+	}
+	var err error
+	if v := os.Getenv("CACHE_DATABASE"); v != "" {
+		if cfg.Database, err = strconv.Atoi(v); err != nil {
+			return cfg, fmt.Errorf("invalid CACHE_DATABASE: %w", err)
+		}
+	}
+	if v := os.Getenv("CACHE_WINDOW"); v != "" {
+		if cfg.Window, err = time.ParseDuration(v); err != nil {
+			return cfg, fmt.Errorf("invalid CACHE_WINDOW: %w", err)
+		}
+	}
+	if v := os.Getenv("CACHE_CLEAN_INTERVAL"); v != "" {
+		if cfg.CleanInterval, err = time.ParseDuration(v); err != nil {
+			return cfg, fmt.Errorf("invalid CACHE_CLEAN_INTERVAL: %w", err)
+		}
+	}
+	if v := os.Getenv("CACHE_CLEAN_JITTER"); v != "" {
+		if cfg.CleanJitter, err = strconv.ParseFloat(v, 64); err != nil {
+			return cfg, fmt.Errorf("invalid CACHE_CLEAN_JITTER: %w", err)
+		}
+	}
+	if v := os.Getenv("CACHE_CLEANER"); v != "" {
+		if cfg.Cleaner, err = strconv.ParseBool(v); err != nil {
+			return cfg, fmt.Errorf("invalid CACHE_CLEANER: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// URL -
+// Returns the connection URL of the configuration, as accepted by Open
+func (cfg Config) URL() string {
+	u := url.URL{
+		Scheme: cfg.Backend,
+		Host:   cfg.Address,
+	}
+	if cfg.Username != "" || cfg.Password != "" {
+		u.User = url.UserPassword(cfg.Username, cfg.Password)
+	}
+	// the memory backend reads the path as its write-ahead log
+	if cfg.Database != 0 && (cfg.Backend == "redis" || cfg.Backend == "rediss") {
+		u.Path = "/" + strconv.Itoa(cfg.Database)
+	}
+	q := url.Values{}
+	for name, value := range cfg.Params {
+		q.Set(name, value)
+	}
+	if cfg.Window > 0 {
+		q.Set("window", cfg.Window.String())
+	}
+	if cfg.Prefix != "" {
+		q.Set("prefix", cfg.Prefix)
+	}
+	if cfg.CleanInterval > 0 {
+		q.Set("clean_interval", cfg.CleanInterval.String())
+	}
+	if cfg.CleanJitter > 0 {
+		q.Set("clean_jitter", strconv.FormatFloat(cfg.CleanJitter, 'g', -1, 64))
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// New -
+// Builds the cache described by cfg through the driver registered for its backend, see Open,
+// and starts its cleaner when requested
+func New(cfg Config) (Cache, error) {
+	c, err := Open(cfg.URL())
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Cleaner {
+		c.RunCleaner(context.Background())
+	}
+	return c, nil
+}
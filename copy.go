@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Scanner is implemented by caches able to enumerate their keys.
+type Scanner interface {
+	// Scan calls fn for every key of the cache until fn returns an error, which Scan returns.
+	Scan(ctx context.Context, fn func(key string) error) error
+}
+
+// copyConfig holds the settings of a Copy call
+type copyConfig struct {
+	rate     int
+	progress func(copied int)
+}
+
+type CopyOption func(*copyConfig)
+
+// CopyRate -
+// Functional option limiting how many entries are copied per second, up to one per nanosecond.
+// Unlimited by default
+func CopyRate(perSecond int) CopyOption {
+	return func(c *copyConfig) {
+		c.rate = perSecond
+	}
+}
+
+// CopyProgress -
+// Functional option to specify a callback invoked after every copied entry with the number copied so far
+func CopyProgress(fn func(copied int)) CopyOption {
+	return func(c *copyConfig) {
+		c.progress = fn
+	}
+}
+
+// Copy -
+// Streams every entry of src into dst, for instance to move from the memory cache to redis or between
+// redis clusters. src must implement Scanner. When src implements TTLReader and dst implements Toucher
+// entries keep their remaining lifetime, otherwise they start a fresh window inside dst. Pinned entries
+// are pinned inside dst when it implements Persist, and stale entries are not copied so that they are
+// not brought back to life. Entries which disappear from src while copying are skipped, any other
+// read failure stops the copy. Returns the number of copied entries
+func Copy(ctx context.Context, src, dst Cache, opts ...CopyOption) (int, error) {
+	scanner, ok := src.(Scanner)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	cfg := copyConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.rate < 0 || time.Duration(cfg.rate) > time.Second {
+		return 0, fmt.Errorf("invalid copy rate %d, expected up to %d entries per second", cfg.rate, time.Second)
+	}
+	ttls, _ := src.(TTLReader)
+	toucher, _ := dst.(Toucher)
+	pinner, _ := dst.(persister)
+
+	var tick <-chan time.Time
+	if cfg.rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(cfg.rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	copied := 0
+	err := scanner.Scan(ctx, func(key string) error {
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		var (
+			val []byte
+			ttl time.Duration
+			err error
+		)
+		if ttls != nil {
+			val, ttl, err = ttls.GetWithTTL(key)
+		} else {
+			val, err = src.Get(key)
+		}
+		if err != nil {
+			if errors.Is(err, ErrKeyNotFound) || !src.IsWarm(key) {
+				return nil // gone since it was scanned
+			}
+			return fmt.Errorf("unable to read %s: %w", key, err)
+		}
+		if (ttls != nil && ttl == 0) || (ttls == nil && !src.IsWarm(key)) {
+			return nil // stale
+		}
+		if err := dst.Put(key, val); err != nil {
+			return fmt.Errorf("unable to copy %s: %w", key, err)
+		}
+		switch {
+		case ttl == NoExpiry && pinner != nil:
+			if err := pinner.Persist(key); err != nil {
+				return fmt.Errorf("unable to pin %s: %w", key, err)
+			}
+		case ttl > 0 && toucher != nil:
+			if err := toucher.Touch(key, ttl); err != nil {
+				return fmt.Errorf("unable to copy the lifetime of %s: %w", key, err)
+			}
+		}
+		copied++
+		if cfg.progress != nil {
+			cfg.progress(copied)
+		}
+		return nil
+	})
+	return copied, err
+}
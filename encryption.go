@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// encryptedV1 is the format header of values encrypted by WithEncryption
+const encryptedV1 byte = 1
+
+// Keyring holds the AES keys of WithEncryption by ID. New values are encrypted with the primary key,
+// while any key of the ring can decrypt, so keys can be rotated without losing cached values
+type Keyring struct {
+	mutex   sync.RWMutex
+	keys    map[uint32]cipher.AEAD
+	primary uint32
+}
+
+// NewKeyring -
+// Initialises a keyring whose primary key is key, identified by id. The key must be 16, 24 or 32
+// bytes long to select AES-128, AES-192 or AES-256
+func NewKeyring(id uint32, key []byte) (*Keyring, error) {
+	k := &Keyring{
+		keys: map[uint32]cipher.AEAD{},
+	}
+	if err := k.Rotate(id, key); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Add -
+// Adds a key which can decrypt values but is not used to encrypt new ones
+func (k *Keyring) Add(id uint32, key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.keys[id] = aead
+	return nil
+}
+
+// Rotate -
+// Adds key and makes it the primary key, the previous primary key keeps decrypting existing values
+func (k *Keyring) Rotate(id uint32, key []byte) error {
+	if err := k.Add(id, key); err != nil {
+		return err
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.primary = id
+	return nil
+}
+
+// Remove -
+// Removes a retired key, values it encrypted can no longer be read. The primary key cannot be removed
+func (k *Keyring) Remove(id uint32) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if id == k.primary {
+		return errors.New("unable to remove the primary key")
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+// encrypting encrypts the values of the wrapped cache.
+type encrypting struct {
+	Wrapper
+	keys *Keyring
+}
+
+// WithEncryption -
+// Wraps c so that values are encrypted at rest with AES-GCM under the primary key of keys. The ID of
+// the key is stored in front of every value so rotated keys keep decrypting, and the cache key is
+// authenticated so an encrypted value cannot be moved to another key
+func WithEncryption(c Cache, keys *Keyring) Cache {
+	return &encrypting{
+		Wrapper: Wrapper{Cache: c},
+		keys:    keys,
+	}
+}
+
+// Put -
+// Encrypts the value under the primary key and saves it
+func (e *encrypting) Put(key string, val []byte) error {
+	e.keys.mutex.RLock()
+	id := e.keys.primary
+	aead := e.keys.keys[id]
+	e.keys.mutex.RUnlock()
+
+	out := make([]byte, 5+aead.NonceSize(), 5+aead.NonceSize()+len(val)+aead.Overhead())
+	out[0] = encryptedV1
+	binary.BigEndian.PutUint32(out[1:5], id)
+	nonce := out[5:]
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("unable to encrypt value: %w", err)
+	}
+	return e.Cache.Put(key, aead.Seal(out, nonce, val, []byte(key)))
+}
+
+// Get -
+// Fetches the value and decrypts it with the key it was encrypted with
+func (e *encrypting) Get(key string) ([]byte, error) {
+	val, err := e.Cache.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(val) < 5 || val[0] != encryptedV1 {
+		return nil, errors.New("unable to decrypt value: unknown format")
+	}
+	id := binary.BigEndian.Uint32(val[1:5])
+	e.keys.mutex.RLock()
+	aead, ok := e.keys.keys[id]
+	e.keys.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unable to decrypt value: unknown key %d", id)
+	}
+	if len(val) < 5+aead.NonceSize() {
+		return nil, errors.New("unable to decrypt value: truncated")
+	}
+	nonce, sealed := val[5:5+aead.NonceSize()], val[5+aead.NonceSize():]
+	out, err := aead.Open(nil, nonce, sealed, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt value: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"time"
+)
+
+// envelope format header, followed by the format version
+const (
+	envelopeMagic   byte = 0xCE
+	envelopeVersion byte = 1
+
+	envelopeCompressed byte = 1 << 0
+
+	envelopeHeader = 22 // magic, version, flags, schema, created at, hash and content type length
+)
+
+// Envelope is a cached value along with the metadata stored in front of it by EnvelopeCache
+type Envelope struct {
+	Schema      uint16    // version of the schema of Value, owned by the application
+	ContentType string    // codec of Value, e.g. "application/json", at most 255 bytes
+	Compressed  bool      // whether Value is compressed
+	CreatedAt   time.Time // when the value was saved
+	Hash        uint64    // FNV-1a hash of Value, usable as an ETag
+	Value       []byte
+}
+
+// MarshalBinary -
+// Encodes the envelope, the header takes 22 bytes plus the content type
+func (e Envelope) MarshalBinary() ([]byte, error) {
+	if len(e.ContentType) > 255 {
+		return nil, errors.New("envelope content type longer than 255 bytes")
+	}
+	b := make([]byte, envelopeHeader, envelopeHeader+len(e.ContentType)+len(e.Value))
+	b[0] = envelopeMagic
+	b[1] = envelopeVersion
+	if e.Compressed {
+		b[2] |= envelopeCompressed
+	}
+	binary.BigEndian.PutUint16(b[3:5], e.Schema)
+	binary.BigEndian.PutUint64(b[5:13], uint64(e.CreatedAt.UnixNano()))
+	binary.BigEndian.PutUint64(b[13:21], e.Hash)
+	b[21] = byte(len(e.ContentType))
+	b = append(b, e.ContentType...)
+	return append(b, e.Value...), nil
+}
+
+// UnmarshalBinary -
+// Decodes an envelope encoded by MarshalBinary, Value shares the memory of data
+func (e *Envelope) UnmarshalBinary(data []byte) error {
+	if len(data) < envelopeHeader || data[0] != envelopeMagic {
+		return errors.New("value is not an envelope")
+	}
+	if data[1] != envelopeVersion {
+		return errors.New("unknown envelope version")
+	}
+	ctLen := int(data[21])
+	if len(data) < envelopeHeader+ctLen {
+		return errors.New("envelope truncated")
+	}
+	e.Compressed = data[2]&envelopeCompressed != 0
+	e.Schema = binary.BigEndian.Uint16(data[3:5])
+	e.CreatedAt = time.Unix(0, int64(binary.BigEndian.Uint64(data[5:13])))
+	e.Hash = binary.BigEndian.Uint64(data[13:21])
+	e.ContentType = string(data[envelopeHeader : envelopeHeader+ctLen])
+	e.Value = data[envelopeHeader+ctLen:]
+	return nil
+}
+
+// hashValue -
+// Returns the FNV-1a hash of val
+func hashValue(val []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(val)
+	return h.Sum64()
+}
+
+// EnvelopeCache stores every value inside an Envelope, so the format of cached values can evolve
+// safely and reads can return the metadata along with the value
+type EnvelopeCache struct {
+	Wrapper
+	schema      uint16
+	contentType string
+	clock       Clock
+}
+
+type EnvelopeOption func(*EnvelopeCache)
+
+// WithEnvelope -
+// Wraps c so that values are stored inside an Envelope, values written without the wrapper cannot be read through it
+func WithEnvelope(c Cache, opts ...EnvelopeOption) *EnvelopeCache {
+	e := &EnvelopeCache{
+		Wrapper: Wrapper{Cache: c},
+		clock:   SystemClock{},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// EnvelopeSchema -
+// Functional option to specify the schema version recorded by Put
+func EnvelopeSchema(v uint16) EnvelopeOption {
+	return func(e *EnvelopeCache) {
+		e.schema = v
+	}
+}
+
+// EnvelopeContentType -
+// Functional option to specify the content type recorded by Put
+func EnvelopeContentType(ct string) EnvelopeOption {
+	return func(e *EnvelopeCache) {
+		e.contentType = ct
+	}
+}
+
+// EnvelopeClock -
+// Functional option to specify the clock timestamping envelopes
+func EnvelopeClock(c Clock) EnvelopeOption {
+	return func(e *EnvelopeCache) {
+		e.clock = c
+	}
+}
+
+// PutEnvelope -
+// Saves env under key, CreatedAt and Hash are filled in when zero
+func (e *EnvelopeCache) PutEnvelope(key string, env Envelope) error {
+	if env.CreatedAt.IsZero() {
+		env.CreatedAt = e.clock.Now()
+	}
+	if env.Hash == 0 {
+		env.Hash = hashValue(env.Value)
+	}
+	data, err := env.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return e.Cache.Put(key, data)
+}
+
+// GetEnvelope -
+// Fetches the envelope of key
+func (e *EnvelopeCache) GetEnvelope(key string) (Envelope, error) {
+	var env Envelope
+	data, err := e.Cache.Get(key)
+	if err != nil {
+		return env, err
+	}
+	err = env.UnmarshalBinary(data)
+	return env, err
+}
+
+// Put -
+// Saves the value inside an envelope carrying the configured schema version and content type
+func (e *EnvelopeCache) Put(key string, val []byte) error {
+	return e.PutEnvelope(key, Envelope{
+		Schema:      e.schema,
+		ContentType: e.contentType,
+		Value:       val,
+	})
+}
+
+// Get -
+// Fetches the value out of the envelope of key
+func (e *EnvelopeCache) Get(key string) ([]byte, error) {
+	env, err := e.GetEnvelope(key)
+	if err != nil {
+		return nil, err
+	}
+	return env.Value, nil
+}
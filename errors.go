@@ -0,0 +1,30 @@
+package cache
+
+import "errors"
+
+// ErrNotSupported is returned when the underlying cache does not support the requested operation.
+var ErrNotSupported = errors.New("operation not supported by cache")
+
+// ErrNotWarm is reported to After hooks when IsWarm finds no value inside the time window.
+var ErrNotWarm = errors.New("cache key is not warm")
+
+// ErrKeyExists is returned by Add when the key already holds a value.
+var ErrKeyExists = errors.New("cache key already exists")
+
+// ErrKeyNotFound is returned by Replace, and wrapped by the misses of the memory and mmap caches, when the key holds no value.
+var ErrKeyNotFound = errors.New("cache key not found")
+
+// ErrVersionMismatch is returned by PutIfVersion when the item changed since its version was read.
+var ErrVersionMismatch = errors.New("cache key version mismatch")
+
+// ErrNegative is returned by NegativeCache when the key is known to have no value, as opposed to a miss.
+var ErrNegative = errors.New("cache key is known to have no value")
+
+// ErrReadOnly is returned by writes to a ReadOnlyCache while read-only mode is on.
+var ErrReadOnly = errors.New("cache is read-only")
+
+// ErrCorrupted is returned by WithChecksum when a value does not match its stored checksum.
+var ErrCorrupted = errors.New("cache value corrupted")
+
+// ErrValueTooLarge is returned by writes of values exceeding the MaxValueSize of a cache adaptor.
+var ErrValueTooLarge = errors.New("cache value too large")
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Flight deduplicates concurrent calls sharing a key, as singleflight does, but runs the shared
+// call on a context detached from the caller which started it. The call keeps the values of that
+// context and is only cancelled once every caller waiting on it has given up, so one caller
+// cancelling never fails the others. The zero value is ready to use
+type Flight struct {
+	mutex sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is a call in progress, shared by its waiters.
+type flightCall struct {
+	done    chan struct{}
+	val     any
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// Do -
+// Runs fn once for all concurrent callers of key and returns its result. Each caller honours
+// its own ctx, returning ctx.Err() when it is done before fn returns
+func (f *Flight) Do(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (any, error) {
+	f.mutex.Lock()
+	if f.calls == nil {
+		f.calls = map[string]*flightCall{}
+	}
+	call, ok := f.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(detachedContext{ctx})
+		call = &flightCall{done: make(chan struct{}), cancel: cancel}
+		f.calls[key] = call
+		go func() {
+			call.val, call.err = fn(callCtx)
+			cancel()
+			f.forget(key, call)
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	f.mutex.Unlock()
+
+	select {
+	case <-call.done:
+		return call.val, call.err
+	case <-ctx.Done():
+		f.mutex.Lock()
+		call.waiters--
+		abandoned := call.waiters == 0
+		if abandoned && f.calls[key] == call {
+			// forgotten before unlocking, so no caller joins a call about to be cancelled
+			delete(f.calls, key)
+		}
+		f.mutex.Unlock()
+		if abandoned {
+			call.cancel()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// forget -
+// Removes call from the calls in progress, so later callers of key start a new one
+func (f *Flight) forget(key string, call *flightCall) {
+	f.mutex.Lock()
+	if f.calls[key] == call {
+		delete(f.calls, key)
+	}
+	f.mutex.Unlock()
+}
+
+// detachedContext carries the values of its parent but neither its deadline nor its cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (d detachedContext) Value(key any) any {
+	return d.parent.Value(key)
+}
@@ -0,0 +1,285 @@
+// Package grpccache caches the responses of unary gRPC calls keyed on a hash of the marshaled
+// request, on the client side through UnaryClientInterceptor and on the server side through
+// UnaryServerInterceptor.
+package grpccache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// interceptor caches the responses of the configured methods.
+type interceptor struct {
+	c       cache.Cache
+	methods map[string]time.Duration // full method name -> time to live, zero keeps the cache window
+	vary    []string                 // metadata keys included in the cache key
+	clock   cache.Clock
+	logger  cache.Logger
+	results *prometheus.CounterVec // calls per method and result, nil unless Metrics is used
+}
+
+type Option func(*interceptor)
+
+// newInterceptor -
+// Applies the options on top of the defaults
+func newInterceptor(c cache.Cache, opts []Option) *interceptor {
+	i := &interceptor{
+		c:       c,
+		methods: map[string]time.Duration{},
+		clock:   cache.SystemClock{},
+		logger:  cache.NopLogger{},
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Method -
+// Functional option making the responses of the full method name, e.g. "/pkg.Service/Get",
+// cacheable for ttl, a zero ttl keeps them for the window of the cache. Only the listed
+// methods are cached
+func Method(fullMethod string, ttl time.Duration) Option {
+	return func(i *interceptor) {
+		i.methods[fullMethod] = ttl
+	}
+}
+
+// VaryMetadata -
+// Functional option including the values of the metadata keys in the cache key, so that calls
+// differing in them never share a response. Without it responses are shared by every caller of a
+// method: a server whose responses depend on the caller, e.g. on its authorization or tenant, must
+// vary on the metadata identifying the caller or leave the method uncached
+func VaryMetadata(keys ...string) Option {
+	return func(i *interceptor) {
+		for _, k := range keys {
+			i.vary = append(i.vary, strings.ToLower(k))
+		}
+	}
+}
+
+// Clock -
+// Functional option to override the clock used to expire responses
+func Clock(clock cache.Clock) Option {
+	return func(i *interceptor) {
+		i.clock = clock
+	}
+}
+
+// Logger -
+// Functional option to log failures to read or write the cache, which never fail the call
+func Logger(l cache.Logger) Option {
+	return func(i *interceptor) {
+		i.logger = l
+	}
+}
+
+// Metrics -
+// Functional option registering with reg the go_cache_grpc_calls_total counter, labelled with
+// the method and the result of the lookup: hit, miss or bypass
+func Metrics(reg prometheus.Registerer) Option {
+	return func(i *interceptor) {
+		i.results = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "go_cache",
+			Name:      "grpc_calls_total",
+			Help:      "Number of cacheable gRPC calls by cache lookup result.",
+		}, []string{"method", "result"})
+		if err := reg.Register(i.results); err != nil {
+			if already, ok := err.(prometheus.AlreadyRegisteredError); ok {
+				i.results = already.ExistingCollector.(*prometheus.CounterVec)
+			}
+		}
+	}
+}
+
+// UnaryClientInterceptor -
+// Returns a client interceptor serving the responses of the configured methods from c and
+// caching those returned by the server. Requests and responses must be protobuf messages. Calls
+// whose outgoing metadata holds "cache-control: no-cache" skip the cache
+func UnaryClientInterceptor(c cache.Cache, opts ...Option) grpc.UnaryClientInterceptor {
+	i := newInterceptor(c, opts)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		ttl, ok := i.methods[method]
+		if !ok {
+			return invoker(ctx, method, req, reply, cc, callOpts...)
+		}
+		md, _ := metadata.FromOutgoingContext(ctx)
+		if bypassed(md) {
+			i.record(method, "bypass")
+			return invoker(ctx, method, req, reply, cc, callOpts...)
+		}
+		key, err := i.key(method, req, md)
+		if err != nil {
+			i.logger.Error("unable to derive grpc cache key", "method", method, "error", err)
+			return invoker(ctx, method, req, reply, cc, callOpts...)
+		}
+		if m, ok := reply.(proto.Message); ok && i.get(key, m) != nil {
+			i.record(method, "hit")
+			return nil
+		}
+		i.record(method, "miss")
+		if err := invoker(ctx, method, req, reply, cc, callOpts...); err != nil {
+			return err
+		}
+		i.put(key, reply, ttl)
+		return nil
+	}
+}
+
+// UnaryServerInterceptor -
+// Returns a server interceptor answering calls to the configured methods from c without running
+// the handler, and caching the responses of the handler otherwise. Requests and responses must be
+// protobuf messages registered with protoregistry.GlobalTypes, as generated code does. Calls whose
+// incoming metadata holds "cache-control: no-cache" skip the cache.
+//
+// Responses are keyed on the method and the request only, so a response computed for one caller
+// is served to every other caller sending the same request, bypassing the authorization of the
+// handler. Use VaryMetadata with the keys identifying the caller for methods whose responses
+// depend on it
+func UnaryServerInterceptor(c cache.Cache, opts ...Option) grpc.UnaryServerInterceptor {
+	i := newInterceptor(c, opts)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ttl, ok := i.methods[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		if bypassed(md) {
+			i.record(info.FullMethod, "bypass")
+			return handler(ctx, req)
+		}
+		key, err := i.key(info.FullMethod, req, md)
+		if err != nil {
+			i.logger.Error("unable to derive grpc cache key", "method", info.FullMethod, "error", err)
+			return handler(ctx, req)
+		}
+		if res := i.get(key, nil); res != nil {
+			i.record(info.FullMethod, "hit")
+			return res, nil
+		}
+		i.record(info.FullMethod, "miss")
+		res, err := handler(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		i.put(key, res, ttl)
+		return res, nil
+	}
+}
+
+// bypassed -
+// Reports whether the call metadata asks not to be served from the cache
+func bypassed(md metadata.MD) bool {
+	for _, v := range md.Get("cache-control") {
+		if strings.Contains(v, "no-cache") || strings.Contains(v, "no-store") {
+			return true
+		}
+	}
+	return false
+}
+
+// record -
+// Counts a cache lookup result of method
+func (i *interceptor) record(method, result string) {
+	if i.results != nil {
+		i.results.WithLabelValues(method, result).Inc()
+	}
+}
+
+// key -
+// Returns the cache key of a call, the hex encoded sha256 of the deterministic encoding of
+// the request and of the values of the VaryMetadata keys, under the method name
+func (i *interceptor) key(method string, req any, md metadata.MD) (string, error) {
+	m, ok := req.(proto.Message)
+	if !ok {
+		return "", fmt.Errorf("request of type %T is not a protobuf message", req)
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	if len(i.vary) > 0 {
+		data = append(binary.AppendUvarint(nil, uint64(len(data))), data...)
+	}
+	for _, k := range i.vary {
+		values := md.Get(k)
+		data = binary.AppendUvarint(data, uint64(len(values)))
+		for _, v := range values {
+			data = binary.AppendUvarint(data, uint64(len(v)))
+			data = append(data, v...)
+		}
+	}
+	sum := sha256.Sum256(data)
+	return cache.Key("grpccache", method, hex.EncodeToString(sum[:])), nil
+}
+
+// get -
+// Decodes the cached response of key into reply, or a new message of the cached type when reply
+// is nil. Returns nil when the response is missing, expired or cannot be decoded
+func (i *interceptor) get(key string, reply proto.Message) proto.Message {
+	data, err := i.c.Get(key)
+	if err != nil || len(data) < 8 {
+		return nil
+	}
+	if expires := int64(binary.BigEndian.Uint64(data)); expires != 0 && i.clock.Now().UnixNano() >= expires {
+		return nil
+	}
+	n, l := binary.Uvarint(data[8:])
+	if l <= 0 || uint64(len(data)-8-l) < n {
+		i.logger.Error("unable to decode cached grpc response", "key", key, "error", "malformed header")
+		return nil
+	}
+	name, payload := protoreflect.FullName(data[8+l:8+l+int(n)]), data[8+l+int(n):]
+	if reply == nil {
+		mt, err := protoregistry.GlobalTypes.FindMessageByName(name)
+		if err != nil {
+			i.logger.Error("unable to decode cached grpc response", "key", key, "error", err)
+			return nil
+		}
+		reply = mt.New().Interface()
+	} else if reply.ProtoReflect().Descriptor().FullName() != name {
+		return nil
+	}
+	if err := proto.Unmarshal(payload, reply); err != nil {
+		i.logger.Error("unable to decode cached grpc response", "key", key, "error", err)
+		return nil
+	}
+	return reply
+}
+
+// put -
+// Saves the response under key, prefixed by its expiry as unix nanoseconds, zero when it lives
+// as long as the cache window, and by the full name of its message type
+func (i *interceptor) put(key string, reply any, ttl time.Duration) {
+	m, ok := reply.(proto.Message)
+	if !ok {
+		return
+	}
+	name := m.ProtoReflect().Descriptor().FullName()
+	data := make([]byte, 8, 8+binary.MaxVarintLen64+len(name)+proto.Size(m))
+	if ttl > 0 {
+		binary.BigEndian.PutUint64(data, uint64(i.clock.Now().Add(ttl).UnixNano()))
+	}
+	data = binary.AppendUvarint(data, uint64(len(name)))
+	data = append(data, name...)
+	data, err := proto.MarshalOptions{}.MarshalAppend(data, m)
+	if err == nil {
+		err = i.c.Put(key, data)
+	}
+	if err != nil {
+		i.logger.Error("unable to cache grpc response", "key", key, "error", err)
+	}
+}
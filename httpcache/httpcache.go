@@ -0,0 +1,276 @@
+// Package httpcache caches the responses of HTTP handlers, so that repeated GET requests for the
+// same resource are served without running the handler.
+package httpcache
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+const defaultMaxBodySize = 1 << 20
+
+// response is the cached form of a handler response
+type response struct {
+	Status  int
+	Header  http.Header
+	Body    []byte
+	Expires time.Time         // zero when the response lives as long as the cache window
+	Vary    map[string]string // request header values selected by the Vary response header
+}
+
+// middleware serves cached responses and caches those of the next handler.
+type middleware struct {
+	c           *cache.ObjectCache
+	next        http.Handler
+	ttl         time.Duration
+	vary        []string
+	statuses    map[int]bool
+	maxBodySize int
+	bypass      func(r *http.Request) bool
+	clock       cache.Clock
+	logger      cache.Logger
+}
+
+type Option func(*middleware)
+
+// Middleware -
+// Returns a middleware caching the responses of GET requests in c, keyed on the method, the URL
+// and the request headers listed through Vary. Status, headers and body of successful responses are
+// stored, unless the response is marked no-store or private or sets a cookie. A cached response is
+// only served to requests matching the headers named by its own Vary header. Requests marked no-cache
+// or no-store, matching Bypass, or carrying Authorization or Cookie headers not listed through Vary
+// skip the cache, as the cache is shared between users. Every response carries an X-Cache header set
+// to HIT, MISS or BYPASS
+func Middleware(c cache.Cache, opts ...Option) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		m := &middleware{
+			c:           cache.WithCodec(c, cache.Gob),
+			next:        next,
+			statuses:    map[int]bool{http.StatusOK: true},
+			maxBodySize: defaultMaxBodySize,
+			clock:       cache.SystemClock{},
+			logger:      cache.NopLogger{},
+		}
+		for _, opt := range opts {
+			opt(m)
+		}
+		return m
+	}
+}
+
+// TTL -
+// Functional option to specify how long responses are served from the cache, defaults to the
+// window of the cache. Durations longer than the window have no effect
+func TTL(d time.Duration) Option {
+	return func(m *middleware) {
+		m.ttl = d
+	}
+}
+
+// Vary -
+// Functional option listing the request headers, such as Accept-Encoding or Authorization, whose
+// values select distinct cached responses for the same URL. Listing Authorization or Cookie caches
+// the responses of authenticated requests, one per credential
+func Vary(headers ...string) Option {
+	return func(m *middleware) {
+		for _, h := range headers {
+			m.vary = append(m.vary, http.CanonicalHeaderKey(h))
+		}
+	}
+}
+
+// Statuses -
+// Functional option listing the status codes of cacheable responses, defaults to 200 only
+func Statuses(codes ...int) Option {
+	return func(m *middleware) {
+		m.statuses = map[int]bool{}
+		for _, code := range codes {
+			m.statuses[code] = true
+		}
+	}
+}
+
+// MaxBodySize -
+// Functional option to specify the largest response body cached in bytes, defaults to 1MiB
+func MaxBodySize(n int) Option {
+	return func(m *middleware) {
+		m.maxBodySize = n
+	}
+}
+
+// Bypass -
+// Functional option to skip the cache for requests matching fn, for instance those of admin pages
+func Bypass(fn func(r *http.Request) bool) Option {
+	return func(m *middleware) {
+		m.bypass = fn
+	}
+}
+
+// Clock -
+// Functional option to override the clock used to expire responses
+func Clock(clock cache.Clock) Option {
+	return func(m *middleware) {
+		m.clock = clock
+	}
+}
+
+// Logger -
+// Functional option to log failures to read or write the cache, which never fail the request
+func Logger(l cache.Logger) Option {
+	return func(m *middleware) {
+		m.logger = l
+	}
+}
+
+// ServeHTTP -
+// Serves the cached response of the request or runs the next handler, caching its response
+func (m *middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || m.bypassed(r) {
+		w.Header().Set("X-Cache", "BYPASS")
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	key := m.key(r)
+	var cached response
+	if err := m.c.GetObject(key, &cached); err == nil && (cached.Expires.IsZero() || m.clock.Now().Before(cached.Expires)) && cached.matches(r) {
+		header := w.Header()
+		for name, values := range cached.Header {
+			header[name] = values
+		}
+		header.Set("X-Cache", "HIT")
+		w.WriteHeader(cached.Status)
+		w.Write(cached.Body)
+		return
+	}
+
+	w.Header().Set("X-Cache", "MISS")
+	rec := &recorder{ResponseWriter: w, limit: m.maxBodySize}
+	m.next.ServeHTTP(rec, r)
+	if !m.cacheable(rec) {
+		return
+	}
+	res := response{
+		Status: rec.status,
+		Header: w.Header().Clone(),
+		Body:   rec.body.Bytes(),
+		Vary:   map[string]string{},
+	}
+	res.Header.Del("X-Cache")
+	for _, name := range varyHeaders(res.Header) {
+		res.Vary[name] = strings.Join(r.Header.Values(name), ",")
+	}
+	if m.ttl > 0 {
+		res.Expires = m.clock.Now().Add(m.ttl)
+	}
+	if err := m.c.PutObject(key, res); err != nil {
+		m.logger.Error("unable to cache http response", "key", key, "error", err)
+	}
+}
+
+// bypassed -
+// Reports whether the request asks not to be served from the cache
+func (m *middleware) bypassed(r *http.Request) bool {
+	if m.bypass != nil && m.bypass(r) {
+		return true
+	}
+	for _, name := range []string{"Authorization", "Cookie"} {
+		if r.Header.Get(name) != "" && !m.varies(name) {
+			return true
+		}
+	}
+	cc := r.Header.Get("Cache-Control")
+	return strings.Contains(cc, "no-cache") || strings.Contains(cc, "no-store")
+}
+
+// cacheable -
+// Reports whether the recorded response may be cached
+func (m *middleware) cacheable(rec *recorder) bool {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if !m.statuses[rec.status] || rec.overflow {
+		return false
+	}
+	header := rec.Header()
+	cc := header.Get("Cache-Control")
+	if strings.Contains(cc, "no-store") || strings.Contains(cc, "private") || header.Get("Set-Cookie") != "" {
+		return false
+	}
+	for _, name := range varyHeaders(header) {
+		if name == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// varies -
+// Reports whether the request header name is listed through Vary
+func (m *middleware) varies(name string) bool {
+	for _, h := range m.vary {
+		if h == name {
+			return true
+		}
+	}
+	return false
+}
+
+// matches -
+// Reports whether the request carries the header values the cached response varies on
+func (res response) matches(r *http.Request) bool {
+	for name, value := range res.Vary {
+		if strings.Join(r.Header.Values(name), ",") != value {
+			return false
+		}
+	}
+	return true
+}
+
+// key -
+// Returns the cache key of the request
+func (m *middleware) key(r *http.Request) string {
+	parts := []any{"httpcache", r.Method, r.URL.String()}
+	for _, h := range m.vary {
+		parts = append(parts, h+"="+strings.Join(r.Header.Values(h), ","))
+	}
+	return cache.Key(parts...)
+}
+
+// recorder writes the response through while keeping a copy of the status and body.
+type recorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	limit    int
+	overflow bool // the body exceeded the limit and is not kept
+}
+
+// WriteHeader -
+// Records the status before writing it through
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write -
+// Keeps a copy of the body up to the limit before writing it through
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if !rec.overflow {
+		if rec.body.Len()+len(p) > rec.limit {
+			rec.overflow = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(p)
+		}
+	}
+	return rec.ResponseWriter.Write(p)
+}
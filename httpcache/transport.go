@@ -0,0 +1,325 @@
+package httpcache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+// cacheableStatuses are the status codes cacheable by default according to RFC 7231
+var cacheableStatuses = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// entry is the cached form of an upstream response
+type entry struct {
+	Status   int
+	Header   http.Header
+	Body     []byte
+	Vary     map[string]string // request header values selected by the Vary response header
+	StoredAt time.Time
+}
+
+// Transport is an http.RoundTripper storing upstream responses in a cache, following the
+// freshness and validation rules of RFC 7234. As the cache may be shared, responses to credentialed
+// requests are only stored when the server allows shared caching. Fresh responses are served
+// without contacting the server, stale responses carrying an ETag or Last-Modified header are
+// revalidated with a conditional request. Cached responses carry an X-Cache header set to HIT,
+// or REVALIDATED when the server confirmed them
+type Transport struct {
+	c           *cache.ObjectCache
+	next        http.RoundTripper
+	maxBodySize int
+	clock       cache.Clock
+	logger      cache.Logger
+}
+
+type TransportOption func(*Transport)
+
+// NewTransport -
+// Initialises a new Transport caching the responses of GET requests in c, requests are sent
+// through http.DefaultTransport unless overridden with Upstream
+func NewTransport(c cache.Cache, opts ...TransportOption) *Transport {
+	t := &Transport{
+		c:           cache.WithCodec(c, cache.Gob),
+		next:        http.DefaultTransport,
+		maxBodySize: defaultMaxBodySize,
+		clock:       cache.SystemClock{},
+		logger:      cache.NopLogger{},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Upstream -
+// Transport option to specify the round tripper sending requests to the server
+func Upstream(rt http.RoundTripper) TransportOption {
+	return func(t *Transport) {
+		t.next = rt
+	}
+}
+
+// TransportMaxBodySize -
+// Transport option to specify the largest response body cached in bytes, defaults to 1MiB
+func TransportMaxBodySize(n int) TransportOption {
+	return func(t *Transport) {
+		t.maxBodySize = n
+	}
+}
+
+// TransportClock -
+// Transport option to override the clock used to compute the age of responses
+func TransportClock(clock cache.Clock) TransportOption {
+	return func(t *Transport) {
+		t.clock = clock
+	}
+}
+
+// TransportLogger -
+// Transport option to log failures to read or write the cache, which never fail the request
+func TransportLogger(l cache.Logger) TransportOption {
+	return func(t *Transport) {
+		t.logger = l
+	}
+}
+
+// Client -
+// Returns an http.Client sending its requests through the transport
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// RoundTrip -
+// Serves GET requests from the cache while the cached response is fresh, revalidates it once
+// stale and stores cacheable upstream responses. Successful unsafe requests invalidate the
+// cached response of their URL
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := cache.Key("httpcache", "transport", req.URL.String())
+	if req.Method != http.MethodGet {
+		res, err := t.next.RoundTrip(req)
+		if err == nil && req.Method != http.MethodHead && req.Method != http.MethodOptions && res.StatusCode < 400 {
+			t.c.Delete(key)
+		}
+		return res, err
+	}
+	reqCC := directives(req.Header)
+	if _, ok := reqCC["no-store"]; ok {
+		return t.next.RoundTrip(req)
+	}
+
+	var cached entry
+	found := t.c.GetObject(key, &cached) == nil && cached.matches(req)
+	if found {
+		_, noCache := reqCC["no-cache"]
+		_, mustRevalidate := directives(cached.Header)["no-cache"]
+		if !noCache && !mustRevalidate && t.age(cached) < freshness(cached) {
+			return cached.response(req, "HIT", t.age(cached)), nil
+		}
+		if etag := cached.Header.Get("ETag"); etag != "" || cached.Header.Get("Last-Modified") != "" {
+			req = req.Clone(req.Context())
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+			if lm := cached.Header.Get("Last-Modified"); lm != "" {
+				req.Header.Set("If-Modified-Since", lm)
+			}
+		} else {
+			found = false
+		}
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if found && res.StatusCode == http.StatusNotModified {
+		res.Body.Close()
+		for name, values := range res.Header {
+			cached.Header[name] = values
+		}
+		cached.StoredAt = t.clock.Now()
+		t.store(key, cached)
+		return cached.response(req, "REVALIDATED", 0), nil
+	}
+	if !storable(req, res) {
+		return res, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, int64(t.maxBodySize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response body: %w", err)
+	}
+	if len(body) > t.maxBodySize {
+		res.Body = readCloser{io.MultiReader(bytes.NewReader(body), res.Body), res.Body}
+		return res, nil
+	}
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	e := entry{
+		Status:   res.StatusCode,
+		Header:   res.Header.Clone(),
+		Body:     body,
+		Vary:     map[string]string{},
+		StoredAt: t.clock.Now(),
+	}
+	for _, name := range varyHeaders(res.Header) {
+		e.Vary[name] = strings.Join(req.Header.Values(name), ",")
+	}
+	t.store(key, e)
+	return res, nil
+}
+
+// store -
+// Saves the entry, failures are only logged
+func (t *Transport) store(key string, e entry) {
+	if err := t.c.PutObject(key, e); err != nil {
+		t.logger.Error("unable to cache http response", "key", key, "error", err)
+	}
+}
+
+// age -
+// Returns the current age of the cached response, including the Age it had when stored
+func (t *Transport) age(e entry) time.Duration {
+	age := t.clock.Now().Sub(e.StoredAt)
+	if s, err := strconv.Atoi(e.Header.Get("Age")); err == nil && s > 0 {
+		age += time.Duration(s) * time.Second
+	}
+	return age
+}
+
+// matches -
+// Reports whether the request carries the header values the cached response varies on
+func (e entry) matches(req *http.Request) bool {
+	for name, value := range e.Vary {
+		if strings.Join(req.Header.Values(name), ",") != value {
+			return false
+		}
+	}
+	return true
+}
+
+// response -
+// Builds the http.Response of the cached entry
+func (e entry) response(req *http.Request, status string, age time.Duration) *http.Response {
+	header := e.Header.Clone()
+	header.Set("X-Cache", status)
+	if age > 0 {
+		header.Set("Age", strconv.Itoa(int(age/time.Second)))
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// freshness -
+// Returns the freshness lifetime of the cached response from its max-age directive, its Expires
+// header or, lacking both, a tenth of the time since its Last-Modified date
+func freshness(e entry) time.Duration {
+	if maxAge, ok := directives(e.Header)["max-age"]; ok {
+		if s, err := strconv.Atoi(maxAge); err == nil {
+			return time.Duration(s) * time.Second
+		}
+		return 0
+	}
+	date, err := http.ParseTime(e.Header.Get("Date"))
+	if err != nil {
+		date = e.StoredAt
+	}
+	if expires := e.Header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return t.Sub(date)
+	}
+	if lm, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil && lm.Before(date) {
+		return date.Sub(lm) / 10
+	}
+	return 0
+}
+
+// storable -
+// Reports whether the upstream response may be stored and reused later. Responses to requests
+// carrying Authorization are only stored when marked public or s-maxage, as the cache is keyed on
+// the URL alone and may be shared with other callers
+func storable(req *http.Request, res *http.Response) bool {
+	if !cacheableStatuses[res.StatusCode] {
+		return false
+	}
+	cc := directives(res.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	if req.Header.Get("Authorization") != "" {
+		_, public := cc["public"]
+		_, sMaxAge := cc["s-maxage"]
+		if !public && !sMaxAge {
+			return false
+		}
+	}
+	for _, name := range varyHeaders(res.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+	// without a freshness lifetime or a validator the response could never be reused
+	_, maxAge := cc["max-age"]
+	return maxAge || res.Header.Get("Expires") != "" || res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != ""
+}
+
+// directives -
+// Parses the Cache-Control header into its directives and their optional values
+func directives(h http.Header) map[string]string {
+	d := map[string]string{}
+	for _, part := range strings.Split(strings.Join(h.Values("Cache-Control"), ","), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		d[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return d
+}
+
+// varyHeaders -
+// Returns the canonical names listed by the Vary header
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, part := range strings.Split(strings.Join(h.Values("Vary"), ","), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			names = append(names, http.CanonicalHeaderKey(part))
+		}
+	}
+	return names
+}
+
+// readCloser reads from a reader while closing the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
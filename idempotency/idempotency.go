@@ -0,0 +1,363 @@
+// Package idempotency records the outcome of requests under their idempotency key, so a retried
+// request is answered with the recorded response instead of being processed twice.
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+const defaultLockTimeout = time.Minute
+
+var (
+	// ErrInProgress is returned by Begin while another request holding the key is being processed.
+	ErrInProgress = errors.New("idempotency key in progress")
+	// ErrFingerprintMismatch is returned by Begin when the key was used by a different request.
+	ErrFingerprintMismatch = errors.New("idempotency key reused with a different request")
+	// ErrKeyLost is returned by Complete when the key is no longer held by the request, as its record
+	// expired or another request took it over after the lock timeout.
+	ErrKeyLost = errors.New("idempotency key no longer held by the request")
+)
+
+// Adder is a cache saving a value only when its key is missing, as the memory and redis caches do.
+type Adder interface {
+	cache.Cache
+	Add(key string, val []byte) error
+}
+
+// State is the processing state of a request.
+type State string
+
+const (
+	InProgress State = "in_progress"
+	Completed  State = "completed"
+	Aborted    State = "aborted" // released without a response, the next request takes the key over
+)
+
+// Record is the state and, once completed, the response of a request.
+type Record struct {
+	State       State
+	Fingerprint string // identifies the request, a key reused with another fingerprint is refused
+	StartedAt   time.Time
+	Status      int
+	Header      http.Header
+	Body        []byte
+}
+
+// Store records requests under their idempotency key.
+type Store struct {
+	c           Adder
+	prefix      string
+	lockTimeout time.Duration
+	principal   func(r *http.Request) string
+	clock       cache.Clock
+	logger      cache.Logger
+}
+
+type Option func(*Store)
+
+// New -
+// Initialises a new Store on top of c, records live for the window of the cache
+func New(c Adder, opts ...Option) *Store {
+	s := &Store{
+		c:           c,
+		prefix:      "idempotency",
+		lockTimeout: defaultLockTimeout,
+		clock:       cache.SystemClock{},
+		logger:      cache.NopLogger{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// KeyPrefix -
+// Functional option to specify the prefix of the cache keys of records, defaults to "idempotency"
+func KeyPrefix(p string) Option {
+	return func(s *Store) {
+		s.prefix = p
+	}
+}
+
+// LockTimeout -
+// Functional option to specify how long a request may stay in progress before another request
+// with the same key takes over, for instance after the process handling it crashed. Defaults to a minute
+func LockTimeout(d time.Duration) Option {
+	return func(s *Store) {
+		s.lockTimeout = d
+	}
+}
+
+// Principal -
+// Functional option scoping the idempotency keys of the middleware to the caller identified by fn,
+// typically the authenticated user or API client. Without it keys are shared by every caller, and
+// a caller reusing the key of another with the same request is replayed the response of the other
+func Principal(fn func(r *http.Request) string) Option {
+	return func(s *Store) {
+		s.principal = fn
+	}
+}
+
+// Clock -
+// Functional option to override the clock timing requests in progress
+func Clock(clock cache.Clock) Option {
+	return func(s *Store) {
+		s.clock = clock
+	}
+}
+
+// Logger -
+// Functional option to log the failures of the middleware to record responses
+func Logger(l cache.Logger) Option {
+	return func(s *Store) {
+		s.logger = l
+	}
+}
+
+// Begin -
+// Marks the request identified by fingerprint as in progress under key. It returns the in-progress
+// record of the caller when it acquired the key and must process the request, then pass the record
+// to Complete or call Abort, the completed record when the request was already processed,
+// ErrInProgress while another request holds the key and ErrFingerprintMismatch when the key was
+// used by a different request. A request in progress for longer than the lock timeout is only
+// taken over when the cache implements cache.VersionedPutter, so two requests never both take over
+func (s *Store) Begin(key, fingerprint string) (*Record, error) {
+	rec := Record{
+		State:       InProgress,
+		Fingerprint: fingerprint,
+		StartedAt:   s.clock.Now(),
+	}
+	data, err := cache.JSON.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	k := s.key(key)
+	err = s.c.Add(k, data)
+	if err == nil {
+		return &rec, nil
+	}
+	if !errors.Is(err, cache.ErrKeyExists) {
+		return nil, err
+	}
+	var existing Record
+	raw, err := s.c.Get(k)
+	if err != nil {
+		// expired between Add and Get, the next attempt starts afresh
+		return nil, ErrInProgress
+	}
+	if err := cache.JSON.Unmarshal(raw, &existing); err != nil {
+		return nil, fmt.Errorf("unable to decode idempotency record: %w", err)
+	}
+	if existing.State != Aborted && existing.Fingerprint != fingerprint {
+		return nil, ErrFingerprintMismatch
+	}
+	if existing.State == Completed {
+		return &existing, nil
+	}
+	if existing.State != Aborted && s.clock.Now().Sub(existing.StartedAt) < s.lockTimeout {
+		return nil, ErrInProgress
+	}
+	// the request holding the key timed out, take over unless another request already did
+	v, ok := s.c.(cache.VersionedPutter)
+	if !ok {
+		return nil, ErrInProgress
+	}
+	if err := s.swap(v, k, raw, data); err != nil {
+		if errors.Is(err, ErrKeyLost) {
+			return nil, ErrInProgress
+		}
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Complete -
+// Records the response of the request holding key, later requests with the key receive it. held
+// is the in-progress record returned by Begin, the response is only recorded while it is still the
+// record of key, ErrKeyLost is returned otherwise
+func (s *Store) Complete(key string, held *Record, status int, header http.Header, body []byte) error {
+	if held == nil || held.State != InProgress {
+		return errors.New("unable to complete idempotent request without its in-progress record")
+	}
+	expected, err := cache.JSON.Marshal(held)
+	if err != nil {
+		return err
+	}
+	data, err := cache.JSON.Marshal(Record{
+		State:       Completed,
+		Fingerprint: held.Fingerprint,
+		StartedAt:   held.StartedAt,
+		Status:      status,
+		Header:      header,
+		Body:        body,
+	})
+	if err != nil {
+		return err
+	}
+	k := s.key(key)
+	if v, ok := s.c.(cache.VersionedPutter); ok {
+		return s.swap(v, k, expected, data)
+	}
+	// without versions no other request can take the key over, it can only expire
+	current, err := s.c.Get(k)
+	if err != nil || !bytes.Equal(current, expected) {
+		return ErrKeyLost
+	}
+	return s.c.Put(k, data)
+}
+
+// swap -
+// Replaces the record of k with data as long as it still holds expected, ErrKeyLost otherwise
+func (s *Store) swap(v cache.VersionedPutter, k string, expected, data []byte) error {
+	current, version, err := v.GetVersion(k)
+	if err != nil || !bytes.Equal(current, expected) {
+		return ErrKeyLost
+	}
+	if err := v.PutIfVersion(k, data, version); err != nil {
+		if errors.Is(err, cache.ErrVersionMismatch) {
+			return ErrKeyLost
+		}
+		return err
+	}
+	return nil
+}
+
+// Abort -
+// Releases key without recording a response, so the request can be retried. held is the in-progress
+// record returned by Begin, the key is only released while it is still the record of key, so a
+// request which lost the key never releases the record of the request which took it over.
+// ErrKeyLost is returned otherwise
+func (s *Store) Abort(key string, held *Record) error {
+	if held == nil || held.State != InProgress {
+		return errors.New("unable to abort idempotent request without its in-progress record")
+	}
+	expected, err := cache.JSON.Marshal(held)
+	if err != nil {
+		return err
+	}
+	k := s.key(key)
+	if v, ok := s.c.(cache.VersionedPutter); ok {
+		data, err := cache.JSON.Marshal(Record{State: Aborted, StartedAt: held.StartedAt})
+		if err != nil {
+			return err
+		}
+		return s.swap(v, k, expected, data)
+	}
+	// without versions no other request can take the key over, it can only expire
+	current, err := s.c.Get(k)
+	if err != nil || !bytes.Equal(current, expected) {
+		return ErrKeyLost
+	}
+	return s.c.Delete(k)
+}
+
+// key -
+// Returns the cache key of the record of an idempotency key
+func (s *Store) key(key string) string {
+	return cache.Key(s.prefix, key)
+}
+
+// Middleware -
+// Returns a handler processing requests carrying an Idempotency-Key header at most once. The
+// fingerprint of a request covers its method, path and body, and keys are shared by every caller
+// unless scoped with Principal. Replayed responses carry an
+// Idempotent-Replayed header, requests whose key is in progress are answered with 409 Conflict
+// and keys reused with a different request with 422 Unprocessable Entity. Responses with a 5xx
+// status are not recorded, so such requests can be retried
+func (s *Store) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "unable to read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.New()
+		fmt.Fprintf(sum, "%s %s\n", r.Method, r.URL.Path)
+		sum.Write(body)
+		fingerprint := hex.EncodeToString(sum.Sum(nil))
+
+		if s.principal != nil {
+			key = cache.Key(s.principal(r), key)
+		}
+		rec, err := s.Begin(key, fingerprint)
+		switch {
+		case errors.Is(err, ErrInProgress):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, ErrFingerprintMismatch):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case err != nil:
+			s.logger.Error("unable to begin idempotent request", "key", key, "error", err)
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		case rec.State == Completed:
+			for name, values := range rec.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(rec.Status)
+			w.Write(rec.Body)
+			return
+		}
+
+		rw := &recorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			if p := recover(); p != nil {
+				s.Abort(key, rec)
+				panic(p)
+			}
+		}()
+		next.ServeHTTP(rw, r)
+		if rw.status >= 500 {
+			if err := s.Abort(key, rec); err != nil {
+				s.logger.Error("unable to release idempotency key", "key", key, "error", err)
+			}
+			return
+		}
+		if err := s.Complete(key, rec, rw.status, w.Header().Clone(), rw.body.Bytes()); err != nil {
+			s.logger.Error("unable to record idempotent response", "key", key, "error", err)
+		}
+	})
+}
+
+// recorder writes the response through while keeping a copy of the status and body.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+// WriteHeader -
+// Records the status before writing it through
+func (rw *recorder) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Write -
+// Keeps a copy of the body before writing it through
+func (rw *recorder) Write(p []byte) (int, error) {
+	rw.wroteHeader = true
+	rw.body.Write(p)
+	return rw.ResponseWriter.Write(p)
+}
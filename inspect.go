@@ -0,0 +1,18 @@
+package cache
+
+import "time"
+
+// EntryInfo describes a cache entry along with its access metadata, as returned by Inspect.
+type EntryInfo struct {
+	Key        string
+	Size       int           // bytes of the value
+	TTL        time.Duration // time left before the entry goes stale, zero when it never does
+	Hits       uint64        // reads which found the entry, zero unless the cache tracks accesses
+	LastAccess time.Time     // last read which found the entry, zero unless the cache tracks accesses
+}
+
+// Inspector is implemented by caches reporting metadata about their entries, e.g. to tune TTLs.
+type Inspector interface {
+	// Inspect returns the metadata of key, or an error wrapping ErrKeyNotFound when it holds no value.
+	Inspect(key string) (EntryInfo, error)
+}
@@ -0,0 +1,21 @@
+package cache
+
+// MultiWarmChecker is implemented by caches checking many keys in a single lock pass or round trip.
+type MultiWarmChecker interface {
+	// IsWarmMulti reports for every key whether it is cached inside the time window.
+	IsWarmMulti(keys []string) map[string]bool
+}
+
+// IsWarmMulti -
+// Reports for every key whether it is cached inside the time window of c, in a single pass when c
+// implements MultiWarmChecker and through one IsWarm call per key otherwise
+func IsWarmMulti(c Cache, keys []string) map[string]bool {
+	if m, ok := c.(MultiWarmChecker); ok {
+		return m.IsWarmMulti(keys)
+	}
+	warm := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		warm[key] = c.IsWarm(key)
+	}
+	return warm
+}
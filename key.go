@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultKeyBuilder is the KeyBuilder used by Key, joining parts with a colon.
+var DefaultKeyBuilder = KeyBuilder{Separator: ":"}
+
+// KeyBuilder builds cache keys from parts of any type, each encoded the same way on every call.
+type KeyBuilder struct {
+	Separator string // placed between parts, defaults to a colon
+	Namespace string // prefix of every key, joined to the parts by the separator
+}
+
+// Key -
+// Builds a key from parts using DefaultKeyBuilder, e.g. Key("user", 42, "profile") returns "user:42:profile"
+func Key(parts ...any) string {
+	return DefaultKeyBuilder.Key(parts...)
+}
+
+// Key -
+// Builds a key from the namespace and parts. Strings are used as is with "%" and the separator
+// percent-encoded, so distinct parts never produce the same key, numbers and booleans use their
+// strconv form, times their UTC RFC 3339 form, byte slices their hex form, and other values their
+// MarshalText, String or JSON encoding in that order of preference
+func (b KeyBuilder) Key(parts ...any) string {
+	sep := b.separator()
+	var sb strings.Builder
+	if b.Namespace != "" {
+		sb.WriteString(b.Namespace)
+	}
+	for i, part := range parts {
+		if i > 0 || b.Namespace != "" {
+			sb.WriteString(sep)
+		}
+		sb.WriteString(escapeKeyPart(encodeKeyPart(part), sep))
+	}
+	return sb.String()
+}
+
+// Sub -
+// Returns a builder whose keys are nested under the namespace and parts of b
+func (b KeyBuilder) Sub(parts ...any) KeyBuilder {
+	return KeyBuilder{
+		Separator: b.Separator,
+		Namespace: b.Key(parts...),
+	}
+}
+
+// separator -
+// Returns the configured separator or the default colon
+func (b KeyBuilder) separator() string {
+	if b.Separator == "" {
+		return ":"
+	}
+	return b.Separator
+}
+
+// encodeKeyPart -
+// Returns the stable string form of part
+func encodeKeyPart(part any) string {
+	switch v := part.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return hex.EncodeToString(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.FormatInt(int64(v), 10)
+	case int8:
+		return strconv.FormatInt(int64(v), 10)
+	case int16:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	case encoding.TextMarshaler:
+		if text, err := v.MarshalText(); err == nil {
+			return string(text)
+		}
+	case fmt.Stringer:
+		return v.String()
+	}
+	// struct fields keep their declaration order and map keys are sorted, making JSON stable
+	if data, err := json.Marshal(part); err == nil {
+		return string(data)
+	}
+	return fmt.Sprintf("%v", part)
+}
+
+// escapeKeyPart -
+// Percent-encodes "%" and every occurrence of sep inside part
+func escapeKeyPart(part, sep string) string {
+	if !strings.Contains(part, "%") && !strings.Contains(part, sep) {
+		return part
+	}
+	part = strings.ReplaceAll(part, "%", "%25")
+	var encoded strings.Builder
+	for i := 0; i < len(sep); i++ {
+		if sep[i] == '%' {
+			encoded.WriteString("%25")
+			continue
+		}
+		fmt.Fprintf(&encoded, "%%%02X", sep[i])
+	}
+	return strings.ReplaceAll(part, sep, encoded.String())
+}
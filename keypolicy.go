@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidKey is returned by WithKeyPolicy when a key breaks the policy and is not hashed.
+var ErrInvalidKey = errors.New("invalid cache key")
+
+// hashedKeyPrefix prefixes the keys hashed by WithKeyPolicy
+const hashedKeyPrefix = "sha256:"
+
+// KeyPolicy describes the keys accepted by a cache.
+type KeyPolicy struct {
+	MaxLength int               // longest key in bytes, unlimited when zero
+	Allowed   func(r rune) bool // reports whether a key may contain r, every valid UTF-8 rune is allowed when nil
+	Hash      bool              // keys breaking the policy are hashed with sha256 rather than rejected with ErrInvalidKey
+}
+
+// PrintableASCII -
+// Reports whether r is a printable ASCII character other than a space, the characters safe in
+// memcached keys and redis commands typed by hand
+func PrintableASCII(r rune) bool {
+	return r > ' ' && r <= unicode.MaxASCII && r != 0x7f
+}
+
+// keyPolicy validates and hashes the keys of the wrapped cache.
+type keyPolicy struct {
+	Wrapper
+	policy KeyPolicy
+}
+
+// WithKeyPolicy -
+// Wraps c so that every key is checked against policy. Keys breaking it are rejected with
+// ErrInvalidKey or, when the policy hashes them, replaced by "sha256:" followed by the hex encoded
+// sha256 of the key, letting callers use URLs or SQL statements as keys. Values of hashed keys are
+// stored along with the original key, which Get verifies
+func WithKeyPolicy(c Cache, policy KeyPolicy) Cache {
+	return &keyPolicy{
+		Wrapper: Wrapper{Cache: c},
+		policy:  policy,
+	}
+}
+
+// check -
+// Returns the reason key breaks the policy, or nil
+func (k *keyPolicy) check(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+	if k.policy.MaxLength > 0 && len(key) > k.policy.MaxLength {
+		return fmt.Errorf("%w: %d bytes long, limit is %d", ErrInvalidKey, len(key), k.policy.MaxLength)
+	}
+	for i, r := range key {
+		if r == utf8.RuneError || (k.policy.Allowed != nil && !k.policy.Allowed(r)) {
+			return fmt.Errorf("%w: character %q at offset %d", ErrInvalidKey, r, i)
+		}
+	}
+	return nil
+}
+
+// key -
+// Returns the key to use with the wrapped cache and whether it was hashed
+func (k *keyPolicy) key(key string) (string, bool, error) {
+	err := k.check(key)
+	if err == nil {
+		return key, false, nil
+	}
+	if !k.policy.Hash || key == "" {
+		return "", false, err
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hashedKeyPrefix + hex.EncodeToString(sum[:]), true, nil
+}
+
+// Put -
+// Saves the value under the key or its hash
+func (k *keyPolicy) Put(key string, val []byte) error {
+	stored, hashed, err := k.key(key)
+	if err != nil {
+		return err
+	}
+	if hashed {
+		data := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(key)+len(val)), uint64(len(key)))
+		val = append(append(data, key...), val...)
+	}
+	return k.Cache.Put(stored, val)
+}
+
+// Get -
+// Fetches the value saved under the key or its hash, verifying the original key of hashed keys
+func (k *keyPolicy) Get(key string) ([]byte, error) {
+	stored, hashed, err := k.key(key)
+	if err != nil {
+		return nil, err
+	}
+	val, err := k.Cache.Get(stored)
+	if err != nil || !hashed {
+		return val, err
+	}
+	n, l := binary.Uvarint(val)
+	if l <= 0 || uint64(len(val)-l) < n {
+		return nil, fmt.Errorf("unable to read value of hashed key %s: malformed header", stored)
+	}
+	if original := string(val[l : l+int(n)]); original != key {
+		return nil, fmt.Errorf("unable to retrieve value: hashed key %s belongs to another key", stored)
+	}
+	return val[l+int(n):], nil
+}
+
+// Delete -
+// Deletes the value saved under the key or its hash
+func (k *keyPolicy) Delete(key string) error {
+	stored, _, err := k.key(key)
+	if err != nil {
+		return err
+	}
+	return k.Cache.Delete(stored)
+}
+
+// IsWarm -
+// Determines if the key or its hash holds a value inside the time window, invalid keys never do
+func (k *keyPolicy) IsWarm(key string) bool {
+	stored, _, err := k.key(key)
+	return err == nil && k.Cache.IsWarm(stored)
+}
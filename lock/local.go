@@ -0,0 +1,80 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// held is an in-process lock
+type held struct {
+	token   string
+	expires time.Time
+}
+
+// pruneInterval is how often acquire drops the expired locks
+const pruneInterval = time.Minute
+
+// localBackend holds locks inside the process, for caches which cannot share them.
+type localBackend struct {
+	mutex  sync.Mutex
+	locks  map[string]held
+	pruned time.Time // last time the expired locks were dropped
+}
+
+// newLocal -
+// Returns an empty in-process backend
+func newLocal() *localBackend {
+	return &localBackend{
+		locks: map[string]held{},
+	}
+}
+
+// acquire -
+// Takes the lock when it is free or expired
+func (b *localBackend) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(b.pruned) >= pruneInterval {
+		for k, h := range b.locks {
+			if !now.Before(h.expires) {
+				delete(b.locks, k)
+			}
+		}
+		b.pruned = now
+	}
+	if h, ok := b.locks[key]; ok && now.Before(h.expires) {
+		return false, nil
+	}
+	b.locks[key] = held{token: token, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// release -
+// Frees the lock when it is held with the token
+func (b *localBackend) release(ctx context.Context, key, token string) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	h, ok := b.locks[key]
+	if !ok || h.token != token || !time.Now().Before(h.expires) {
+		return false, nil
+	}
+	delete(b.locks, key)
+	return true, nil
+}
+
+// extend -
+// Resets the expiry of the lock when it is held with the token
+func (b *localBackend) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	h, ok := b.locks[key]
+	now := time.Now()
+	if !ok || h.token != token || !now.Before(h.expires) {
+		return false, nil
+	}
+	h.expires = now.Add(ttl)
+	b.locks[key] = h
+	return true, nil
+}
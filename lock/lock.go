@@ -0,0 +1,215 @@
+// Package lock provides short-lived named locks next to a cache: distributed locks held in redis
+// with SET NX PX, following the Redlock algorithm across several independent instances, and
+// in-process locks next to the memory cache.
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+	"github.com/pedreviljoen/go-cache/memory"
+	cacheredis "github.com/pedreviljoen/go-cache/redis"
+)
+
+const defaultRetryInterval = time.Millisecond * 50
+
+var (
+	// ErrNotAcquired is returned by TryAcquire when the lock is held by someone else.
+	ErrNotAcquired = errors.New("lock not acquired")
+	// ErrNotHeld is returned by Release and Extend when the lock expired or was taken over.
+	ErrNotHeld = errors.New("lock not held")
+	// ErrUnsupportedCache is returned by New for caches which can neither share locks across
+	// processes nor are known to be local to the process.
+	ErrUnsupportedCache = errors.New("unable to hold locks next to this cache")
+)
+
+// unwrapper is implemented by cache wrappers exposing the cache they wrap
+type unwrapper interface {
+	Unwrap() cache.Cache
+}
+
+// locals holds the in-process backend shared by every Locker of a memory cache
+var locals sync.Map // *memory.MemCache -> *localBackend
+
+// backend stores the owner token of every held lock.
+type backend interface {
+	acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	release(ctx context.Context, key, token string) (bool, error)
+	extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+}
+
+// Locker hands out named locks which expire after their time to live unless extended.
+type Locker struct {
+	b             backend
+	prefix        string
+	retryInterval time.Duration
+}
+
+type Option func(*Locker)
+
+// New -
+// Initialises a new Locker next to c, unwrapping the wrappers around it. Redis caches hold the
+// locks in redis, shared by every process using the same server, and memory caches hold them inside
+// the process, shared by every Locker of the same cache. Any other cache fails with
+// ErrUnsupportedCache rather than silently holding locks which do not exclude other processes, use
+// NewRedlock or NewLocal for those
+func New(c cache.Cache, opts ...Option) (*Locker, error) {
+	inner := c
+	for {
+		switch cc := inner.(type) {
+		case *cacheredis.RedisCache:
+			return NewRedlock([]*cacheredis.RedisCache{cc}, opts...), nil
+		case *memory.MemCache:
+			b, _ := locals.LoadOrStore(cc, newLocal())
+			return newLocker(b.(*localBackend), opts), nil
+		case unwrapper:
+			inner = cc.Unwrap()
+			continue
+		}
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedCache, c)
+	}
+}
+
+// NewLocal -
+// Initialises a new Locker holding its locks inside the process, they only exclude the holders
+// sharing this Locker
+func NewLocal(opts ...Option) *Locker {
+	return newLocker(newLocal(), opts)
+}
+
+// NewRedlock -
+// Initialises a new Locker holding its locks on independent redis instances, a lock being acquired
+// once a majority of the instances granted it within its time to live, as described by the
+// Redlock algorithm. A single instance behaves as a plain SET NX PX lock
+func NewRedlock(instances []*cacheredis.RedisCache, opts ...Option) *Locker {
+	return newLocker(newRedisBackend(instances), opts)
+}
+
+// newLocker -
+// Applies the options on top of the defaults
+func newLocker(b backend, opts []Option) *Locker {
+	l := &Locker{
+		b:             b,
+		prefix:        "go-cache:lock:",
+		retryInterval: defaultRetryInterval,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// KeyPrefix -
+// Functional option to specify the prefix of the keys of locks, defaults to "go-cache:lock:"
+// which the redis cache skips when scanning, flushing or deleting keys by pattern. Other prefixes
+// should stay apart from the key prefix of the cache, or flushing it releases the locks
+func KeyPrefix(p string) Option {
+	return func(l *Locker) {
+		l.prefix = p
+	}
+}
+
+// RetryInterval -
+// Functional option to specify how often Acquire retries a lock held by someone else, defaults to 50ms
+func RetryInterval(d time.Duration) Option {
+	return func(l *Locker) {
+		l.retryInterval = d
+	}
+}
+
+// Lock is a held lock, identified by a random token so only its holder can release or extend it.
+type Lock struct {
+	l     *Locker
+	name  string
+	token string
+}
+
+// Acquire -
+// Acquires the lock name for ttl, waiting until it is released or expires, or until ctx is done
+func (l *Locker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	for {
+		lock, err := l.TryAcquire(ctx, name, ttl)
+		if !errors.Is(err, ErrNotAcquired) {
+			return lock, err
+		}
+		timer := time.NewTimer(l.retryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("unable to acquire lock %s: %w", name, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// TryAcquire -
+// Acquires the lock name for ttl, returning ErrNotAcquired at once when it is held by someone else
+func (l *Locker) TryAcquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := l.b.acquire(ctx, l.prefix+name, token, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotAcquired
+	}
+	return &Lock{l: l, name: name, token: token}, nil
+}
+
+// Name -
+// Returns the name of the lock
+func (lk *Lock) Name() string {
+	return lk.name
+}
+
+// Token -
+// Returns the random token identifying this holder of the lock. Tokens do not increase, so they
+// cannot serve as fencing tokens
+func (lk *Lock) Token() string {
+	return lk.token
+}
+
+// Release -
+// Releases the lock, returning ErrNotHeld when it already expired or was taken over
+func (lk *Lock) Release(ctx context.Context) error {
+	ok, err := lk.l.b.release(ctx, lk.l.prefix+lk.name, lk.token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// Extend -
+// Resets the time to live of the lock to ttl, returning ErrNotHeld when it already expired or was taken over
+func (lk *Lock) Extend(ctx context.Context, ttl time.Duration) error {
+	ok, err := lk.l.b.extend(ctx, lk.l.prefix+lk.name, lk.token, ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// newToken -
+// Returns a random lock token
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate lock token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
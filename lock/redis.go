@@ -0,0 +1,116 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	cacheredis "github.com/pedreviljoen/go-cache/redis"
+	"github.com/redis/go-redis/v9"
+)
+
+// clockDriftFactor is the fraction of the time to live accounted for clock drift between instances
+const clockDriftFactor = 0.01
+
+// releaseScript deletes KEYS[1] only when it holds the token ARGV[1]
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// extendScript sets the time to live of KEYS[1] to ARGV[2] milliseconds only when it holds the token ARGV[1]
+var extendScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// redisBackend holds locks on one or more independent redis instances.
+type redisBackend struct {
+	clients []redis.UniversalClient
+}
+
+// newRedisBackend -
+// Returns a backend using the clients of the redis caches
+func newRedisBackend(instances []*cacheredis.RedisCache) *redisBackend {
+	b := &redisBackend{}
+	for _, rc := range instances {
+		b.clients = append(b.clients, rc.Client())
+	}
+	return b
+}
+
+// quorum -
+// Returns the number of instances which must agree
+func (b *redisBackend) quorum() int {
+	return len(b.clients)/2 + 1
+}
+
+// acquire -
+// Sets the key to the token with SET NX PX on every instance, succeeding when a majority granted
+// it in less than its validity time. Partial acquisitions are released
+func (b *redisBackend) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	granted, err := b.each(func(c redis.UniversalClient) (bool, error) {
+		return c.SetNX(ctx, key, token, ttl).Result()
+	})
+	validity := ttl - time.Since(start) - time.Duration(float64(ttl)*clockDriftFactor)
+	if granted >= b.quorum() && validity > 0 {
+		return true, nil
+	}
+	if granted > 0 {
+		b.release(context.Background(), key, token)
+	}
+	if granted == 0 && err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// release -
+// Deletes the key from every instance where it holds the token
+func (b *redisBackend) release(ctx context.Context, key, token string) (bool, error) {
+	released, err := b.each(func(c redis.UniversalClient) (bool, error) {
+		n, err := releaseScript.Run(ctx, c, []string{key}, token).Int64()
+		return n == 1, err
+	})
+	if released == 0 && err != nil {
+		return false, err
+	}
+	return released >= b.quorum(), nil
+}
+
+// extend -
+// Resets the time to live of the key on every instance where it holds the token
+func (b *redisBackend) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	extended, err := b.each(func(c redis.UniversalClient) (bool, error) {
+		n, err := extendScript.Run(ctx, c, []string{key}, token, ttl.Milliseconds()).Int64()
+		return n == 1, err
+	})
+	if extended == 0 && err != nil {
+		return false, err
+	}
+	return extended >= b.quorum(), nil
+}
+
+// each -
+// Runs fn against every instance, returning how many succeeded and the last error
+func (b *redisBackend) each(fn func(c redis.UniversalClient) (bool, error)) (int, error) {
+	var (
+		ok      int
+		lastErr error
+	)
+	for _, c := range b.clients {
+		granted, err := fn(c)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if granted {
+			ok++
+		}
+	}
+	return ok, lastErr
+}
@@ -0,0 +1,16 @@
+package cache
+
+// Logger receives diagnostics from the cache adaptors such as connection events and
+// cleaner failures. Its method set matches *slog.Logger, see SlogLogger.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// NopLogger discards every message, it is the default Logger of the cache adaptors.
+type NopLogger struct{}
+
+func (NopLogger) Debug(msg string, args ...any) {}
+func (NopLogger) Info(msg string, args ...any)  {}
+func (NopLogger) Error(msg string, args ...any) {}
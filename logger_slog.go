@@ -0,0 +1,14 @@
+//go:build go1.21
+
+package cache
+
+import "log/slog"
+
+// SlogLogger -
+// Adapts l to a Logger, a nil l falls back to slog.Default()
+func SlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		return slog.Default()
+	}
+	return l
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+)
+
+// memoizeConfig holds the settings of Memoize.
+type memoizeConfig struct {
+	prefix string
+	codec  Codec
+	ttl    time.Duration
+	clock  Clock
+	key    func(k any) string
+}
+
+type MemoizeOption func(*memoizeConfig)
+
+// MemoizePrefix -
+// Memoize option naming the function inside its keys, so functions sharing a cache and an argument
+// type do not collide. Defaults to "memoize"
+func MemoizePrefix(prefix string) MemoizeOption {
+	return func(m *memoizeConfig) {
+		m.prefix = prefix
+	}
+}
+
+// MemoizeCodec -
+// Memoize option to specify the codec encoding results, defaults to JSON
+func MemoizeCodec(codec Codec) MemoizeOption {
+	return func(m *memoizeConfig) {
+		m.codec = codec
+	}
+}
+
+// MemoizeTTL -
+// Memoize option to specify how long results are reused, defaults to the window of the cache.
+// Durations longer than the window have no effect
+func MemoizeTTL(d time.Duration) MemoizeOption {
+	return func(m *memoizeConfig) {
+		m.ttl = d
+	}
+}
+
+// MemoizeClock -
+// Memoize option to override the clock used to expire results
+func MemoizeClock(clock Clock) MemoizeOption {
+	return func(m *memoizeConfig) {
+		m.clock = clock
+	}
+}
+
+// MemoizeKey -
+// Memoize option overriding the derivation of the key of an argument, which defaults to Key
+// with the prefix and the argument
+func MemoizeKey(fn func(k any) string) MemoizeOption {
+	return func(m *memoizeConfig) {
+		m.key = fn
+	}
+}
+
+// Memoize -
+// Returns fn caching its results in c, keyed on the argument. Concurrent calls with the same
+// argument share a single call to fn through a Flight, each caller still honouring its own context
+// while fn only sees its context cancelled once every caller has given up. Errors are not cached,
+// and results which cannot be cached are still returned
+func Memoize[K comparable, V any](c Cache, fn func(context.Context, K) (V, error), opts ...MemoizeOption) func(context.Context, K) (V, error) {
+	m := &memoizeConfig{
+		prefix: "memoize",
+		codec:  JSON,
+		clock:  SystemClock{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.key == nil {
+		m.key = func(k any) string {
+			return Key(m.prefix, k)
+		}
+	}
+	var calls Flight
+	return func(ctx context.Context, k K) (V, error) {
+		key := m.key(k)
+		var v V
+		if data, err := c.Get(key); err == nil && len(data) >= 8 {
+			expires := int64(binary.BigEndian.Uint64(data))
+			if (expires == 0 || m.clock.Now().UnixNano() < expires) && m.codec.Unmarshal(data[8:], &v) == nil {
+				return v, nil
+			}
+		}
+		res, err := calls.Do(ctx, key, func(ctx context.Context) (any, error) {
+			v, err := fn(ctx, k)
+			if err != nil {
+				return v, err
+			}
+			data := make([]byte, 8)
+			if m.ttl > 0 {
+				binary.BigEndian.PutUint64(data, uint64(m.clock.Now().Add(m.ttl).UnixNano()))
+			}
+			if encoded, err := m.codec.Marshal(v); err == nil {
+				c.Put(key, append(data, encoded...)) // the result is returned even if it could not be cached
+			}
+			return v, nil
+		})
+		if err != nil {
+			return v, err
+		}
+		v, _ = res.(V)
+		return v, nil
+	}
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"unsafe"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+// entryOverhead approximates the bytes held by a map entry besides its key and value bytes
+const entryOverhead = int64(unsafe.Sizeof(MemCacheValue{})+unsafe.Sizeof("")) + 16
+
+// hit -
+// Records a read which found the value, when accesses are tracked
+func (v MemCacheValue) hit(clock cache.Clock) {
+	if v.access != nil {
+		v.access.hits.Add(1)
+		v.access.last.Store(clock.Now().UnixNano())
+	}
+}
+
+// accessOf -
+// Returns the access metadata of a value replacing old, carrying over the metadata of old
+func (c *MemCache) accessOf(old MemCacheValue) *access {
+	if !c.trackHits {
+		return nil
+	}
+	if old.access != nil {
+		return old.access
+	}
+	return &access{}
+}
+
+// TopKeys -
+// Returns up to n keys with the most hits, hottest first, nil unless TrackKeyHits is enabled
+func (c *MemCache) TopKeys(n int) []cache.KeyHits {
+	if !c.trackHits || n <= 0 {
+		return nil
+	}
+	c.mutex.RLock()
+	keys := make([]cache.KeyHits, 0, len(c.cache))
+	for key, v := range c.cache {
+		if v.access != nil {
+			keys = append(keys, cache.KeyHits{Key: key, Hits: v.access.hits.Load()})
+		}
+	}
+	c.mutex.RUnlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Hits != keys[j].Hits {
+			return keys[i].Hits > keys[j].Hits
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+// Inspect -
+// Returns the size, remaining lifetime and, when TrackKeyHits is enabled, the hit count and last
+// read of key. Stale items not yet flushed report a negative lifetime
+func (c *MemCache) Inspect(key string) (cache.EntryInfo, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	v, ok := c.cache[key]
+	if !ok {
+		return cache.EntryInfo{}, fmt.Errorf("unable to inspect %s: %w", key, cache.ErrKeyNotFound)
+	}
+	info := cache.EntryInfo{
+		Key:  key,
+		Size: len(v.value),
+	}
+	if !v.pinned {
+		info.TTL = c.remaining(v)
+		if info.TTL == 0 {
+			info.TTL = -1
+		}
+	}
+	if v.access != nil {
+		info.Hits = v.access.hits.Load()
+		if last := v.access.last.Load(); last != 0 {
+			info.LastAccess = time.Unix(0, last)
+		}
+	}
+	return info, nil
+}
+
+// MemoryUsage -
+// Returns an estimate of the bytes held by the cache entries: their keys, values, tags and
+// bookkeeping, excluding the allocator and map bucket overhead
+func (c *MemCache) MemoryUsage() int64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	var total int64
+	for key, v := range c.cache {
+		total += entryOverhead + int64(len(key)+cap(v.value))
+		for _, tag := range v.tags {
+			total += int64(unsafe.Sizeof("")) + int64(len(tag))
+		}
+	}
+	return total
+}
+
+// CleanerRun -
+// Returns when the last cleaner run finished and how long it took, a zero time when the cleaner
+// has not run yet
+func (c *MemCache) CleanerRun() (time.Time, time.Duration) {
+	return c.lastCleanerRun(), time.Duration(c.lastCleanTook.Load())
+}
@@ -1,28 +1,90 @@
 package memory
 
 import (
+	"expvar"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/pedreviljoen/go-cache"
 )
 
 const defaultWindow = time.Second * 60
 
 // MemCache is an in memory cache implementation
 type MemCache struct {
-	mutex  sync.RWMutex
-	window time.Duration
+	mutex   sync.RWMutex
+	window  time.Duration
+	sliding bool // Get restarts the lifetime of the item it reads
+
+	trackHits bool // every item records the Get calls which found it, see TopKeys and Inspect
+
+	ttlJitter float64 // fraction by which the lifetime of every saved item is randomised
+
+	maxValueSize  int  // largest value accepted by writes, unlimited when zero
+	skipOversized bool // oversized writes are dropped rather than rejected
+
+	cleanInterval time.Duration // how often the cleaner runs, defaults to the window
+	cleanJitter   float64       // fraction by which each cleaner interval is randomised
+
 	cache  map[string]MemCacheValue
+	tags   map[string]map[string]struct{} // tag -> keys carrying that tag
+	seq    uint64                         // last version handed out to a saved value
+	stats  cache.Counters
+	logger cache.Logger
+	clock  cache.Clock
+
+	onEvicted func(key string, value []byte) // called for every removed item
+	onExpired func(key string, value []byte) // called for items removed for being stale
+
+	lastClean      atomic.Int64 // unix nano timestamp of the last cleaner run
+	lastCleanTook  atomic.Int64 // duration of the last cleaner run in nanoseconds
+	onCleanerError func(error)  // called when a cleaner run fails
+
+	wal             *wal          // write-ahead log of a cache created by Open
+	compactInterval time.Duration // how often the write-ahead log is compacted
+
+	lifecycle sync.Mutex // guards cleaner and closed
+	cleaner   *cleaner
+	closed    bool
 }
 
 // MemCacheValue represents a cached value as part of MemCache
 type MemCacheValue struct {
-	saved time.Time // when this value was saved
-	value []byte    // result of proto.Marshal()
+	saved   time.Time     // when this value was saved or last touched
+	ttl     time.Duration // lifetime set through Touch or TTLJitter, zero uses the cache window
+	pinned  bool          // set through Persist, pinned values never go stale
+	version uint64        // sequence number of the write which saved this value
+	value   []byte        // the cached bytes, e.g. the result of cachecodec.PutProto
+	tags    []string      // tags attached through PutTagged
+	access  *access       // reads which found this key, set when TrackKeyHits is enabled
+}
+
+// access holds the access metadata of an item, shared by the copies of its MemCacheValue
+type access struct {
+	hits atomic.Uint64 // Get calls which found the item
+	last atomic.Int64  // unix nano timestamp of the last of those calls
+}
+
+// token -
+// Returns the version token of the value
+func (v MemCacheValue) token() cache.Version {
+	return cache.Version(strconv.FormatUint(v.version, 10))
+}
+
+// evictedItem is a removed cache item awaiting its eviction callbacks
+type evictedItem struct {
+	key   string
+	value []byte
 }
 
 type cleaner struct {
 	Interval time.Duration
-	stop     chan bool
+	Jitter   float64
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
 }
 
 type Option func(*MemCache)
@@ -33,8 +95,11 @@ type Option func(*MemCache)
 func New(opts ...Option) *MemCache {
 	nache := &MemCache{
 		cache:  map[string]MemCacheValue{},
+		tags:   map[string]map[string]struct{}{},
 		mutex:  sync.RWMutex{},
 		window: defaultWindow,
+		logger: cache.NopLogger{},
+		clock:  cache.SystemClock{},
 	}
 	for _, opt := range opts {
 		opt(nache)
@@ -49,3 +114,128 @@ func Window(t time.Duration) Option {
 		mc.window = t
 	}
 }
+
+// Expvar -
+// Functional option which publishes the cache statistics (entries, hits, misses, last cleaner run)
+// through expvar under name, publishing the same name twice panics
+func Expvar(name string) Option {
+	return func(mc *MemCache) {
+		expvar.Publish(name, expvar.Func(func() any {
+			s := mc.stats.Snapshot()
+			return map[string]any{
+				"entries":          mc.Len(),
+				"hits":             s.Hits,
+				"misses":           s.Misses,
+				"puts":             s.Puts,
+				"deletes":          s.Deletes,
+				"evictions":        s.Evictions,
+				"last_cleaner_run": mc.lastCleanerRun(),
+			}
+		}))
+	}
+}
+
+// Logger -
+// Functional option to specify the logger receiving cleaner and eviction diagnostics, defaults to a no-op logger
+func Logger(l cache.Logger) Option {
+	return func(mc *MemCache) {
+		mc.logger = l
+	}
+}
+
+// OnEvicted -
+// Functional option registering a callback invoked for every item removed from the cache,
+// whether by Delete, an invalidation, Flush or FlushStale
+func OnEvicted(fn func(key string, value []byte)) Option {
+	return func(mc *MemCache) {
+		mc.onEvicted = fn
+	}
+}
+
+// OnExpired -
+// Functional option registering a callback invoked for every stale item removed by FlushStale
+func OnExpired(fn func(key string, value []byte)) Option {
+	return func(mc *MemCache) {
+		mc.onExpired = fn
+	}
+}
+
+// Clock -
+// Functional option to specify the clock used to age cache items, defaults to the system clock
+func Clock(clock cache.Clock) Option {
+	return func(mc *MemCache) {
+		mc.clock = clock
+	}
+}
+
+// CleanInterval -
+// Functional option to specify how often the cleaner flushes stale items, independently of the
+// time window, defaults to the time window
+func CleanInterval(d time.Duration) Option {
+	return func(mc *MemCache) {
+		mc.cleanInterval = d
+	}
+}
+
+// OnCleanerError -
+// Functional option registering a callback invoked whenever the cleaner fails to flush stale items
+func OnCleanerError(fn func(error)) Option {
+	return func(mc *MemCache) {
+		mc.onCleanerError = fn
+	}
+}
+
+// CleanJitter -
+// Functional option randomising every cleaner interval by ±fraction (e.g. 0.1 for ±10%), so that
+// many caches inside one process do not sweep in lockstep
+func CleanJitter(fraction float64) Option {
+	return func(mc *MemCache) {
+		mc.cleanJitter = fraction
+	}
+}
+
+// TTLJitter -
+// Functional option randomising the lifetime of every saved item by ±fraction of the window (e.g. 0.1
+// for ±10%), so items cached together, for instance at startup, do not all go stale in the same second
+func TTLJitter(fraction float64) Option {
+	return func(mc *MemCache) {
+		mc.ttlJitter = fraction
+	}
+}
+
+// MaxValueSize -
+// Functional option refusing writes of values larger than n bytes with cache.ErrValueTooLarge, so a
+// single runaway payload cannot blow up the cache, see SkipOversized
+func MaxValueSize(n int) Option {
+	return func(mc *MemCache) {
+		mc.maxValueSize = n
+	}
+}
+
+// SkipOversized -
+// Functional option making writes of values larger than MaxValueSize silently succeed without
+// saving anything, rather than failing with cache.ErrValueTooLarge
+func SkipOversized(enabled bool) Option {
+	return func(mc *MemCache) {
+		mc.skipOversized = enabled
+	}
+}
+
+// TrackKeyHits -
+// Functional option making every item count the reads which found it and remember the last one, so
+// TopKeys, Inspect and the debug handler can report the hottest keys and idle ones. The metadata
+// survives rewrites of a key and is lost when it is removed
+func TrackKeyHits(enabled bool) Option {
+	return func(mc *MemCache) {
+		mc.trackHits = enabled
+	}
+}
+
+// Sliding -
+// Functional option making Get restart the lifetime of every item it reads, so items expire after
+// being idle for the window rather than a fixed time after they were saved. Pinned items are left as is
+func Sliding(enabled bool) Option {
+	return func(mc *MemCache) {
+		mc.sliding = enabled
+	}
+}
@@ -1,136 +1,740 @@
 package memory
 
 import (
+	"context"
 	"fmt"
-	"runtime"
+	"math/rand"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/pedreviljoen/go-cache"
 )
 
 // IsWarm -
 // Accept a cache key identifier and determines if the cache is still within
 // the time duration window
 func (c *MemCache) IsWarm(key string) bool {
+	_, ok := c.Warmth(key)
+	return ok
+}
+
+// IsWarmMulti -
+// Accepts cache key identifiers and reports for each whether it is cached inside the time window,
+// under a single acquisition of the lock
+func (c *MemCache) IsWarmMulti(keys []string) map[string]bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	warm := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		val, ok := c.cache[key]
+		warm[key] = ok && (val.pinned || c.remaining(val) > 0)
+	}
+	return warm
+}
+
+// Warmth -
+// Accepts a cache key identifier and returns the time left before the item goes stale,
+// the boolean is false when the key is missing or already stale. Pinned items report a zero duration
+func (c *MemCache) Warmth(key string) (time.Duration, bool) {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 	val, ok := c.cache[key]
-	age := (time.Since(val.saved) - c.window) * -1
-	return ok && age > 0
+	if !ok {
+		return 0, false
+	}
+	if val.pinned {
+		return 0, true
+	}
+	remaining := c.remaining(val)
+	if remaining <= 0 {
+		return 0, false
+	}
+	return remaining, true
 }
 
 // Put -
 // Accepts a cache key identifier and value, save the respective key and value
 // inside the in-memory cache
 func (c *MemCache) Put(key string, value []byte) error {
-	cache := map[string]MemCacheValue{}
+	return c.PutTagged(key, value)
+}
+
+// PutTagged -
+// Accepts a cache key identifier, value and a set of tags, saves the key and value
+// inside the in-memory cache and records the key against each tag
+func (c *MemCache) PutTagged(key string, value []byte, tags ...string) error {
+	if ok, err := c.admit(key, len(value)); !ok {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.put(key, value, tags)
+	return nil
+}
+
+// Add -
+// Accepts a cache key identifier and value, saves the value only if the key is missing
+// or stale and returns cache.ErrKeyExists otherwise
+func (c *MemCache) Add(key string, value []byte) error {
+	if ok, err := c.admit(key, len(value)); !ok {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.live(key) {
+		return cache.ErrKeyExists
+	}
+	c.put(key, value, nil)
+	return nil
+}
+
+// Replace -
+// Accepts a cache key identifier and value, saves the value only if the key holds an
+// item which is not stale, keeping its tags, and returns cache.ErrKeyNotFound otherwise
+func (c *MemCache) Replace(key string, value []byte) error {
+	if ok, err := c.admit(key, len(value)); !ok {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.live(key) {
+		return cache.ErrKeyNotFound
+	}
+	c.put(key, value, c.cache[key].tags)
+	return nil
+}
+
+// Swap -
+// Accepts a cache key identifier and value, saves the value and returns the value it
+// replaced in one step, the previous value is nil when the key was not set
+func (c *MemCache) Swap(key string, value []byte) ([]byte, error) {
+	if ok, err := c.admit(key, len(value)); !ok {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	old := c.cache[key].value
+	c.put(key, value, nil)
+	return old, nil
+}
+
+// GetVersion -
+// Accepts a cache key identifier and fetches the value of the corresponding cache key along
+// with its version, which changes on every write of the key
+func (c *MemCache) GetVersion(key string) ([]byte, cache.Version, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	v, ok := c.cache[key]
+	if !ok {
+		c.stats.Misses.Add(1)
+		return nil, "", fmt.Errorf("unable to retrieve value from cache: %w", cache.ErrKeyNotFound)
+	}
+	c.stats.Hits.Add(1)
+	return v.value, v.token(), nil
+}
+
+// PutIfVersion -
+// Accepts a cache key identifier, value and the version read through GetVersion, saves the
+// value only if the item has not been written since, keeping its tags, and returns
+// cache.ErrVersionMismatch otherwise. The zero version only saves the value when the key is missing
+func (c *MemCache) PutIfVersion(key string, value []byte, version cache.Version) error {
+	if ok, err := c.admit(key, len(value)); !ok {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	v, ok := c.cache[key]
+	var current cache.Version
+	if ok {
+		current = v.token()
+	}
+	if current != version {
+		return cache.ErrVersionMismatch
+	}
+	c.put(key, value, v.tags)
+	return nil
+}
+
+// Increment -
+// Accepts a cache key identifier and adds delta to the integer stored as its decimal value,
+// missing or stale keys start from zero. The lifetime of an existing item is kept
+func (c *MemCache) Increment(key string, delta int64) (int64, error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	if !c.live(key) {
+		c.put(key, []byte(strconv.FormatInt(delta, 10)), nil)
+		return delta, nil
+	}
+	v := c.cache[key]
+	n, err := strconv.ParseInt(string(v.value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cache value of %s is not an integer: %w", key, err)
+	}
+	n += delta
+	c.seq++
+	v.value = []byte(strconv.FormatInt(n, 10))
+	v.version = c.seq
+	c.update(key, v)
+	c.stats.Puts.Add(1)
+	return n, nil
+}
+
+// Decrement -
+// Accepts a cache key identifier and subtracts delta from the integer stored as its decimal value
+func (c *MemCache) Decrement(key string, delta int64) (int64, error) {
+	return c.Increment(key, -delta)
+}
+
+// Append -
+// Accepts a cache key identifier and appends data to its value, missing or stale keys
+// are saved with data as their value. The lifetime of an existing item is kept
+func (c *MemCache) Append(key string, data []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.live(key) {
+		if ok, err := c.admit(key, len(data)); !ok {
+			return err
+		}
+		c.put(key, append([]byte(nil), data...), nil)
+		return nil
+	}
+	v := c.cache[key]
+	if ok, err := c.admit(key, len(v.value)+len(data)); !ok {
+		return err
+	}
+	c.seq++
+	v.value = append(v.value[:len(v.value):len(v.value)], data...)
+	v.version = c.seq
+	c.update(key, v)
+	c.stats.Puts.Add(1)
+	return nil
+}
+
+// admit -
+// Reports whether a value of size bytes may be saved under key, the error is nil when an
+// oversized value is skipped rather than rejected, see MaxValueSize
+func (c *MemCache) admit(key string, size int) (bool, error) {
+	if c.maxValueSize <= 0 || size <= c.maxValueSize {
+		return true, nil
+	}
+	if c.skipOversized {
+		c.logger.Debug("skipped oversized cache value", "key", key, "size", size, "max", c.maxValueSize)
+		return false, nil
+	}
+	return false, fmt.Errorf("unable to save %s of %d bytes, limit is %d: %w", key, size, c.maxValueSize, cache.ErrValueTooLarge)
+}
+
+// live -
+// Reports whether key holds an item which is not stale, the caller must hold the lock
+func (c *MemCache) live(key string) bool {
+	v, ok := c.cache[key]
+	return ok && (v.pinned || c.remaining(v) > 0)
+}
+
+// put -
+// Saves the key and value with its tags, the caller must hold the write lock
+func (c *MemCache) put(key string, value []byte, tags []string) {
+	cache := map[string]MemCacheValue{}
 	curCache := c.cache
 	for k, v := range curCache {
 		if k != key {
 			cache[k] = v
 		}
 	}
+	old, ok := curCache[key]
+	if ok {
+		c.untag(key, old)
+	}
+	c.seq++
 	nVal := MemCacheValue{
-		value: value,
-		saved: time.Now(),
+		value:   value,
+		saved:   c.clock.Now(),
+		ttl:     c.jitteredTTL(),
+		tags:    tags,
+		version: c.seq,
+		access:  c.accessOf(old),
+	}
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = map[string]struct{}{}
+			c.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
 	}
 	cache[key] = nVal
 	c.cache = cache
+	c.wal.put(key, nVal)
+	c.stats.Puts.Add(1)
+}
+
+// InvalidateTag -
+// Deletes every cache item which was saved with the given tag
+func (c *MemCache) InvalidateTag(tag string) error {
+	var removed []evictedItem
+	defer func() { c.notify(removed, false) }()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key := range c.tags[tag] {
+		if v, ok := c.cache[key]; ok {
+			c.remove(key, v, &removed)
+			c.stats.Deletes.Add(1)
+		}
+	}
+	delete(c.tags, tag)
 	return nil
 }
 
+// update -
+// Replaces the item of key in place, the caller must hold the write lock
+func (c *MemCache) update(key string, v MemCacheValue) {
+	c.cache[key] = v
+	c.wal.put(key, v)
+}
+
+// remove -
+// Deletes key from the cache and the tag index, recording it in removed when eviction
+// callbacks are registered, the caller must hold the write lock
+func (c *MemCache) remove(key string, v MemCacheValue, removed *[]evictedItem) {
+	c.untag(key, v)
+	delete(c.cache, key)
+	c.wal.delete(key)
+	if c.onEvicted != nil || c.onExpired != nil {
+		*removed = append(*removed, evictedItem{key: key, value: v.value})
+	}
+}
+
+// notify -
+// Invokes the eviction callbacks for every removed item, expired marks items removed
+// for being stale. It must be called without holding the lock so callbacks may use the cache
+func (c *MemCache) notify(removed []evictedItem, expired bool) {
+	for _, item := range removed {
+		if expired && c.onExpired != nil {
+			c.onExpired(item.key, item.value)
+		}
+		if c.onEvicted != nil {
+			c.onEvicted(item.key, item.value)
+		}
+	}
+}
+
+// untag -
+// Removes key from the inverted index of every tag carried by v, the caller must hold the write lock
+func (c *MemCache) untag(key string, v MemCacheValue) {
+	for _, tag := range v.tags {
+		keys := c.tags[tag]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
+
 // Get -
 // Accepts a cache key identifier and fetches the value of the corresponding cache key
 func (c *MemCache) Get(key string) ([]byte, error) {
+	if c.sliding {
+		return c.slide(key)
+	}
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
-	cache, ok := c.cache[key]
+	item, ok := c.cache[key]
 	if !ok {
-		return nil, fmt.Errorf("unable to retrieve value from cache")
+		c.stats.Misses.Add(1)
+		return nil, fmt.Errorf("unable to retrieve value from cache: %w", cache.ErrKeyNotFound)
 	}
-	return cache.value, nil
+	c.stats.Hits.Add(1)
+	item.hit(c.clock)
+	return item.value, nil
+}
+
+// slide -
+// Fetches the value of key like Get and restarts the lifetime of the item when it is not stale
+func (c *MemCache) slide(key string) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	v, ok := c.cache[key]
+	if !ok {
+		c.stats.Misses.Add(1)
+		return nil, fmt.Errorf("unable to retrieve value from cache: %w", cache.ErrKeyNotFound)
+	}
+	if !v.pinned && c.remaining(v) > 0 {
+		v.saved = c.clock.Now()
+		c.update(key, v)
+	}
+	c.stats.Hits.Add(1)
+	v.hit(c.clock)
+	return v.value, nil
+}
+
+// GetWithTTL -
+// Accepts a cache key identifier and fetches the value of the corresponding cache key along
+// with the time left before it goes stale, stale items report a zero duration and pinned items
+// cache.NoExpiry
+func (c *MemCache) GetWithTTL(key string) ([]byte, time.Duration, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	v, ok := c.cache[key]
+	if !ok {
+		c.stats.Misses.Add(1)
+		return nil, 0, fmt.Errorf("unable to retrieve value from cache: %w", cache.ErrKeyNotFound)
+	}
+	c.stats.Hits.Add(1)
+	v.hit(c.clock)
+	if v.pinned {
+		return v.value, cache.NoExpiry, nil
+	}
+	remaining := c.remaining(v)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return v.value, remaining, nil
+}
+
+// Touch -
+// Accepts a cache key identifier and resets the lifetime of the item to ttl without
+// rewriting its value, a zero ttl resets the item to the cache window
+func (c *MemCache) Touch(key string, ttl time.Duration) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	v, ok := c.cache[key]
+	if !ok {
+		return fmt.Errorf("unable to retrieve value from cache: %w", cache.ErrKeyNotFound)
+	}
+	v.saved = c.clock.Now()
+	v.ttl = ttl
+	v.pinned = false
+	c.update(key, v)
+	return nil
+}
+
+// Persist -
+// Accepts a cache key identifier and pins the item so it never goes stale and is kept by
+// FlushStale and the cleaner, writing or touching the key again unpins it
+func (c *MemCache) Persist(key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	v, ok := c.cache[key]
+	if !ok {
+		return fmt.Errorf("unable to retrieve value from cache: %w", cache.ErrKeyNotFound)
+	}
+	v.pinned = true
+	c.update(key, v)
+	return nil
+}
+
+// jitteredTTL -
+// Returns the lifetime of an item being saved, zero for the cache window unless TTL jitter is configured
+func (c *MemCache) jitteredTTL() time.Duration {
+	if c.ttlJitter <= 0 {
+		return 0
+	}
+	d := time.Duration(float64(c.window) * (1 + c.ttlJitter*(2*rand.Float64()-1)))
+	if d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// remaining -
+// Returns the time left before v goes stale, negative once it is stale
+func (c *MemCache) remaining(v MemCacheValue) time.Duration {
+	ttl := v.ttl
+	if ttl <= 0 {
+		ttl = c.window
+	}
+	return ttl - c.clock.Now().Sub(v.saved)
+}
+
+// Len -
+// Returns the number of items held by the cache, including stale items which have not been flushed yet
+func (c *MemCache) Len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.cache)
+}
+
+// Stats -
+// Returns a snapshot of the hit, miss, put, delete and eviction counters
+func (c *MemCache) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// Pop -
+// Accepts a cache key identifier, fetches the value of the corresponding cache key and
+// removes it in one step so only a single caller receives it
+func (c *MemCache) Pop(key string) ([]byte, error) {
+	var removed []evictedItem
+	defer func() { c.notify(removed, false) }()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	v, ok := c.cache[key]
+	if !ok {
+		c.stats.Misses.Add(1)
+		return nil, cache.ErrKeyNotFound
+	}
+	c.remove(key, v, &removed)
+	c.stats.Hits.Add(1)
+	c.stats.Deletes.Add(1)
+	return v.value, nil
 }
 
 // Delete -
 // Accepts a cache key identifier and deletes the value of the corresponding cache key
 func (c *MemCache) Delete(key string) error {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
-	_, ok := c.cache[key]
+	var removed []evictedItem
+	defer func() { c.notify(removed, false) }()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	v, ok := c.cache[key]
 	if !ok {
-		return fmt.Errorf("unable to retrieve value from cache")
-	} else {
-		c.cache[key] = MemCacheValue{}
+		return fmt.Errorf("unable to delete %s: %w", key, cache.ErrKeyNotFound)
 	}
+	c.remove(key, v, &removed)
+	c.stats.Deletes.Add(1)
 	return nil
 }
 
 // Flush -
 // Empties the entire cache
 func (c *MemCache) Flush() error {
+	var removed []evictedItem
+	defer func() { c.notify(removed, false) }()
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	if c.onEvicted != nil {
+		for k, v := range c.cache {
+			removed = append(removed, evictedItem{key: k, value: v.value})
+		}
+	}
 	nCache := map[string]MemCacheValue{}
 	c.cache = nCache
+	c.tags = map[string]map[string]struct{}{}
+	c.wal.flush()
 	return nil
 }
 
 // FlushStale -
 // Iterates over all cache key-value items and removes all stale cache items
 func (c *MemCache) FlushStale() error {
+	return c.FlushStaleByPrefix("")
+}
+
+// FlushStaleByPrefix -
+// Iterates over all cache key-value items starting with prefix and removes the stale ones
+func (c *MemCache) FlushStaleByPrefix(prefix string) error {
+	c.stats.StaleFlushes.Add(1)
+	var removed []evictedItem
+	defer func() { c.notify(removed, true) }()
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	evicted := 0
 	for k, v := range c.cache {
-		age := (time.Since(v.saved) - c.window) * (-1)
-		if age < 0 {
-			delete(c.cache, k)
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if !v.pinned && c.remaining(v) < 0 {
+			c.remove(k, v, &removed)
+			evicted++
 		}
 	}
+	c.stats.Evictions.Add(uint64(evicted))
+	c.logger.Debug("flushed stale cache items", "prefix", prefix, "evicted", evicted)
 	return nil
 }
 
 // RunCleaner -
 // Initialises and starts a new cleaner process in a separate go routine
 // this process flushes cache items inside the cache which are older than the configured cache window
-func (c *MemCache) RunCleaner() {
-	j := c.initCleaner()
-	j.run(c)
-	runtime.SetFinalizer(c, j.stopCleaner)
+// and runs until ctx is done or the cache is closed
+func (c *MemCache) RunCleaner(ctx context.Context) {
+	c.lifecycle.Lock()
+	defer c.lifecycle.Unlock()
+	if c.closed || c.cleaner.running() {
+		return
+	}
+	if c.cleanerInterval() <= 0 {
+		c.logger.Error("cache cleaner not started, no clean interval or window configured")
+		return
+	}
+	c.cleaner = c.initCleaner()
+	c.cleaner.run(ctx, c)
+}
+
+// stopCleaner -
+// Stops the running cleaner, if any, and waits for it to exit
+func (c *MemCache) stopCleaner() {
+	c.lifecycle.Lock()
+	j := c.cleaner
+	c.cleaner = nil
+	c.lifecycle.Unlock()
+	if j != nil {
+		j.stopCleaner()
+	}
+}
+
+// Close -
+// Stops the cleaner process, it is safe to call more than once
+func (c *MemCache) Close() error {
+	c.lifecycle.Lock()
+	c.closed = true
+	c.lifecycle.Unlock()
+	c.stopCleaner()
+	return c.closeWAL()
+}
+
+// lastCleanerRun -
+// Returns the time the cleaner last ran, the zero time when it never ran
+func (c *MemCache) lastCleanerRun() time.Time {
+	ns := c.lastClean.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// cleanerInterval -
+// Returns how often the cleaner runs, the configured clean interval or else the cache window
+func (c *MemCache) cleanerInterval() time.Duration {
+	if c.cleanInterval > 0 {
+		return c.cleanInterval
+	}
+	return c.window
 }
 
 // initCleaner -
 // Initialises a new cleaner
 func (c *MemCache) initCleaner() *cleaner {
 	return &cleaner{
-		Interval: c.window,
-		stop:     make(chan bool),
+		Interval: c.cleanerInterval(),
+		Jitter:   c.cleanJitter,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
 	}
 }
 
 // runCleaner -
 // Runs the cleaner inside a go routine
-func (j *cleaner) run(c *MemCache) {
-	go j.cleanup(c)
+func (j *cleaner) run(ctx context.Context, c *MemCache) {
+	go j.cleanup(ctx, c)
 }
 
 // cleanup -
 // Calls the underlying FlushStale method of the cache which clears
 // stale cache items
-func (j *cleaner) cleanup(c *MemCache) {
-	ticker := time.NewTicker(j.Interval)
+func (j *cleaner) cleanup(ctx context.Context, c *MemCache) {
+	defer close(j.done)
+	timer := time.NewTimer(j.next())
 	for {
 		select {
-		case <-ticker.C:
-			c.FlushStale()
+		case <-timer.C:
+			start := c.clock.Now()
+			if err := c.FlushStale(); err != nil {
+				c.logger.Error("cache cleaner failed to flush stale items", "error", err)
+				if c.onCleanerError != nil {
+					c.onCleanerError(err)
+				}
+			}
+			c.lastCleanTook.Store(int64(c.clock.Now().Sub(start)))
+			c.lastClean.Store(c.clock.Now().UnixNano())
+			timer.Reset(j.next())
 		case <-j.stop:
-			ticker.Stop()
+			timer.Stop()
+			return
+		case <-ctx.Done():
+			timer.Stop()
 			return
 		}
 	}
 }
 
+// next -
+// Returns the delay until the next cleaner run, the interval randomised by ±Jitter
+func (j *cleaner) next() time.Duration {
+	if j.Jitter <= 0 {
+		return j.Interval
+	}
+	d := time.Duration(float64(j.Interval) * (1 + j.Jitter*(2*rand.Float64()-1)))
+	if d <= 0 {
+		return j.Interval
+	}
+	return d
+}
+
+// running -
+// Reports whether the cleaner go-routine is still running, a nil cleaner never runs
+func (j *cleaner) running() bool {
+	if j == nil {
+		return false
+	}
+	select {
+	case <-j.done:
+		return false
+	default:
+		return true
+	}
+}
+
 // stopCleaner -
-// Sends a stop signal to the go-routine running the cleaner process
+// Signals the go-routine running the cleaner process to stop and waits for it to exit,
+// it is safe to call more than once
 func (j *cleaner) stopCleaner() {
-	j.stop <- true
+	j.once.Do(func() {
+		close(j.stop)
+	})
+	<-j.done
+}
+
+// DeleteByPrefix -
+// Accepts a key prefix and deletes every cache item whose key starts with it
+func (c *MemCache) DeleteByPrefix(prefix string) error {
+	var removed []evictedItem
+	defer func() { c.notify(removed, false) }()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for k, v := range c.cache {
+		if strings.HasPrefix(k, prefix) {
+			c.remove(k, v, &removed)
+			c.stats.Deletes.Add(1)
+		}
+	}
+	return nil
+}
+
+// DeleteByPattern -
+// Accepts a redis style glob pattern (*, ?, [...]) and deletes every cache item whose key matches it
+func (c *MemCache) DeleteByPattern(pattern string) error {
+	var removed []evictedItem
+	defer func() { c.notify(removed, false) }()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for k, v := range c.cache {
+		if cache.Match(pattern, k) {
+			c.remove(k, v, &removed)
+			c.stats.Deletes.Add(1)
+		}
+	}
+	return nil
+}
+
+// Scan -
+// Calls fn for every key held by the cache, including stale keys which have not been flushed yet,
+// until fn returns an error or ctx is done. Keys are collected up front so fn may use the cache
+func (c *MemCache) Scan(ctx context.Context, fn func(key string) error) error {
+	c.mutex.RLock()
+	keys := make([]string, 0, len(c.cache))
+	for key := range c.cache {
+		keys = append(keys, key)
+	}
+	c.mutex.RUnlock()
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+func init() {
+	cache.Register("memory", openURL)
+}
+
+// openURL -
+// Builds a MemCache from a "memory://" URL for cache.Open. A path such as "memory:///var/lib/app/cache.log"
+// persists the cache to a write-ahead log through Open. Supported query parameters are window,
+// prefix (ignored), clean_interval, clean_jitter, ttl_jitter, sliding and max_value_size
+func openURL(u *url.URL) (cache.Cache, error) {
+	var opts []Option
+	for name, values := range u.Query() {
+		value := values[len(values)-1]
+		var err error
+		switch name {
+		case "window":
+			var d time.Duration
+			if d, err = time.ParseDuration(value); err == nil {
+				opts = append(opts, Window(d))
+			}
+		case "clean_interval":
+			var d time.Duration
+			if d, err = time.ParseDuration(value); err == nil {
+				opts = append(opts, CleanInterval(d))
+			}
+		case "clean_jitter":
+			var f float64
+			if f, err = strconv.ParseFloat(value, 64); err == nil {
+				opts = append(opts, CleanJitter(f))
+			}
+		case "ttl_jitter":
+			var f float64
+			if f, err = strconv.ParseFloat(value, 64); err == nil {
+				opts = append(opts, TTLJitter(f))
+			}
+		case "sliding":
+			var b bool
+			if b, err = strconv.ParseBool(value); err == nil {
+				opts = append(opts, Sliding(b))
+			}
+		case "prefix":
+			// a MemCache has a keyspace of its own, accepted so configurations can switch backends
+		case "max_value_size":
+			var n int
+			if n, err = strconv.Atoi(value); err == nil {
+				opts = append(opts, MaxValueSize(n))
+			}
+		default:
+			return nil, fmt.Errorf("unable to open memory cache: unknown parameter %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to open memory cache: invalid %s: %w", name, err)
+		}
+	}
+	if u.Path != "" && u.Path != "/" {
+		return Open(u.Path, opts...)
+	}
+	return New(opts...), nil
+}
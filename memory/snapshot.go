@@ -0,0 +1,179 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// snapshotMagic starts every snapshot, the trailing byte is the format version
+var snapshotMagic = []byte("GOCACHE\x01")
+
+// maxFieldSize bounds the length of a key, value or tag read from a snapshot or write-ahead log
+const maxFieldSize = 1 << 30
+
+// SaveTo -
+// Serialises every item of the cache, its value, save timestamp, lifetime, pin and tags, to w in a
+// compact binary format which LoadFrom restores, so a restarted process can resume with a warm cache
+func (c *MemCache) SaveTo(w io.Writer) error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	bw := bufio.NewWriter(w)
+	bw.Write(snapshotMagic)
+	bw.Write(binary.AppendUvarint(nil, uint64(len(c.cache))))
+	var buf []byte
+	for key, v := range c.cache {
+		buf = appendItem(buf[:0], key, v)
+		bw.Write(buf)
+	}
+	return bw.Flush()
+}
+
+// LoadFrom -
+// Restores the items of a snapshot written by SaveTo, replacing items sharing their keys and
+// skipping items which went stale in the meantime. Nothing is restored when the snapshot is malformed
+func (c *MemCache) LoadFrom(r io.Reader) error {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return fmt.Errorf("unable to read cache snapshot: %w", err)
+	}
+	if string(magic) != string(snapshotMagic) {
+		return errors.New("unable to read cache snapshot: unknown format")
+	}
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return fmt.Errorf("unable to read cache snapshot: %w", err)
+	}
+	var keys []string
+	var items []MemCacheValue
+	for i := uint64(0); i < count; i++ {
+		key, item, err := readItem(br)
+		if err != nil {
+			return fmt.Errorf("unable to read cache snapshot: %w", err)
+		}
+		keys = append(keys, key)
+		items = append(items, item)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, v := range items {
+		if !v.pinned && c.remaining(v) <= 0 {
+			continue
+		}
+		c.restore(keys[i], v)
+	}
+	return nil
+}
+
+// restore -
+// Inserts an item read back from disk, replacing the item sharing its key, the caller must hold the write lock
+func (c *MemCache) restore(key string, v MemCacheValue) {
+	old, ok := c.cache[key]
+	if ok {
+		c.untag(key, old)
+	}
+	c.seq++
+	v.version = c.seq
+	v.access = c.accessOf(old)
+	for _, tag := range v.tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = map[string]struct{}{}
+		}
+		c.tags[tag][key] = struct{}{}
+	}
+	c.cache[key] = v
+	c.wal.put(key, v)
+}
+
+// appendItem -
+// Appends the binary form of an item and its key to b
+func appendItem(b []byte, key string, v MemCacheValue) []byte {
+	b = appendBytes(b, []byte(key))
+	b = appendBytes(b, v.value)
+	b = binary.AppendUvarint(b, uint64(v.saved.UnixNano()))
+	b = binary.AppendUvarint(b, uint64(v.ttl))
+	if v.pinned {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	b = binary.AppendUvarint(b, uint64(len(v.tags)))
+	for _, tag := range v.tags {
+		b = appendBytes(b, []byte(tag))
+	}
+	return b
+}
+
+// appendBytes -
+// Appends p prefixed by its length to b
+func appendBytes(b, p []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(p)))
+	return append(b, p...)
+}
+
+// readItem -
+// Reads a single item and its key written by appendItem
+func readItem(br *bufio.Reader) (string, MemCacheValue, error) {
+	var v MemCacheValue
+	key, err := readBytes(br)
+	if err != nil {
+		return "", v, err
+	}
+	if v.value, err = readBytes(br); err != nil {
+		return "", v, err
+	}
+	saved, err := binary.ReadUvarint(br)
+	if err != nil {
+		return "", v, err
+	}
+	v.saved = time.Unix(0, int64(saved))
+	ttl, err := binary.ReadUvarint(br)
+	if err != nil {
+		return "", v, err
+	}
+	v.ttl = time.Duration(ttl)
+	pinned, err := br.ReadByte()
+	if err != nil {
+		return "", v, err
+	}
+	v.pinned = pinned == 1
+	tags, err := binary.ReadUvarint(br)
+	if err != nil {
+		return "", v, err
+	}
+	for i := uint64(0); i < tags; i++ {
+		tag, err := readBytes(br)
+		if err != nil {
+			return "", v, err
+		}
+		v.tags = append(v.tags, string(tag))
+	}
+	return string(key), v, nil
+}
+
+// readBytes -
+// Reads a byte slice prefixed by its length written by appendBytes. The length comes from the
+// input, so it is bounded by maxFieldSize and the slice only grows as its bytes are actually read,
+// a truncated or corrupted input fails without allocating the length it claims
+func readBytes(br *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxFieldSize {
+		return nil, fmt.Errorf("field of %d bytes exceeds the limit of %d bytes", n, maxFieldSize)
+	}
+	b, err := io.ReadAll(io.LimitReader(br, int64(n)))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(b)) < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
@@ -0,0 +1,253 @@
+package memory
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+const defaultCompactInterval = time.Minute * 10
+
+// write-ahead log record types
+const (
+	walPut    byte = 'P'
+	walDelete byte = 'D'
+	walFlush  byte = 'F'
+)
+
+// wal is an append-only log of the writes applied to a MemCache, replayed by Open
+type wal struct {
+	path   string
+	f      *os.File
+	buf    []byte
+	logger cache.Logger
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// Open -
+// Constructor function which initialises a new cache persisted to the write-ahead log at path.
+// The log is replayed first, restoring the state of the previous process, then every write is
+// appended to it. The log is compacted on open and every compact interval, see CompactInterval.
+// Writes reach the operating system before the call returns, surviving a crash of the process
+func Open(path string, opts ...Option) (*MemCache, error) {
+	c := New(opts...)
+	if err := c.replay(path); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open cache log: %w", err)
+	}
+	c.wal = &wal{
+		path:   path,
+		f:      f,
+		logger: c.logger,
+	}
+	c.mutex.Lock()
+	err = c.compact()
+	c.mutex.Unlock()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	interval := c.compactInterval
+	if interval == 0 {
+		interval = defaultCompactInterval
+	}
+	if interval > 0 {
+		c.wal.stop = make(chan struct{})
+		c.wal.done = make(chan struct{})
+		go c.compactEvery(interval)
+	}
+	return c, nil
+}
+
+// CompactInterval -
+// Functional option to specify how often the write-ahead log of a cache created by Open is
+// rewritten to hold only the current items, defaults to 10 minutes, a negative interval disables it
+func CompactInterval(t time.Duration) Option {
+	return func(mc *MemCache) {
+		mc.compactInterval = t
+	}
+}
+
+// replay -
+// Applies the records of the log at path, a truncated final record left by a crash is ignored
+func (c *MemCache) replay(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to open cache log: %w", err)
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for {
+		op, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read cache log: %w", err)
+		}
+		switch op {
+		case walPut:
+			key, v, err := readItem(br)
+			if err != nil {
+				return c.truncated(err)
+			}
+			c.restore(key, v)
+		case walDelete:
+			key, err := readBytes(br)
+			if err != nil {
+				return c.truncated(err)
+			}
+			if v, ok := c.cache[string(key)]; ok {
+				c.untag(string(key), v)
+				delete(c.cache, string(key))
+			}
+		case walFlush:
+			c.cache = map[string]MemCacheValue{}
+			c.tags = map[string]map[string]struct{}{}
+		default:
+			return fmt.Errorf("unable to read cache log: unknown record %q", op)
+		}
+	}
+}
+
+// truncated -
+// Ignores a record cut short by a crash, reporting any other failure
+func (c *MemCache) truncated(err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		c.logger.Error("ignoring truncated cache log record", "error", err)
+		return nil
+	}
+	return fmt.Errorf("unable to read cache log: %w", err)
+}
+
+// compact -
+// Rewrites the log to hold a single record per current item, the caller must hold the write lock
+func (c *MemCache) compact() error {
+	tmp := c.wal.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("unable to compact cache log: %w", err)
+	}
+	bw := bufio.NewWriter(f)
+	var buf []byte
+	for key, v := range c.cache {
+		buf = appendItem(append(buf[:0], walPut), key, v)
+		bw.Write(buf)
+	}
+	if err = bw.Flush(); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, c.wal.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("unable to compact cache log: %w", err)
+	}
+	nf, err := os.OpenFile(c.wal.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to reopen cache log: %w", err)
+	}
+	c.wal.f.Close()
+	c.wal.f = nf
+	return nil
+}
+
+// compactEvery -
+// Compacts the log every interval until the cache is closed
+func (c *MemCache) compactEvery(interval time.Duration) {
+	defer close(c.wal.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.mutex.Lock()
+			err := c.compact()
+			c.mutex.Unlock()
+			if err != nil {
+				c.logger.Error("cache log compaction failed", "error", err)
+			}
+		case <-c.wal.stop:
+			return
+		}
+	}
+}
+
+// put -
+// Appends the record of a saved item, a nil log records nothing
+func (w *wal) put(key string, v MemCacheValue) {
+	if w == nil {
+		return
+	}
+	w.write(appendItem(append(w.buf[:0], walPut), key, v))
+}
+
+// delete -
+// Appends the record of a removed item
+func (w *wal) delete(key string) {
+	if w == nil {
+		return
+	}
+	w.write(appendBytes(append(w.buf[:0], walDelete), []byte(key)))
+}
+
+// flush -
+// Appends the record of a flushed cache
+func (w *wal) flush() {
+	if w == nil {
+		return
+	}
+	w.write(append(w.buf[:0], walFlush))
+}
+
+// write -
+// Writes a record to the log, the caller must hold the write lock of the cache
+func (w *wal) write(record []byte) {
+	w.buf = record
+	if _, err := w.f.Write(record); err != nil {
+		w.logger.Error("unable to write cache log", "error", err)
+	}
+}
+
+// closeWAL -
+// Stops the compaction and closes the write-ahead log, if any
+func (c *MemCache) closeWAL() error {
+	c.mutex.RLock()
+	w := c.wal
+	c.mutex.RUnlock()
+	if w == nil {
+		return nil
+	}
+	if w.stop != nil {
+		w.stopOnce.Do(func() { close(w.stop) })
+		<-w.done
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.wal != w {
+		return nil // closed by a concurrent call
+	}
+	c.wal = nil
+	return w.f.Close()
+}
@@ -0,0 +1,115 @@
+package cache
+
+// Middleware decorates a Cache with a cross-cutting concern such as metrics, logging or compression.
+type Middleware func(Cache) Cache
+
+// Op identifies a cache operation passed to Before and After hooks.
+type Op string
+
+const (
+	OpPut        Op = "put"
+	OpGet        Op = "get"
+	OpDelete     Op = "delete"
+	OpIsWarm     Op = "is_warm"
+	OpFlush      Op = "flush"
+	OpFlushStale Op = "flush_stale"
+)
+
+// Wrap -
+// Decorates c with every middleware, the first middleware is the outermost one
+// and therefore sees each call first
+func Wrap(c Cache, mw ...Middleware) Cache {
+	for i := len(mw) - 1; i >= 0; i-- {
+		c = mw[i](c)
+	}
+	return c
+}
+
+// hooks calls before and after around every operation of the wrapped cache.
+type hooks struct {
+	Wrapper
+	before func(op Op, key string) error
+	after  func(op Op, key string, err error)
+}
+
+// Before -
+// Middleware calling fn before every operation, a non-nil error aborts the operation and is
+// returned to the caller. Flush and FlushStale pass an empty key
+func Before(fn func(op Op, key string) error) Middleware {
+	return func(c Cache) Cache {
+		return &hooks{Wrapper: Wrapper{Cache: c}, before: fn}
+	}
+}
+
+// After -
+// Middleware calling fn after every operation with the error it returned, a Get miss
+// is reported through its error and a cold IsWarm through ErrNotWarm
+func After(fn func(op Op, key string, err error)) Middleware {
+	return func(c Cache) Cache {
+		return &hooks{Wrapper: Wrapper{Cache: c}, after: fn}
+	}
+}
+
+func (h *hooks) call(op Op, key string, fn func() error) error {
+	if h.before != nil {
+		if err := h.before(op, key); err != nil {
+			return err
+		}
+	}
+	err := fn()
+	if h.after != nil {
+		h.after(op, key, err)
+	}
+	return err
+}
+
+// Put -
+// Saves the value inside the wrapped cache
+func (h *hooks) Put(key string, val []byte) error {
+	return h.call(OpPut, key, func() error {
+		return h.Cache.Put(key, val)
+	})
+}
+
+// Get -
+// Fetches the value from the wrapped cache
+func (h *hooks) Get(key string) ([]byte, error) {
+	var val []byte
+	err := h.call(OpGet, key, func() (err error) {
+		val, err = h.Cache.Get(key)
+		return err
+	})
+	return val, err
+}
+
+// Delete -
+// Deletes the value from the wrapped cache
+func (h *hooks) Delete(key string) error {
+	return h.call(OpDelete, key, func() error {
+		return h.Cache.Delete(key)
+	})
+}
+
+// IsWarm -
+// Determines if the wrapped cache holds a value for key, an aborted call reports false
+func (h *hooks) IsWarm(key string) bool {
+	err := h.call(OpIsWarm, key, func() error {
+		if !h.Cache.IsWarm(key) {
+			return ErrNotWarm
+		}
+		return nil
+	})
+	return err == nil
+}
+
+// Flush -
+// Empties the wrapped cache
+func (h *hooks) Flush() error {
+	return h.call(OpFlush, "", h.Cache.Flush)
+}
+
+// FlushStale -
+// Flushes the stale items of the wrapped cache
+func (h *hooks) FlushStale() error {
+	return h.call(OpFlushStale, "", h.Cache.FlushStale)
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package mmapcache
+
+import (
+	"os"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+// mmap -
+// Memory mapped files are only supported on unix platforms
+func mmap(f *os.File, size int) ([]byte, error) {
+	return nil, cache.ErrNotSupported
+}
+
+// munmap -
+// Memory mapped files are only supported on unix platforms
+func munmap(b []byte) error {
+	return cache.ErrNotSupported
+}
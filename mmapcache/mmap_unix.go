@@ -0,0 +1,20 @@
+//go:build unix
+
+package mmapcache
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmap -
+// Maps size bytes of f into memory, shared so writes reach the file
+func mmap(f *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
+
+// munmap -
+// Unmaps memory mapped by mmap
+func munmap(b []byte) error {
+	return syscall.Munmap(b)
+}
@@ -0,0 +1,451 @@
+// Package mmapcache is a persistent cache whose items live inside a memory-mapped file rather than
+// on the heap, so the cache survives restarts and can grow beyond comfortable heap sizes.
+package mmapcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+const (
+	defaultWindow      = time.Second * 60
+	defaultInitialSize = 1 << 20
+
+	headerSize = 16 // magic and end offset
+	recordHead = 21 // checksum, flags, key length, value length and save timestamp
+
+	flagDeleted byte = 1
+)
+
+// magic starts every cache file, the trailing byte is the format version
+var magic = []byte("GOCMMAP\x01")
+
+// MMapCache is a cache.Cache storing its items as an append-only log of records inside a
+// memory-mapped file. Every record carries a CRC32 checksum validated when the file is opened,
+// deleted and replaced records are reclaimed by FlushStale
+type MMapCache struct {
+	mutex  sync.RWMutex
+	window time.Duration
+
+	f     *os.File
+	data  []byte         // the mapped file
+	end   int            // offset just past the last record
+	index map[string]int // key -> offset of its live record
+
+	initialSize int
+	stats       cache.Counters
+	logger      cache.Logger
+	clock       cache.Clock
+
+	lifecycle sync.Mutex // guards stop and closed
+	stop      chan struct{}
+	done      chan struct{}
+	closed    bool
+}
+
+type Option func(*MMapCache)
+
+// Open -
+// Constructor function which opens, or creates, the cache file at path and maps it into memory.
+// The records of an existing file are validated against their checksums, loading stops at the
+// first corrupt record so a file torn by a crash only loses its latest writes
+func Open(path string, opts ...Option) (*MMapCache, error) {
+	c := &MMapCache{
+		window:      defaultWindow,
+		index:       map[string]int{},
+		initialSize: defaultInitialSize,
+		logger:      cache.NopLogger{},
+		clock:       cache.SystemClock{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open cache file: %w", err)
+	}
+	c.f = f
+	if err := c.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Window -
+// Functional option to specify the time window of the cache
+func Window(t time.Duration) Option {
+	return func(c *MMapCache) {
+		c.window = t
+	}
+}
+
+// InitialSize -
+// Functional option to specify the size in bytes a new cache file starts with, defaults to 1MiB.
+// The file doubles in size whenever it runs out of space
+func InitialSize(n int) Option {
+	return func(c *MMapCache) {
+		if n > headerSize {
+			c.initialSize = n
+		}
+	}
+}
+
+// Logger -
+// Functional option to specify the logger receiving corrupt records and cleaner failures
+func Logger(l cache.Logger) Option {
+	return func(c *MMapCache) {
+		c.logger = l
+	}
+}
+
+// Clock -
+// Functional option to specify the clock used to timestamp and age items
+func Clock(clock cache.Clock) Option {
+	return func(c *MMapCache) {
+		c.clock = clock
+	}
+}
+
+// load -
+// Maps the file, initialising a new one, and indexes its valid records
+func (c *MMapCache) load() error {
+	info, err := c.f.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to open cache file: %w", err)
+	}
+	size := int(info.Size())
+	fresh := size < headerSize
+	if fresh {
+		size = c.initialSize
+		if err := c.f.Truncate(int64(size)); err != nil {
+			return fmt.Errorf("unable to size cache file: %w", err)
+		}
+	}
+	if c.data, err = mmap(c.f, size); err != nil {
+		return fmt.Errorf("unable to map cache file: %w", err)
+	}
+	if fresh {
+		copy(c.data, magic)
+		c.setEnd(headerSize)
+		return nil
+	}
+	if !bytes.Equal(c.data[:len(magic)], magic) {
+		munmap(c.data)
+		return errors.New("unable to open cache file: unknown format")
+	}
+	end := int(binary.LittleEndian.Uint64(c.data[8:headerSize]))
+	if end < headerSize || end > len(c.data) {
+		end = len(c.data)
+	}
+	off := headerSize
+	for off < end {
+		n, ok := c.valid(off, end)
+		if !ok {
+			c.logger.Error("ignoring corrupt cache records", "offset", off, "bytes", end-off)
+			break
+		}
+		if c.data[off+4]&flagDeleted == 0 {
+			c.index[string(c.key(off))] = off
+		}
+		off += n
+	}
+	c.setEnd(off)
+	return nil
+}
+
+// valid -
+// Validates the record at off, returning its length
+func (c *MMapCache) valid(off, end int) (int, bool) {
+	if end-off < recordHead {
+		return 0, false
+	}
+	kl := int(binary.LittleEndian.Uint32(c.data[off+5:]))
+	vl := int(binary.LittleEndian.Uint32(c.data[off+9:]))
+	n := recordHead + kl + vl
+	if kl < 0 || vl < 0 || n > end-off {
+		return 0, false
+	}
+	sum := binary.LittleEndian.Uint32(c.data[off:])
+	return n, sum == crc32.ChecksumIEEE(c.data[off+5:off+n])
+}
+
+// key -
+// Returns the key of the record at off, backed by the mapping
+func (c *MMapCache) key(off int) []byte {
+	kl := int(binary.LittleEndian.Uint32(c.data[off+5:]))
+	return c.data[off+recordHead : off+recordHead+kl]
+}
+
+// value -
+// Returns the value of the record at off, backed by the mapping
+func (c *MMapCache) value(off int) []byte {
+	kl := int(binary.LittleEndian.Uint32(c.data[off+5:]))
+	vl := int(binary.LittleEndian.Uint32(c.data[off+9:]))
+	start := off + recordHead + kl
+	return c.data[start : start+vl]
+}
+
+// saved -
+// Returns when the record at off was saved
+func (c *MMapCache) saved(off int) time.Time {
+	return time.Unix(0, int64(binary.LittleEndian.Uint64(c.data[off+13:])))
+}
+
+// stale -
+// Reports whether the record at off is older than the window
+func (c *MMapCache) stale(off int) bool {
+	return c.clock.Now().Sub(c.saved(off)) >= c.window
+}
+
+// setEnd -
+// Records the end offset in memory and inside the header
+func (c *MMapCache) setEnd(end int) {
+	c.end = end
+	binary.LittleEndian.PutUint64(c.data[8:headerSize], uint64(end))
+}
+
+// grow -
+// Remaps the file so at least n more bytes fit after the last record, the caller must hold the write lock.
+// The larger region is mapped before the current one is unmapped, so a failure leaves the cache usable
+func (c *MMapCache) grow(n int) error {
+	if c.end+n <= len(c.data) {
+		return nil
+	}
+	size := len(c.data) * 2
+	for size < c.end+n {
+		size *= 2
+	}
+	if err := c.f.Truncate(int64(size)); err != nil {
+		return err
+	}
+	data, err := mmap(c.f, size)
+	if err != nil {
+		return err
+	}
+	old := c.data
+	c.data = data
+	if err := munmap(old); err != nil {
+		c.logger.Error("unable to unmap previous cache file region", "error", err)
+	}
+	return nil
+}
+
+// Put -
+// Accepts a cache key identifier and value, appends a record holding them to the file and
+// marks the previous record of the key as deleted
+func (c *MMapCache) Put(key string, value []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.data == nil {
+		return errors.New("cache is closed")
+	}
+	n := recordHead + len(key) + len(value)
+	if err := c.grow(n); err != nil {
+		return fmt.Errorf("unable to grow cache file: %w", err)
+	}
+	off := c.end
+	rec := c.data[off : off+n]
+	rec[4] = 0
+	binary.LittleEndian.PutUint32(rec[5:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(rec[9:], uint32(len(value)))
+	binary.LittleEndian.PutUint64(rec[13:], uint64(c.clock.Now().UnixNano()))
+	copy(rec[recordHead:], key)
+	copy(rec[recordHead+len(key):], value)
+	binary.LittleEndian.PutUint32(rec, crc32.ChecksumIEEE(rec[5:]))
+	c.setEnd(off + n)
+	if old, ok := c.index[key]; ok {
+		c.data[old+4] |= flagDeleted
+	}
+	c.index[key] = off
+	c.stats.Puts.Add(1)
+	return nil
+}
+
+// Get -
+// Accepts a cache key identifier and fetches a copy of the value of the corresponding cache key
+func (c *MMapCache) Get(key string) ([]byte, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	off, ok := c.index[key]
+	if !ok {
+		c.stats.Misses.Add(1)
+		return nil, fmt.Errorf("unable to retrieve value from cache: %w", cache.ErrKeyNotFound)
+	}
+	c.stats.Hits.Add(1)
+	return append([]byte(nil), c.value(off)...), nil
+}
+
+// IsWarm -
+// Accept a cache key identifier and determines if the cache is still within
+// the time duration window
+func (c *MMapCache) IsWarm(key string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	off, ok := c.index[key]
+	return ok && !c.stale(off)
+}
+
+// Delete -
+// Accepts a cache key identifier and marks the record of the corresponding cache key as deleted
+func (c *MMapCache) Delete(key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	off, ok := c.index[key]
+	if !ok {
+		return fmt.Errorf("unable to delete %s: %w", key, cache.ErrKeyNotFound)
+	}
+	c.data[off+4] |= flagDeleted
+	delete(c.index, key)
+	c.stats.Deletes.Add(1)
+	return nil
+}
+
+// Len -
+// Returns the number of items held by the cache, including stale items which have not been flushed yet
+func (c *MMapCache) Len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.index)
+}
+
+// Stats -
+// Returns a snapshot of the hit, miss, put, delete and eviction counters
+func (c *MMapCache) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// Flush -
+// Empties the entire cache, the file keeps its size
+func (c *MMapCache) Flush() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.data == nil {
+		return errors.New("cache is closed")
+	}
+	c.index = map[string]int{}
+	c.setEnd(headerSize)
+	return nil
+}
+
+// FlushStale -
+// Removes every stale item and compacts the file in place, reclaiming the space of deleted and
+// replaced records
+func (c *MMapCache) FlushStale() error {
+	c.stats.StaleFlushes.Add(1)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.data == nil {
+		return errors.New("cache is closed")
+	}
+	index := make(map[string]int, len(c.index))
+	evicted := 0
+	w := headerSize
+	for off := headerSize; off < c.end; {
+		n, _ := c.valid(off, c.end)
+		if c.data[off+4]&flagDeleted == 0 {
+			if c.stale(off) {
+				evicted++
+			} else {
+				copy(c.data[w:], c.data[off:off+n])
+				index[string(c.key(w))] = w
+				w += n
+			}
+		}
+		off += n
+	}
+	c.index = index
+	c.setEnd(w)
+	c.stats.Evictions.Add(uint64(evicted))
+	c.logger.Debug("flushed stale cache items", "evicted", evicted)
+	return nil
+}
+
+// RunCleaner -
+// Initialises and starts a new cleaner process in a separate go routine which flushes the stale
+// items every window, until ctx is done or the cache is closed
+func (c *MMapCache) RunCleaner(ctx context.Context) {
+	c.lifecycle.Lock()
+	defer c.lifecycle.Unlock()
+	if c.closed || c.stop != nil || c.window <= 0 {
+		return
+	}
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(c.window)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.FlushStale(); err != nil {
+					c.logger.Error("cache cleaner failed", "error", err)
+				}
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(c.stop, c.done)
+}
+
+// Close -
+// Stops the cleaner, flushes the mapping to disk and closes the file, the cache must not be used afterwards
+func (c *MMapCache) Close() error {
+	c.lifecycle.Lock()
+	if c.closed {
+		c.lifecycle.Unlock()
+		return nil
+	}
+	c.closed = true
+	stop, done := c.stop, c.done
+	c.lifecycle.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	err := c.f.Sync()
+	if uerr := munmap(c.data); err == nil {
+		err = uerr
+	}
+	c.data = nil
+	c.index = map[string]int{}
+	if cerr := c.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Scan -
+// Calls fn for every key held by the cache, including stale keys which have not been flushed yet,
+// until fn returns an error or ctx is done. Keys are collected up front so fn may use the cache
+func (c *MMapCache) Scan(ctx context.Context, fn func(key string) error) error {
+	c.mutex.RLock()
+	keys := make([]string, 0, len(c.index))
+	for key := range c.index {
+		keys = append(keys, key)
+	}
+	c.mutex.RUnlock()
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package cache
+
+// PrefixDeleter is implemented by caches which can delete every key sharing a prefix.
+type PrefixDeleter interface {
+	// DeleteByPrefix deletes all cached values whose key starts with prefix.
+	DeleteByPrefix(prefix string) error
+}
+
+// PrefixStaleFlusher is implemented by caches which can flush stale items sharing a prefix.
+type PrefixStaleFlusher interface {
+	// FlushStaleByPrefix flushes all stale cached items whose key starts with prefix.
+	FlushStaleByPrefix(prefix string) error
+}
+
+// namespace scopes every key of the wrapped cache to a prefix.
+type namespace struct {
+	Wrapper
+	prefix string
+}
+
+// WithNamespace -
+// Wraps c so that every key is transparently prefixed, Flush and FlushStale are
+// scoped to the prefix which requires c to implement PrefixDeleter and PrefixStaleFlusher
+func WithNamespace(c Cache, prefix string) Cache {
+	return &namespace{
+		Wrapper: Wrapper{Cache: c},
+		prefix:  prefix,
+	}
+}
+
+func (n *namespace) key(key string) string {
+	return n.prefix + key
+}
+
+// Put -
+// Saves the value under the namespaced key
+func (n *namespace) Put(key string, val []byte) error {
+	return n.Cache.Put(n.key(key), val)
+}
+
+// Get -
+// Fetches the value of the namespaced key
+func (n *namespace) Get(key string) ([]byte, error) {
+	return n.Cache.Get(n.key(key))
+}
+
+// Delete -
+// Deletes the value of the namespaced key
+func (n *namespace) Delete(key string) error {
+	return n.Cache.Delete(n.key(key))
+}
+
+// IsWarm -
+// Determines if the namespaced key holds a value inside the time window
+func (n *namespace) IsWarm(key string) bool {
+	return n.Cache.IsWarm(n.key(key))
+}
+
+// Flush -
+// Deletes every key inside the namespace, leaving other keys untouched
+func (n *namespace) Flush() error {
+	d, ok := n.Cache.(PrefixDeleter)
+	if !ok {
+		return ErrNotSupported
+	}
+	return d.DeleteByPrefix(n.prefix)
+}
+
+// FlushStale -
+// Flushes the stale keys inside the namespace, leaving other keys untouched
+func (n *namespace) FlushStale() error {
+	f, ok := n.Cache.(PrefixStaleFlusher)
+	if !ok {
+		return ErrNotSupported
+	}
+	return f.FlushStaleByPrefix(n.prefix)
+}
+
+// Close -
+// Does nothing, the underlying cache may be shared with other namespaces and is closed by its owner
+func (n *namespace) Close() error {
+	return nil
+}
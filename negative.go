@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"bytes"
+	"time"
+)
+
+// Toucher is implemented by caches which can reset the lifetime of an item.
+type Toucher interface {
+	// Touch resets the lifetime of key to ttl without rewriting its value.
+	Touch(key string, ttl time.Duration) error
+}
+
+// negativeMarker is the value stored for keys known to have no value
+var negativeMarker = []byte("\x00go-cache:negative\x00")
+
+// NegativeCache remembers keys known to have no value, so repeated lookups of missing records
+// are answered by the cache with ErrNegative rather than reaching the source of truth every time.
+type NegativeCache struct {
+	Wrapper
+	ttl time.Duration
+}
+
+// WithNegativeCaching -
+// Wraps c so that PutNegative records keys without a value for ttl, typically shorter than the
+// window of c. A non zero ttl requires c to implement Toucher, zero keeps the window of c
+func WithNegativeCaching(c Cache, ttl time.Duration) *NegativeCache {
+	return &NegativeCache{
+		Wrapper: Wrapper{Cache: c},
+		ttl:     ttl,
+	}
+}
+
+// PutNegative -
+// Records that key definitively has no value, later Gets return ErrNegative until it expires
+func (n *NegativeCache) PutNegative(key string) error {
+	var t Toucher
+	if n.ttl > 0 {
+		var ok bool
+		if t, ok = n.Cache.(Toucher); !ok {
+			return ErrNotSupported
+		}
+	}
+	if err := n.Cache.Put(key, negativeMarker); err != nil {
+		return err
+	}
+	if t != nil {
+		return t.Touch(key, n.ttl)
+	}
+	return nil
+}
+
+// Get -
+// Fetches the value of key, returning ErrNegative when key is known to have no value
+// and the error of the underlying cache on a regular miss
+func (n *NegativeCache) Get(key string) ([]byte, error) {
+	val, err := n.Cache.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(val, negativeMarker) {
+		return nil, ErrNegative
+	}
+	return val, nil
+}
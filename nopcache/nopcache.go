@@ -0,0 +1,64 @@
+// Package nopcache is a cache.Cache which stores nothing, so caching can be disabled through
+// configuration or in tests without nil checks at the call sites.
+package nopcache
+
+import (
+	"context"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+// NopCache accepts every write and misses every read
+type NopCache struct{}
+
+// New -
+// Returns a cache which stores nothing
+func New() *NopCache {
+	return &NopCache{}
+}
+
+// Put -
+// Discards the value and reports success
+func (NopCache) Put(key string, val []byte) error {
+	return nil
+}
+
+// Get -
+// Always misses with cache.ErrKeyNotFound
+func (NopCache) Get(key string) ([]byte, error) {
+	return nil, cache.ErrKeyNotFound
+}
+
+// Delete -
+// Does nothing and reports success
+func (NopCache) Delete(key string) error {
+	return nil
+}
+
+// IsWarm -
+// Always reports false
+func (NopCache) IsWarm(key string) bool {
+	return false
+}
+
+// Flush -
+// Does nothing and reports success
+func (NopCache) Flush() error {
+	return nil
+}
+
+// FlushStale -
+// Does nothing and reports success
+func (NopCache) FlushStale() error {
+	return nil
+}
+
+// RunCleaner -
+// Does nothing, there is nothing to clean
+func (NopCache) RunCleaner(ctx context.Context) {}
+
+// Close -
+// Does nothing and reports success
+func (NopCache) Close() error {
+	return nil
+}
@@ -0,0 +1,426 @@
+// Package peercache spreads a cache over several instances of a service, groupcache style: the
+// instances form a consistent hash ring over HTTP, each owning the keys of its range in a local
+// cache, typically a MemCache, and fetching the other keys from their owner.
+package peercache
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultBasePath = "/_peercache/"
+	defaultReplicas = 50
+	defaultTimeout  = time.Second * 5
+)
+
+// errNotFound is returned for keys the owning peer does not hold
+var errNotFound = fmt.Errorf("unable to retrieve value from cache: %w", cache.ErrKeyNotFound)
+
+// PeerCache is a cache.Cache whose keys are partitioned across peers. It must be served over HTTP
+// under its base path, by mounting it on the server of the instance.
+type PeerCache struct {
+	self      string
+	local     cache.Cache
+	hot       cache.Cache // copies of values owned by other peers, nil unless HotCache is used
+	load      func(key string) ([]byte, error)
+	basePath  string
+	replicas  int
+	client    *http.Client
+	secret    string
+	authorize func(r *http.Request) bool
+	logger    cache.Logger
+
+	mutex sync.RWMutex
+	ring  *ring
+
+	loads singleflight.Group
+}
+
+type Option func(*PeerCache)
+
+// New -
+// Initialises a new PeerCache for the instance reachable at self, e.g. "http://10.0.0.1:8080",
+// keeping the keys it owns in local. The instance owns every key until SetPeers is called
+func New(self string, local cache.Cache, opts ...Option) *PeerCache {
+	p := &PeerCache{
+		self:     strings.TrimSuffix(self, "/"),
+		local:    local,
+		basePath: defaultBasePath,
+		replicas: defaultReplicas,
+		client:   &http.Client{Timeout: defaultTimeout},
+		logger:   cache.NopLogger{},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.ring = newRing(p.replicas, []string{p.self})
+	return p
+}
+
+// BasePath -
+// Functional option to specify the path under which peers serve each other, defaults to "/_peercache/"
+func BasePath(path string) Option {
+	return func(p *PeerCache) {
+		p.basePath = "/" + strings.Trim(path, "/") + "/"
+	}
+}
+
+// Replicas -
+// Functional option to specify the points each peer owns on the hash ring, defaults to 50
+func Replicas(n int) Option {
+	return func(p *PeerCache) {
+		p.replicas = n
+	}
+}
+
+// Loader -
+// Functional option to load the keys missing from their owner, which caches the result. Concurrent
+// misses of a key share a single load, and as only the owner loads, so do the misses of every peer.
+// When the owner can not be reached the key is loaded locally without being cached. Load errors
+// wrapping cache.ErrKeyNotFound are reported to peers as misses, any other as a failure of the owner
+func Loader(load func(key string) ([]byte, error)) Option {
+	return func(p *PeerCache) {
+		p.load = load
+	}
+}
+
+// HotCache -
+// Functional option keeping copies of the values fetched from other peers in c, sparing the network
+// round trip for hot keys. Copies live for the window of c and are only invalidated by writes
+// going through this instance
+func HotCache(c cache.Cache) Option {
+	return func(p *PeerCache) {
+		p.hot = c
+	}
+}
+
+// Client -
+// Functional option to specify the HTTP client fetching from peers, defaults to a 5 second timeout
+func Client(c *http.Client) Option {
+	return func(p *PeerCache) {
+		p.client = c
+	}
+}
+
+// Secret -
+// Functional option to specify the secret shared by every peer, sent as a bearer token with each
+// request to a peer and required from each request served. Either Secret or Authorize must be set
+// for ServeHTTP to serve anything, as peers read and write any key of the local partition
+func Secret(secret string) Option {
+	return func(p *PeerCache) {
+		p.secret = secret
+	}
+}
+
+// Authorize -
+// Functional option to authenticate the requests of peers with fn instead of a shared secret, for
+// instance by checking the client certificate of r.TLS when peers use mutual TLS, along with a
+// Client presenting that certificate. When Secret is also set both must accept the request
+func Authorize(fn func(r *http.Request) bool) Option {
+	return func(p *PeerCache) {
+		p.authorize = fn
+	}
+}
+
+// Logger -
+// Functional option to log failures to reach peers
+func Logger(l cache.Logger) Option {
+	return func(p *PeerCache) {
+		p.logger = l
+	}
+}
+
+// SetPeers -
+// Replaces the peers of the ring with the base URLs of every instance, including this one
+func (p *PeerCache) SetPeers(peers ...string) {
+	trimmed := make([]string, len(peers))
+	for i, peer := range peers {
+		trimmed[i] = strings.TrimSuffix(peer, "/")
+	}
+	r := newRing(p.replicas, trimmed)
+	p.mutex.Lock()
+	p.ring = r
+	p.mutex.Unlock()
+}
+
+// Owner -
+// Returns the base URL of the peer owning key
+func (p *PeerCache) Owner(key string) string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.ring.owner(key)
+}
+
+// remote -
+// Returns the owner of key, or an empty string when this instance owns it
+func (p *PeerCache) remote(key string) string {
+	owner := p.Owner(key)
+	if owner == "" || owner == p.self {
+		return ""
+	}
+	return owner
+}
+
+// Put -
+// Saves the value on the peer owning key
+func (p *PeerCache) Put(key string, val []byte) error {
+	owner := p.remote(key)
+	if owner == "" {
+		return p.local.Put(key, val)
+	}
+	if p.hot != nil {
+		p.hot.Delete(key)
+	}
+	_, err := p.call(http.MethodPut, owner, key, val)
+	return err
+}
+
+// Get -
+// Fetches the value from the peer owning key, which loads it on a miss when a Loader is set. When
+// the owner can not be reached the Loader is called locally and its value is not cached
+func (p *PeerCache) Get(key string) ([]byte, error) {
+	owner := p.remote(key)
+	if owner == "" {
+		return p.getLocal(key)
+	}
+	if p.hot != nil {
+		if val, err := p.hot.Get(key); err == nil {
+			return val, nil
+		}
+	}
+	val, err := p.call(http.MethodGet, owner, key, nil)
+	var unreachable *url.Error
+	if err != nil && p.load != nil && errors.As(err, &unreachable) {
+		return p.loadUncached(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.hot != nil {
+		p.hot.Put(key, val)
+	}
+	return val, nil
+}
+
+// getLocal -
+// Fetches a key owned by this instance, loading it on a miss when a Loader is set
+func (p *PeerCache) getLocal(key string) ([]byte, error) {
+	val, err := p.local.Get(key)
+	if err == nil || p.load == nil {
+		return val, err
+	}
+	v, err, _ := p.loads.Do(key, func() (any, error) {
+		val, err := p.load(key)
+		if err != nil {
+			return nil, err
+		}
+		p.local.Put(key, val) // the loaded value is returned even if it could not be cached
+		return val, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+// loadUncached -
+// Loads a key owned by an unreachable peer, leaving the caching to its owner
+func (p *PeerCache) loadUncached(key string) ([]byte, error) {
+	v, err, _ := p.loads.Do("remote:"+key, func() (any, error) {
+		return p.load(key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+// Delete -
+// Deletes the value from the peer owning key
+func (p *PeerCache) Delete(key string) error {
+	owner := p.remote(key)
+	if owner == "" {
+		return p.local.Delete(key)
+	}
+	if p.hot != nil {
+		p.hot.Delete(key)
+	}
+	_, err := p.call(http.MethodDelete, owner, key, nil)
+	return err
+}
+
+// IsWarm -
+// Determines if the peer owning key holds a value inside its time window
+func (p *PeerCache) IsWarm(key string) bool {
+	owner := p.remote(key)
+	if owner == "" {
+		return p.local.IsWarm(key)
+	}
+	_, err := p.call(http.MethodHead, owner, key, nil)
+	return err == nil
+}
+
+// Flush -
+// Empties the local partition and hot cache of this instance, every peer flushes its own
+func (p *PeerCache) Flush() error {
+	if p.hot != nil {
+		if err := p.hot.Flush(); err != nil {
+			return err
+		}
+	}
+	return p.local.Flush()
+}
+
+// FlushStale -
+// Flushes the stale items of the local partition and hot cache of this instance
+func (p *PeerCache) FlushStale() error {
+	if p.hot != nil {
+		if err := p.hot.FlushStale(); err != nil {
+			return err
+		}
+	}
+	return p.local.FlushStale()
+}
+
+// RunCleaner -
+// Runs the cleaner of the local partition and hot cache
+func (p *PeerCache) RunCleaner(ctx context.Context) {
+	if p.hot != nil {
+		p.hot.RunCleaner(ctx)
+	}
+	p.local.RunCleaner(ctx)
+}
+
+// Close -
+// Closes the local partition and hot cache
+func (p *PeerCache) Close() error {
+	if p.hot != nil {
+		p.hot.Close()
+	}
+	return p.local.Close()
+}
+
+// ServeHTTP -
+// Serves the keys owned by this instance to its peers: GET and HEAD read a key, PUT writes it
+// and DELETE deletes it. Requests must be authenticated through Secret or Authorize, every request
+// is refused when neither is set. Requests for keys owned by another peer are refused, so peers
+// whose rings disagree during a membership change never write to the wrong partition
+func (p *PeerCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "invalid or missing peer credentials", http.StatusUnauthorized)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, p.basePath) {
+		http.NotFound(w, r)
+		return
+	}
+	key, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), p.basePath))
+	if err != nil || key == "" {
+		http.Error(w, "invalid key", http.StatusBadRequest)
+		return
+	}
+	if owner := p.remote(key); owner != "" {
+		http.Error(w, "key owned by "+owner, http.StatusMisdirectedRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		val, err := p.getLocal(key)
+		if err != nil && (p.load == nil || errors.Is(err, cache.ErrKeyNotFound)) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(val)
+	case http.MethodHead:
+		if !p.local.IsWarm(key) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case http.MethodPut:
+		val, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := p.local.Put(key, val); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if err := p.local.Delete(key); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// authorized -
+// Reports whether the request comes from an authenticated peer
+func (p *PeerCache) authorized(r *http.Request) bool {
+	if p.secret == "" && p.authorize == nil {
+		return false
+	}
+	if p.secret != "" {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(p.secret)) != 1 {
+			return false
+		}
+	}
+	return p.authorize == nil || p.authorize(r)
+}
+
+// call -
+// Sends a request for key to the owning peer, returning the response body
+func (p *PeerCache) call(method, owner, key string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, owner+p.basePath+url.PathEscape(key), reader)
+	if err != nil {
+		return nil, err
+	}
+	if p.secret != "" {
+		req.Header.Set("Authorization", "Bearer "+p.secret)
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		p.logger.Error("unable to reach cache peer", "peer", owner, "key", key, "error", err)
+		return nil, fmt.Errorf("unable to reach cache peer %s: %w", owner, err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response of cache peer %s: %w", owner, err)
+	}
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, errNotFound
+	case res.StatusCode >= 300:
+		return nil, fmt.Errorf("cache peer %s answered %s: %s", owner, res.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
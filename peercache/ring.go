@@ -0,0 +1,48 @@
+package peercache
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// ring maps keys to peers by consistent hashing, each peer owning several points of the ring
+// so keys spread evenly and only a fraction of them move when peers join or leave.
+type ring struct {
+	replicas int
+	hashes   []uint64          // sorted points of the ring
+	owners   map[uint64]string // point -> peer
+}
+
+// newRing -
+// Returns a ring placing replicas points per peer
+func newRing(replicas int, peers []string) *ring {
+	r := &ring{
+		replicas: replicas,
+		owners:   map[uint64]string{},
+	}
+	for _, peer := range peers {
+		for i := 0; i < replicas; i++ {
+			h := xxhash.Sum64String(strconv.Itoa(i) + peer)
+			r.hashes = append(r.hashes, h)
+			r.owners[h] = peer
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// owner -
+// Returns the peer owning key, the first point of the ring at or after its hash
+func (r *ring) owner(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := xxhash.Sum64String(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
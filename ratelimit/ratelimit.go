@@ -0,0 +1,203 @@
+// Package ratelimit limits the rate of events per key on top of the atomic counters of the
+// memory and redis caches, so every process sharing a redis cache enforces the same limits.
+package ratelimit
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+// Counter is a cache atomically incrementing integer values, as the memory and redis caches do.
+type Counter interface {
+	cache.Cache
+	Increment(key string, delta int64) (int64, error)
+	Decrement(key string, delta int64) (int64, error)
+}
+
+// Result describes the outcome of an Allow call.
+type Result struct {
+	Allowed    bool          // the event is within the limit
+	Limit      int64         // events allowed per window
+	Remaining  int64         // events still allowed in the current window
+	RetryAfter time.Duration // wait before the next event may be allowed, zero when allowed
+}
+
+// Limiter decides whether an event identified by key is within its rate limit.
+type Limiter interface {
+	Allow(key string) (Result, error)
+}
+
+// config holds the settings shared by the limiters.
+type config struct {
+	prefix string
+	clock  cache.Clock
+}
+
+type Option func(*config)
+
+// newConfig -
+// Applies the options on top of the defaults
+func newConfig(opts []Option) config {
+	cfg := config{
+		prefix: "ratelimit",
+		clock:  cache.SystemClock{},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// KeyPrefix -
+// Functional option to specify the prefix of the cache keys of a limiter, limiters sharing a
+// cache must use distinct prefixes. Defaults to "ratelimit"
+func KeyPrefix(p string) Option {
+	return func(cfg *config) {
+		cfg.prefix = p
+	}
+}
+
+// Clock -
+// Functional option to override the clock deciding the current window
+func Clock(clock cache.Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = clock
+	}
+}
+
+// FixedWindow allows limit events per key in consecutive windows of fixed length. It is the
+// cheapest limiter, a single increment per event, but allows bursts of twice the limit
+// around the boundary between two windows.
+type FixedWindow struct {
+	c      Counter
+	limit  int64
+	window time.Duration
+	config
+}
+
+// NewFixedWindow -
+// Initialises a new FixedWindow allowing limit events per key in every window
+func NewFixedWindow(c Counter, limit int64, window time.Duration, opts ...Option) *FixedWindow {
+	return &FixedWindow{
+		c:      c,
+		limit:  limit,
+		window: window,
+		config: newConfig(opts),
+	}
+}
+
+// Allow -
+// Counts an event of key in the current window and reports whether it is within the limit
+func (l *FixedWindow) Allow(key string) (Result, error) {
+	now := l.clock.Now()
+	index := now.UnixNano() / int64(l.window)
+	n, err := increment(l.c, cache.Key(l.prefix, key, index), l.window)
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{
+		Allowed:   n <= l.limit,
+		Limit:     l.limit,
+		Remaining: max(l.limit-n, 0),
+	}
+	if !res.Allowed {
+		res.RetryAfter = time.Unix(0, (index+1)*int64(l.window)).Sub(now)
+	}
+	return res, nil
+}
+
+// SlidingWindow allows limit events per key in any window of the given length, estimating the
+// events of the sliding window from the counts of the current and previous fixed windows, the
+// latter weighted by its overlap with the sliding window. Rejected events are not counted.
+type SlidingWindow struct {
+	c      Counter
+	limit  int64
+	window time.Duration
+	config
+}
+
+// NewSlidingWindow -
+// Initialises a new SlidingWindow allowing limit events per key in any window
+func NewSlidingWindow(c Counter, limit int64, window time.Duration, opts ...Option) *SlidingWindow {
+	return &SlidingWindow{
+		c:      c,
+		limit:  limit,
+		window: window,
+		config: newConfig(opts),
+	}
+}
+
+// Allow -
+// Counts an event of key and reports whether the estimated count of the sliding window is
+// within the limit, uncounting the event when it is not
+func (l *SlidingWindow) Allow(key string) (Result, error) {
+	now := l.clock.Now()
+	index := now.UnixNano() / int64(l.window)
+	elapsed := float64(now.UnixNano()-index*int64(l.window)) / float64(l.window)
+
+	var prev int64
+	if data, err := l.c.Get(cache.Key(l.prefix, key, index-1)); err == nil {
+		prev, _ = strconv.ParseInt(string(data), 10, 64)
+	}
+	currKey := cache.Key(l.prefix, key, index)
+	// the current window is read again as the previous one, so it must outlive the next window
+	curr, err := increment(l.c, currKey, 2*l.window)
+	if err != nil {
+		return Result{}, err
+	}
+	weight := float64(prev) * (1 - elapsed)
+	estimate := int64(math.Ceil(weight)) + curr
+	res := Result{
+		Allowed:   estimate <= l.limit,
+		Limit:     l.limit,
+		Remaining: max(l.limit-estimate, 0),
+	}
+	if res.Allowed {
+		return res, nil
+	}
+	if _, err := l.c.Decrement(currKey, 1); err != nil {
+		return res, err
+	}
+	// wait until the weight of the previous window leaves room for the event, or until the
+	// current window ends when it is full on its own
+	curr--
+	res.RetryAfter = time.Unix(0, (index+1)*int64(l.window)).Sub(now)
+	if curr < l.limit && prev > 0 {
+		room := float64(l.limit-curr-1) / float64(prev)
+		wait := time.Duration((1 - room - elapsed) * float64(l.window))
+		if wait > 0 && wait < res.RetryAfter {
+			res.RetryAfter = wait
+		}
+	}
+	return res, nil
+}
+
+// increment -
+// Adds one to the counter at key, giving a new counter ttl as its lifetime when the cache can
+// set the lifetime of a single item
+func increment(c Counter, key string, ttl time.Duration) (int64, error) {
+	n, err := c.Increment(key, 1)
+	if err != nil {
+		return 0, err
+	}
+	if n == 1 {
+		if t, ok := c.(cache.Toucher); ok {
+			if err := t.Touch(key, ttl); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// max -
+// Returns the larger of a and b
+func max(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+// maxAttempts bounds the compare-and-swap attempts of TokenBucket.Allow
+const maxAttempts = 10
+
+// ErrContention is returned by TokenBucket when the bucket of a key kept changing under it.
+var ErrContention = errors.New("rate limit bucket contended, attempts exhausted")
+
+// TokenBucket allows bursts of up to burst events per key, refilling one token every refill
+// interval. The bucket of a key is updated with compare-and-swap, so it requires a cache
+// implementing cache.VersionedPutter, as the memory and redis caches do.
+type TokenBucket struct {
+	c      cache.VersionedPutter
+	burst  int64
+	refill time.Duration
+	config
+}
+
+// NewTokenBucket -
+// Initialises a new TokenBucket holding up to burst tokens per key and refilling one token
+// every refill interval, buckets start full
+func NewTokenBucket(c cache.VersionedPutter, burst int64, refill time.Duration, opts ...Option) *TokenBucket {
+	return &TokenBucket{
+		c:      c,
+		burst:  burst,
+		refill: refill,
+		config: newConfig(opts),
+	}
+}
+
+// Allow -
+// Takes a token from the bucket of key, reporting whether one was available
+func (l *TokenBucket) Allow(key string) (Result, error) {
+	key = cache.Key(l.prefix, key)
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		now := l.clock.Now()
+		tokens, last := float64(l.burst), now
+		data, version, err := l.c.GetVersion(key)
+		if err == nil {
+			if tokens, last, err = decodeBucket(data); err != nil {
+				return Result{}, err
+			}
+		}
+		tokens += float64(now.Sub(last)) / float64(l.refill)
+		if tokens > float64(l.burst) {
+			tokens = float64(l.burst)
+		}
+		res := Result{Limit: l.burst}
+		if tokens >= 1 {
+			tokens--
+			res.Allowed = true
+		} else {
+			res.RetryAfter = time.Duration((1 - tokens) * float64(l.refill))
+		}
+		res.Remaining = int64(tokens)
+		err = l.c.PutIfVersion(key, encodeBucket(tokens, now), version)
+		if errors.Is(err, cache.ErrVersionMismatch) {
+			continue
+		}
+		if err != nil {
+			return Result{}, err
+		}
+		if t, ok := l.c.(cache.Toucher); ok {
+			// an idle bucket is full again after burst refills, it need not outlive them
+			t.Touch(key, time.Duration(l.burst)*l.refill)
+		}
+		return res, nil
+	}
+	return Result{}, ErrContention
+}
+
+// encodeBucket -
+// Returns the stored form of a bucket, its tokens and the unix nano time they were counted
+func encodeBucket(tokens float64, at time.Time) []byte {
+	return []byte(strconv.FormatFloat(tokens, 'g', -1, 64) + " " + strconv.FormatInt(at.UnixNano(), 10))
+}
+
+// decodeBucket -
+// Parses the stored form of a bucket
+func decodeBucket(data []byte) (float64, time.Time, error) {
+	t, at, ok := strings.Cut(string(data), " ")
+	tokens, err := strconv.ParseFloat(t, 64)
+	if err != nil || !ok {
+		return 0, time.Time{}, fmt.Errorf("malformed rate limit bucket %q", data)
+	}
+	nano, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("malformed rate limit bucket %q", data)
+	}
+	return tokens, time.Unix(0, nano), nil
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"sync/atomic"
+)
+
+// ReadOnlyCache rejects writes with ErrReadOnly while read-only mode is on, still serving reads,
+// for instance during incident mitigation or blue/green migrations. The mode can be toggled at runtime.
+type ReadOnlyCache struct {
+	Wrapper
+	readOnly atomic.Bool
+}
+
+// WithReadOnly -
+// Wraps c with a read-only mode toggle, starting in read-only mode when readOnly is true
+func WithReadOnly(c Cache, readOnly bool) *ReadOnlyCache {
+	r := &ReadOnlyCache{
+		Wrapper: Wrapper{Cache: c},
+	}
+	r.readOnly.Store(readOnly)
+	return r
+}
+
+// SetReadOnly -
+// Switches read-only mode on or off
+func (r *ReadOnlyCache) SetReadOnly(readOnly bool) {
+	r.readOnly.Store(readOnly)
+}
+
+// ReadOnly -
+// Reports whether read-only mode is on
+func (r *ReadOnlyCache) ReadOnly() bool {
+	return r.readOnly.Load()
+}
+
+// Put -
+// Saves the value, or returns ErrReadOnly in read-only mode
+func (r *ReadOnlyCache) Put(key string, val []byte) error {
+	if r.readOnly.Load() {
+		return ErrReadOnly
+	}
+	return r.Cache.Put(key, val)
+}
+
+// Delete -
+// Deletes the value, or returns ErrReadOnly in read-only mode
+func (r *ReadOnlyCache) Delete(key string) error {
+	if r.readOnly.Load() {
+		return ErrReadOnly
+	}
+	return r.Cache.Delete(key)
+}
+
+// Flush -
+// Empties the cache, or returns ErrReadOnly in read-only mode
+func (r *ReadOnlyCache) Flush() error {
+	if r.readOnly.Load() {
+		return ErrReadOnly
+	}
+	return r.Cache.Flush()
+}
+
+// FlushStale -
+// Flushes the stale items, or returns ErrReadOnly in read-only mode
+func (r *ReadOnlyCache) FlushStale() error {
+	if r.readOnly.Load() {
+		return ErrReadOnly
+	}
+	return r.Cache.FlushStale()
+}
@@ -0,0 +1,108 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// accessKey -
+// Returns the key of the HASH sidecar holding the access metadata of a cache key
+func (c *RedisCache) accessKey(key string) string {
+	return c.prefix + accessKeyPrefix + key
+}
+
+// recordAccess -
+// Records a sampled read of key into its access sidecar, which expires a window, or a day without
+// one, after the last sampled read. Each sampled read counts for 1/sampleRate hits, so the count estimates every read
+func (c *RedisCache) recordAccess(key string) {
+	if c.accessRate <= 0 || (c.accessRate < 1 && rand.Float64() >= c.accessRate) {
+		return
+	}
+	weight := int64(1)
+	if c.accessRate < 1 {
+		weight = int64(math.Round(1 / c.accessRate))
+	}
+	ctx, cancel := c.ctx(c.getTimeout)
+	defer cancel()
+	sidecar := c.accessKey(key)
+	_, err := c.c.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, sidecar, "hits", weight)
+		pipe.HSet(ctx, sidecar, "last", time.Now().UnixMilli())
+		ttl := c.window
+		if ttl <= 0 {
+			ttl = defaultAccessTTL
+		}
+		pipe.PExpire(ctx, sidecar, ttl)
+		return nil
+	})
+	if err != nil {
+		c.logger.Debug("unable to record cache access", "key", key, "error", err)
+	}
+}
+
+// Inspect -
+// Returns the size and remaining TTL of key in a single round trip, along with its estimated hit
+// count and last sampled read when TrackAccess is enabled
+func (c *RedisCache) Inspect(key string) (cache.EntryInfo, error) {
+	ctx, cancel := c.ctx(c.getTimeout)
+	defer cancel()
+	var (
+		size   *redis.IntCmd
+		pttl   *redis.DurationCmd
+		access *redis.SliceCmd
+	)
+	err := c.do(ctx, func() error {
+		_, err := c.c.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			size = pipe.StrLen(ctx, c.key(key))
+			pttl = pipe.PTTL(ctx, c.key(key))
+			access = pipe.HMGet(ctx, c.accessKey(key), "hits", "last")
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return cache.EntryInfo{}, err
+	}
+	ttl := pttl.Val()
+	if ttl == -2 {
+		return cache.EntryInfo{}, fmt.Errorf("unable to inspect %s: %w", key, cache.ErrKeyNotFound)
+	}
+	info := cache.EntryInfo{
+		Key:  key,
+		Size: int(size.Val()),
+	}
+	if ttl > 0 {
+		info.TTL = ttl
+	}
+	fields := access.Val()
+	if len(fields) == 2 {
+		if s, ok := fields[0].(string); ok {
+			info.Hits, _ = strconv.ParseUint(s, 10, 64)
+		}
+		if s, ok := fields[1].(string); ok {
+			if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+				info.LastAccess = time.UnixMilli(ms)
+			}
+		}
+	}
+	return info, nil
+}
+
+// forgetAccess -
+// Deletes the access sidecars of the prefixed keys through cmd, as the keys are deleted
+func (c *RedisCache) forgetAccess(ctx context.Context, cmd redis.Cmdable, keys ...string) {
+	if c.accessRate <= 0 {
+		return
+	}
+	for _, key := range keys {
+		c.del(ctx, cmd, c.accessKey(strings.TrimPrefix(key, c.prefix)))
+	}
+}
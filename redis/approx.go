@@ -0,0 +1,123 @@
+package redis
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultApproxSamples is the number of keys sampled by ApproxLen to estimate the share of the
+// database held under the key prefix
+const defaultApproxSamples = 1000
+
+// KeyspaceStats describes the population of the cache, estimated from a sample of its keys
+type KeyspaceStats struct {
+	ApproxKeys int64         // estimated number of cache keys, see ApproxLen
+	Sampled    int           // number of cache keys whose TTL was sampled
+	Persistent int           // sampled keys without a TTL
+	MinTTL     time.Duration // shortest remaining TTL among the sampled keys with a TTL
+	MedianTTL  time.Duration
+	P90TTL     time.Duration
+	MaxTTL     time.Duration
+}
+
+// ApproxLen -
+// Estimates the number of cache keys without scanning the whole keyspace: DBSIZE scaled by the
+// share of a sampled SCAN falling under the key prefix, which is exact when the whole database fits
+// in the sample. The tag, pin and access indexes are not counted, with or without a key prefix
+func (c *RedisCache) ApproxLen() (int64, error) {
+	ctx, cancel := c.ctx(c.flushTimeout)
+	defer cancel()
+	var total int64
+	err := c.do(ctx, func() (err error) {
+		total, err = c.c.DBSize(ctx).Result()
+		return err
+	})
+	if err != nil || total == 0 {
+		return 0, err
+	}
+	internal := c.prefix + "go-cache:"
+	var scanned, matched int64
+	err = c.scan(ctx, "*", func(keys []string) error {
+		for _, key := range keys {
+			scanned++
+			if strings.HasPrefix(key, c.prefix) && !strings.HasPrefix(key, internal) {
+				matched++
+			}
+		}
+		if scanned >= defaultApproxSamples {
+			return errStopScan
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+		return matched, nil // the whole database was scanned
+	case err != errStopScan:
+		return 0, err
+	}
+	return total * matched / scanned, nil
+}
+
+// KeyspaceStats -
+// Estimates the number of cache keys through ApproxLen and samples the remaining TTL of up to
+// samples cache keys through pipelined PTTL calls, so dashboards can show the cache population and
+// how soon it expires without a full scan
+func (c *RedisCache) KeyspaceStats(samples int) (KeyspaceStats, error) {
+	approx, err := c.ApproxLen()
+	if err != nil {
+		return KeyspaceStats{}, err
+	}
+	stats := KeyspaceStats{ApproxKeys: approx}
+	ctx := context.Background()
+	internal := c.prefix + "go-cache:"
+	var ttls []time.Duration
+	err = c.scan(ctx, escapePattern(c.prefix)+"*", func(keys []string) error {
+		pipe := c.c.Pipeline()
+		cmds := make([]*redis.DurationCmd, 0, len(keys))
+		for _, key := range keys {
+			if strings.HasPrefix(key, internal) || len(cmds) >= samples-stats.Sampled {
+				continue
+			}
+			cmds = append(cmds, pipe.PTTL(ctx, key))
+		}
+		if len(cmds) > 0 {
+			if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+				return err
+			}
+		}
+		for _, cmd := range cmds {
+			ttl, err := cmd.Result()
+			if err != nil || ttl == -2 {
+				continue // expired between SCAN and PTTL
+			}
+			stats.Sampled++
+			if ttl < 0 {
+				stats.Persistent++
+				continue
+			}
+			ttls = append(ttls, ttl)
+		}
+		if stats.Sampled >= samples {
+			return errStopScan
+		}
+		return nil
+	})
+	if err != nil && err != errStopScan {
+		return KeyspaceStats{}, err
+	}
+	if len(ttls) > 0 {
+		sort.Slice(ttls, func(i, j int) bool { return ttls[i] < ttls[j] })
+		quantile := func(q float64) time.Duration {
+			return ttls[int(q*float64(len(ttls)-1))]
+		}
+		stats.MinTTL = ttls[0]
+		stats.MedianTTL = quantile(0.5)
+		stats.P90TTL = quantile(0.9)
+		stats.MaxTTL = ttls[len(ttls)-1]
+	}
+	return stats, nil
+}
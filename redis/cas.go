@@ -0,0 +1,116 @@
+package redis
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pedreviljoen/go-cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// putIfVersionScript stores ARGV[1] at KEYS[1] with a time to live of ARGV[3] milliseconds when
+// the version of the current value equals ARGV[2], an empty ARGV[2] expects the key to be missing.
+// The version joins the write counter held at KEYS[2] and the SHA1 digest of the value, the counter
+// is incremented by every stored value and lives for ARGV[4] milliseconds. Returns 1 when the
+// value was stored
+var putIfVersionScript = redis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+local version = ''
+if v then
+	version = (redis.call('GET', KEYS[2]) or '0') .. ':' .. redis.sha1hex(v)
+end
+if version ~= ARGV[2] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+redis.call('INCR', KEYS[2])
+redis.call('PEXPIRE', KEYS[2], ARGV[4])
+return 1
+`)
+
+// GetVersion -
+// Accepts a cache key identifier and fetches the value of the corresponding cache key along
+// with its version, made of the number of writes through PutIfVersion and the SHA1 digest of the
+// value, so rewriting an earlier value through PutIfVersion still changes it. Returns redis.Nil
+// when the key does not exist
+func (c *RedisCache) GetVersion(key string) ([]byte, cache.Version, error) {
+	val, err := c.Get(key)
+	if err != nil {
+		return nil, "", err
+	}
+	// the counter is read after the value, a write landing in between yields a version which no
+	// longer matches rather than one matching a value never read
+	ctx, cancel := c.ctx(c.getTimeout)
+	defer cancel()
+	var n string
+	err = c.do(ctx, func() (err error) {
+		n, err = c.c.Get(ctx, c.versionKey(key)).Result()
+		return err
+	})
+	if err == redis.Nil {
+		n, err = "0", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return val, version(n, val), nil
+}
+
+// PutIfVersion -
+// Accepts a cache key identifier, value and the version read through GetVersion, saves the
+// value only if the item has not changed since and returns cache.ErrVersionMismatch otherwise.
+// The zero version only saves the value when the key does not exist
+func (c *RedisCache) PutIfVersion(key string, value []byte, v cache.Version) error {
+	if ok, err := c.admit(key, len(value)); !ok {
+		return err
+	}
+	if c.near != nil {
+		c.near.invalidate(c.key(key))
+	}
+	counterTTL := c.window
+	if counterTTL <= 0 {
+		counterTTL = defaultAccessTTL
+	}
+	ctx, cancel := c.ctx(c.putTimeout)
+	defer cancel()
+	var ok int64
+	err := c.doOnce(ctx, func() (err error) {
+		keys := []string{c.key(key), c.versionKey(key)}
+		ok, err = putIfVersionScript.Run(ctx, c.c, keys, value, string(v), c.ttl().Milliseconds(), counterTTL.Milliseconds()).Int64()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return cache.ErrVersionMismatch
+	}
+	c.stats.Puts.Add(1)
+	return nil
+}
+
+// versionKey -
+// Returns the key holding the write counter of key. It carries the hash tag of the key, or the
+// whole key when it has none, so that both share a hash slot on a cluster
+func (c *RedisCache) versionKey(key string) string {
+	full := c.key(key)
+	tag := full
+	if i := strings.IndexByte(full, '{'); i >= 0 {
+		if j := strings.IndexByte(full[i+1:], '}'); j > 0 {
+			tag = full[i+1 : i+1+j]
+		}
+	}
+	return c.prefix + versionKeyPrefix + "{" + tag + "}" + key
+}
+
+// version -
+// Returns the version of val after n writes, matching the one computed by putIfVersionScript
+func version(n string, val []byte) cache.Version {
+	sum := sha1.Sum(val)
+	return cache.Version(n + ":" + hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,43 @@
+package redis
+
+import (
+	"github.com/redis/go-redis/v9"
+)
+
+// incrByScript adds ARGV[1] to the integer at KEYS[1] and, when the key was created by this
+// call, sets its time to live to ARGV[2] milliseconds. Existing keys keep their TTL
+var incrByScript = redis.NewScript(`
+local created = redis.call('EXISTS', KEYS[1]) == 0
+local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+if created and tonumber(ARGV[2]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return n
+`)
+
+// Increment -
+// Accepts a cache key identifier and atomically adds delta to the integer stored at the key
+// using INCRBY, missing keys start from zero and receive the cache window as their TTL, see TTLJitter
+func (c *RedisCache) Increment(key string, delta int64) (int64, error) {
+	if c.near != nil {
+		c.near.invalidate(c.key(key))
+	}
+	ctx, cancel := c.ctx(c.putTimeout)
+	defer cancel()
+	var n int64
+	err := c.doOnce(ctx, func() (err error) {
+		n, err = incrByScript.Run(ctx, c.c, []string{c.key(key)}, delta, c.ttl().Milliseconds()).Int64()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	c.stats.Puts.Add(1)
+	return n, nil
+}
+
+// Decrement -
+// Accepts a cache key identifier and atomically subtracts delta from the integer stored at the key
+func (c *RedisCache) Decrement(key string, delta int64) (int64, error) {
+	return c.Increment(key, -delta)
+}
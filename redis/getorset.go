@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"github.com/redis/go-redis/v9"
+)
+
+// getOrSetScript returns the value stored at KEYS[1] if any, otherwise stores ARGV[1]
+// with a time to live of ARGV[2] milliseconds, a TTL of zero stores it without expiry
+var getOrSetScript = redis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v then
+	return {1, v}
+end
+if tonumber(ARGV[2]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return {0, ARGV[1]}
+`)
+
+// GetOrSet -
+// Atomically fetches the value of key or, when there is none, stores value with the cache window
+// as its TTL. Returns the value held by the cache afterwards and whether it was already present,
+// so that concurrent misses across processes result in exactly one write
+func (c *RedisCache) GetOrSet(key string, value []byte) ([]byte, bool, error) {
+	if ok, err := c.admit(key, len(value)); !ok {
+		if err != nil {
+			return nil, false, err
+		}
+		return value, false, nil
+	}
+	ctx, cancel := c.ctx(c.putTimeout)
+	defer cancel()
+	var res []any
+	err := c.do(ctx, func() (err error) {
+		res, err = getOrSetScript.Run(ctx, c.c, []string{c.key(key)}, value, c.ttl().Milliseconds()).Slice()
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	loaded := res[0].(int64) == 1
+	if loaded {
+		c.stats.Hits.Add(1)
+	} else {
+		c.stats.Misses.Add(1)
+		c.stats.Puts.Add(1)
+	}
+	return []byte(res[1].(string)), loaded, nil
+}
+
+// GetOrLoad -
+// Fetches the value of key, calling load on a miss and storing its result through GetOrSet.
+// Concurrent misses inside this process share a single call to load, while GetOrSet
+// guarantees that only one of the processes sharing redis writes the value
+func (c *RedisCache) GetOrLoad(key string, load func() ([]byte, error)) ([]byte, error) {
+	if val, err := c.Get(key); err == nil {
+		return val, nil
+	} else if err != redis.Nil {
+		return nil, err
+	}
+	v, err, _ := c.loads.Do(key, func() (any, error) {
+		val, err := load()
+		if err != nil {
+			return nil, err
+		}
+		val, _, err = c.GetOrSet(key, val)
+		return val, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
@@ -0,0 +1,132 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pedreviljoen/go-cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// Keyspace events published by redis which invalidate a cache entry
+const (
+	EventDel     = "del"
+	EventExpired = "expired"
+	EventEvicted = "evicted"
+)
+
+// KeyspaceListener delivers redis keyspace notifications for the keys of a cache to a callback
+type KeyspaceListener struct {
+	pubsubs []*redis.PubSub // one per node, notifications are local to the node holding the key
+	done    chan struct{}
+}
+
+// ListenKeyspace -
+// Subscribes to the keyevent notifications of the given events, defaulting to del, expired and evicted,
+// and invokes fn with the event and cache key until ctx is done or the listener is closed. Keys outside
+// the configured key prefix are ignored. The server must publish the events, e.g. notify-keyspace-events "Egxe".
+// On a cluster every master node and on a ring every shard is subscribed, nodes joining later are not,
+// and fn is never called concurrently
+func (c *RedisCache) ListenKeyspace(ctx context.Context, fn func(event, key string), events ...string) (*KeyspaceListener, error) {
+	if len(events) == 0 {
+		events = []string{EventDel, EventExpired, EventEvicted}
+	}
+	db := "*"
+	if client, ok := c.c.(*redis.Client); ok {
+		db = strconv.Itoa(client.Options().DB)
+	}
+	channels := make([]string, len(events))
+	for i, event := range events {
+		channels[i] = "__keyevent@" + db + "__:" + event
+	}
+
+	l := &KeyspaceListener{done: make(chan struct{})}
+	var mutex sync.Mutex
+	subscribe := func(ctx context.Context, node *redis.Client) error {
+		pubsub := node.PSubscribe(ctx, channels...)
+		if _, err := pubsub.Receive(ctx); err != nil {
+			pubsub.Close()
+			return err
+		}
+		mutex.Lock()
+		l.pubsubs = append(l.pubsubs, pubsub)
+		mutex.Unlock()
+		return nil
+	}
+	var err error
+	switch client := c.c.(type) {
+	case *redis.ClusterClient:
+		err = client.ForEachMaster(ctx, subscribe)
+	case *redis.Ring:
+		err = client.ForEachShard(ctx, subscribe)
+	default:
+		pubsub := c.c.PSubscribe(ctx, channels...)
+		if _, err = pubsub.Receive(ctx); err != nil {
+			pubsub.Close()
+		} else {
+			l.pubsubs = append(l.pubsubs, pubsub)
+		}
+	}
+	if err != nil {
+		for _, pubsub := range l.pubsubs {
+			pubsub.Close()
+		}
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	for _, pubsub := range l.pubsubs {
+		wg.Add(1)
+		go func(pubsub *redis.PubSub) {
+			defer wg.Done()
+			ch := pubsub.Channel()
+			for {
+				select {
+				case msg, ok := <-ch:
+					if !ok {
+						return
+					}
+					if !strings.HasPrefix(msg.Payload, c.prefix) {
+						continue
+					}
+					event := msg.Channel[strings.LastIndexByte(msg.Channel, ':')+1:]
+					mutex.Lock()
+					fn(event, strings.TrimPrefix(msg.Payload, c.prefix))
+					mutex.Unlock()
+				case <-ctx.Done():
+					pubsub.Close()
+					return
+				}
+			}
+		}(pubsub)
+	}
+	go func() {
+		wg.Wait()
+		close(l.done)
+	}()
+	return l, nil
+}
+
+// Close -
+// Stops the listener and waits for the delivering go routines to exit, it is safe to call more than once
+func (l *KeyspaceListener) Close() error {
+	var err error
+	for _, pubsub := range l.pubsubs {
+		if cerr := pubsub.Close(); cerr != nil && cerr != redis.ErrClosed && err == nil {
+			err = cerr
+		}
+	}
+	<-l.done
+	return err
+}
+
+// EvictFrom -
+// Returns a keyspace callback deleting every notified key from local, typically an in-memory L1
+// cache in front of redis, so that replicas sharing redis see each other's invalidations
+func EvictFrom(local cache.Cache) func(event, key string) {
+	return func(event, key string) {
+		local.Delete(key)
+	}
+}
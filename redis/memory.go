@@ -0,0 +1,101 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// errStopScan stops a scan early without reporting an error
+var errStopScan = errors.New("stop scan")
+
+// KeyUsage is the memory used by a single cache key
+type KeyUsage struct {
+	Key   string
+	Bytes int64
+}
+
+// MemoryReport aggregates the memory usage of a sample of cache keys
+type MemoryReport struct {
+	Sampled    int        // number of keys sampled
+	TotalBytes int64      // memory used by the sampled keys
+	AvgBytes   int64      // average memory used per sampled key
+	Top        []KeyUsage // largest sampled keys, largest first
+}
+
+// MemoryUsage -
+// Returns the number of bytes key and its value take up in redis, as reported by MEMORY USAGE
+func (c *RedisCache) MemoryUsage(key string) (int64, error) {
+	ctx, cancel := c.ctx(c.getTimeout)
+	defer cancel()
+	var n int64
+	err := c.do(ctx, func() (err error) {
+		n, err = c.c.MemoryUsage(ctx, c.key(key)).Result()
+		return err
+	})
+	return n, err
+}
+
+// MemoryReport -
+// Samples the memory usage of up to samples cache keys through pipelined MEMORY USAGE calls and
+// reports the top largest ones, so operators can find which keys dominate redis memory. The
+// report is bounded by the flush timeout, and each batch of calls follows the retry policy
+func (c *RedisCache) MemoryReport(samples, top int) (MemoryReport, error) {
+	if samples <= 0 || top < 0 {
+		return MemoryReport{}, fmt.Errorf("unable to report memory usage of %d samples and %d top keys", samples, top)
+	}
+	ctx, cancel := c.ctx(c.flushTimeout)
+	defer cancel()
+	var report MemoryReport
+	err := c.scan(ctx, escapePattern(c.prefix)+"*", func(keys []string) error {
+		remaining := samples - report.Sampled
+		if remaining <= 0 {
+			return errStopScan
+		}
+		if len(keys) > remaining {
+			keys = keys[:remaining]
+		}
+		cmds := make([]*redis.IntCmd, len(keys))
+		err := c.do(ctx, func() error {
+			_, err := c.c.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for i, key := range keys {
+					cmds[i] = pipe.MemoryUsage(ctx, key)
+				}
+				return nil
+			})
+			return err
+		})
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		for i, cmd := range cmds {
+			n, err := cmd.Result()
+			if err != nil {
+				continue // expired between SCAN and MEMORY USAGE
+			}
+			report.Sampled++
+			report.TotalBytes += n
+			report.Top = append(report.Top, KeyUsage{Key: strings.TrimPrefix(keys[i], c.prefix), Bytes: n})
+		}
+		sort.Slice(report.Top, func(i, j int) bool {
+			return report.Top[i].Bytes > report.Top[j].Bytes
+		})
+		if len(report.Top) > top {
+			report.Top = report.Top[:top]
+		}
+		if report.Sampled >= samples {
+			return errStopScan
+		}
+		return nil
+	})
+	if err != nil && err != errStopScan {
+		return MemoryReport{}, err
+	}
+	if report.Sampled > 0 {
+		report.AvgBytes = report.TotalBytes / int64(report.Sampled)
+	}
+	return report, nil
+}
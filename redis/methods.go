@@ -2,9 +2,13 @@ package redis
 
 import (
 	"context"
-	"runtime"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/pedreviljoen/go-cache"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -12,122 +16,987 @@ import (
 // Accept a cache key identifier and determines if the cache is still within
 // the time duration window
 func (c *RedisCache) IsWarm(key string) bool {
-	_, err := c.c.Exists(context.Background(), key).Result()
-	return err != redis.Nil
+	_, ok := c.Warmth(key)
+	return ok
+}
+
+// IsWarmMulti -
+// Accepts cache key identifiers and reports for each whether it is cached inside the time window,
+// through pipelined PTTL calls costing a single round trip per node. Keys are reported cold when
+// redis cannot be reached
+func (c *RedisCache) IsWarmMulti(keys []string) map[string]bool {
+	warm := make(map[string]bool, len(keys))
+	if len(keys) == 0 {
+		return warm
+	}
+	ctx, cancel := c.ctx(c.getTimeout)
+	defer cancel()
+	cmds := make([]*redis.DurationCmd, len(keys))
+	err := c.do(ctx, func() error {
+		_, err := c.c.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range keys {
+				cmds[i] = pipe.PTTL(ctx, c.key(key))
+			}
+			return nil
+		})
+		return err
+	})
+	for i, key := range keys {
+		if err != nil || cmds[i] == nil {
+			warm[key] = false
+			continue
+		}
+		ttl, cmdErr := cmds[i].Result()
+		warm[key] = cmdErr == nil && ttl != -2
+	}
+	return warm
+}
+
+// Warmth -
+// Accepts a cache key identifier and returns the remaining time to live of the item,
+// the boolean is false when the key is missing or the lookup fails. Items saved
+// without an expiry report a zero duration
+func (c *RedisCache) Warmth(key string) (time.Duration, bool) {
+	ctx, cancel := c.ctx(c.getTimeout)
+	defer cancel()
+	var ttl time.Duration
+	err := c.do(ctx, func() (err error) {
+		ttl, err = c.c.PTTL(ctx, c.key(key)).Result()
+		return err
+	})
+	switch {
+	case err != nil, ttl == -2:
+		return 0, false
+	case ttl < 0:
+		return 0, true
+	}
+	return ttl, true
 }
 
 // Put -
 // Accepts a cache key identifier and value, save the respective key and value
 // inside the Redis cache
 func (c *RedisCache) Put(key string, value []byte) error {
-	if err := c.c.Set(context.Background(), key, value, c.window).Err(); err != nil {
+	if ok, err := c.admit(key, len(value)); !ok {
+		return err
+	}
+	if c.near != nil {
+		c.near.invalidate(c.key(key))
+	}
+	ctx, cancel := c.ctx(c.putTimeout)
+	defer cancel()
+	err := c.do(ctx, func() error {
+		return c.c.Set(ctx, c.key(key), value, c.ttl()).Err()
+	})
+	if err != nil {
+		return err
+	}
+	c.stats.Puts.Add(1)
+	return nil
+}
+
+// Add -
+// Accepts a cache key identifier and value, saves the value only if the key does not
+// exist yet using SET NX and returns cache.ErrKeyExists otherwise
+func (c *RedisCache) Add(key string, value []byte) error {
+	if ok, err := c.admit(key, len(value)); !ok {
+		return err
+	}
+	ctx, cancel := c.ctx(c.putTimeout)
+	defer cancel()
+	var ok bool
+	err := c.doOnce(ctx, func() (err error) {
+		ok, err = c.c.SetNX(ctx, c.key(key), value, c.ttl()).Result()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return cache.ErrKeyExists
+	}
+	if c.near != nil {
+		c.near.invalidate(c.key(key))
+	}
+	c.stats.Puts.Add(1)
+	return nil
+}
+
+// Replace -
+// Accepts a cache key identifier and value, saves the value only if the key already
+// exists using SET XX and returns cache.ErrKeyNotFound otherwise
+func (c *RedisCache) Replace(key string, value []byte) error {
+	if ok, err := c.admit(key, len(value)); !ok {
+		return err
+	}
+	ctx, cancel := c.ctx(c.putTimeout)
+	defer cancel()
+	var ok bool
+	err := c.do(ctx, func() (err error) {
+		ok, err = c.c.SetXX(ctx, c.key(key), value, c.ttl()).Result()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return cache.ErrKeyNotFound
+	}
+	if c.near != nil {
+		c.near.invalidate(c.key(key))
+	}
+	c.stats.Puts.Add(1)
+	return nil
+}
+
+// Swap -
+// Accepts a cache key identifier and value, saves the value and returns the value it
+// replaced atomically using SET GET, the previous value is nil when the key was not set
+func (c *RedisCache) Swap(key string, value []byte) ([]byte, error) {
+	if ok, err := c.admit(key, len(value)); !ok {
+		return nil, err
+	}
+	ctx, cancel := c.ctx(c.putTimeout)
+	defer cancel()
+	var old []byte
+	err := c.doOnce(ctx, func() error {
+		prev, err := c.c.SetArgs(ctx, c.key(key), value, redis.SetArgs{Get: true, TTL: c.ttl()}).Result()
+		if err == nil {
+			old = []byte(prev)
+		}
+		return err
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	if c.near != nil {
+		c.near.invalidate(c.key(key))
+	}
+	c.stats.Puts.Add(1)
+	return old, nil
+}
+
+// appendScript appends ARGV[1] to the value at KEYS[1] and, when the key was created by this
+// call, sets its time to live to ARGV[2] milliseconds. Existing keys keep their TTL. When the
+// resulting value would exceed ARGV[3] bytes, unless it is zero, nothing is appended and the
+// negated resulting length is returned
+var appendScript = redis.NewScript(`
+local size = redis.call('STRLEN', KEYS[1]) + string.len(ARGV[1])
+if tonumber(ARGV[3]) > 0 and size > tonumber(ARGV[3]) then
+	return -size
+end
+local created = redis.call('EXISTS', KEYS[1]) == 0
+local n = redis.call('APPEND', KEYS[1], ARGV[1])
+if created and tonumber(ARGV[2]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return n
+`)
+
+// Append -
+// Accepts a cache key identifier and appends data to its value using APPEND, missing keys
+// are saved with data as their value and receive the cache window as their TTL. MaxValueSize
+// applies to the resulting value
+func (c *RedisCache) Append(key string, data []byte) error {
+	if ok, err := c.admit(key, len(data)); !ok {
 		return err
 	}
+	if c.near != nil {
+		c.near.invalidate(c.key(key))
+	}
+	ctx, cancel := c.ctx(c.putTimeout)
+	defer cancel()
+	var n int64
+	err := c.doOnce(ctx, func() (err error) {
+		n, err = appendScript.Run(ctx, c.c, []string{c.key(key)}, data, c.ttl().Milliseconds(), c.maxValueSize).Int64()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		_, err := c.admit(key, int(-n))
+		return err
+	}
+	c.stats.Puts.Add(1)
 	return nil
 }
 
+// PutTagged -
+// Accepts a cache key identifier, value and a set of tags, saves the key and value
+// inside the Redis cache and adds the key to a secondary SET index for each tag
+func (c *RedisCache) PutTagged(key string, value []byte, tags ...string) error {
+	if ok, err := c.admit(key, len(value)); !ok {
+		return err
+	}
+	ctx, cancel := c.ctx(c.putTimeout)
+	defer cancel()
+	err := c.do(ctx, func() error {
+		_, err := c.c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, c.key(key), value, c.ttl())
+			for _, tag := range tags {
+				pipe.SAdd(ctx, c.tagKey(tag), c.key(key))
+				if c.window > 0 {
+					pipe.Expire(ctx, c.tagKey(tag), c.window+time.Duration(float64(c.window)*c.ttlJitter))
+				}
+			}
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	c.stats.Puts.Add(1)
+	return nil
+}
+
+// InvalidateTag -
+// Deletes every cache item which was saved with the given tag, along with the tag index itself
+func (c *RedisCache) InvalidateTag(tag string) error {
+	ctx, cancel := c.ctx(c.deleteTimeout)
+	defer cancel()
+	keys, err := c.c.SMembers(ctx, c.tagKey(tag)).Result()
+	if err != nil {
+		return err
+	}
+	n, err := c.evict(ctx, keys, false)
+	if err != nil {
+		return err
+	}
+	c.stats.Deletes.Add(uint64(n))
+	return c.del(ctx, c.c, c.tagKey(tag)).Err()
+}
+
+// ttl -
+// Returns the TTL of a key being written, the cache window randomised by the configured TTL jitter
+func (c *RedisCache) ttl() time.Duration {
+	if c.ttlJitter <= 0 || c.window <= 0 {
+		return c.window
+	}
+	d := time.Duration(float64(c.window) * (1 + c.ttlJitter*(2*rand.Float64()-1)))
+	if d <= 0 {
+		return c.window
+	}
+	return d
+}
+
+// admit -
+// Reports whether a value of size bytes may be written under key, the error is nil when an
+// oversized value is skipped rather than rejected, see MaxValueSize
+func (c *RedisCache) admit(key string, size int) (bool, error) {
+	if c.maxValueSize <= 0 || size <= c.maxValueSize {
+		return true, nil
+	}
+	if c.skipOversized {
+		c.logger.Debug("skipped oversized cache value", "key", key, "size", size, "max", c.maxValueSize)
+		return false, nil
+	}
+	return false, fmt.Errorf("unable to save %s of %d bytes, limit is %d: %w", key, size, c.maxValueSize, cache.ErrValueTooLarge)
+}
+
+// tagKey -
+// Returns the key of the SET holding every cache key carrying tag
+func (c *RedisCache) tagKey(tag string) string {
+	return c.prefix + tagKeyPrefix + tag
+}
+
+// key -
+// Returns the redis key of a cache key, namespaced by the configured key prefix
+func (c *RedisCache) key(key string) string {
+	return c.prefix + key
+}
+
 // Get -
 // Accepts a cache key identifier and fetches the value of the corresponding cache key
 func (c *RedisCache) Get(key string) ([]byte, error) {
-	val, err := c.c.Get(context.Background(), key).Result()
+	if c.sliding {
+		return c.slide(key)
+	}
+	var epoch uint64
+	if c.near != nil {
+		val, e, ok := c.near.get(c.key(key), time.Now())
+		if ok {
+			c.stats.Hits.Add(1)
+			return val, nil
+		}
+		epoch = e
+	}
+	ctx, cancel := c.ctx(c.getTimeout)
+	defer cancel()
+	var val []byte
+	err := c.do(ctx, func() (err error) {
+		val, err = c.c.Get(ctx, c.key(key)).Bytes()
+		return err
+	})
+	if err == redis.Nil {
+		c.stats.Misses.Add(1)
+	}
 	if err != nil {
 		return nil, err
 	}
+	c.stats.Hits.Add(1)
+	c.recordAccess(key)
+	if c.near != nil {
+		var expires time.Time
+		if c.window > 0 {
+			expires = time.Now().Add(c.window)
+		}
+		c.near.set(c.key(key), val, expires, epoch)
+	}
+	return val, nil
+}
+
+// slideScript returns the value at KEYS[1] and restarts its time to live at ARGV[1]
+// milliseconds, keys without a TTL are left as is
+var slideScript = redis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v and tonumber(ARGV[1]) > 0 and redis.call('PTTL', KEYS[1]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return v
+`)
+
+// slide -
+// Fetches the value of key like Get and restarts its TTL at the cache window
+func (c *RedisCache) slide(key string) ([]byte, error) {
+	ctx, cancel := c.ctx(c.getTimeout)
+	defer cancel()
+	var val string
+	err := c.do(ctx, func() (err error) {
+		val, err = slideScript.Run(ctx, c.c, []string{c.key(key)}, c.ttl().Milliseconds()).Text()
+		return err
+	})
+	if err == redis.Nil {
+		c.stats.Misses.Add(1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.stats.Hits.Add(1)
+	c.recordAccess(key)
 	return []byte(val), nil
 }
 
+// GetWithTTL -
+// Accepts a cache key identifier and fetches the value of the corresponding cache key along
+// with its remaining time to live in a single round trip, items saved without an expiry
+// report cache.NoExpiry. The near cache is bypassed so the lifetime is always accurate
+func (c *RedisCache) GetWithTTL(key string) ([]byte, time.Duration, error) {
+	ctx, cancel := c.ctx(c.getTimeout)
+	defer cancel()
+	var get *redis.StringCmd
+	var pttl *redis.DurationCmd
+	err := c.do(ctx, func() error {
+		_, err := c.c.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			get = pipe.Get(ctx, c.key(key))
+			pttl = pipe.PTTL(ctx, c.key(key))
+			return nil
+		})
+		return err
+	})
+	if err == redis.Nil {
+		c.stats.Misses.Add(1)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	val, err := get.Bytes()
+	if err != nil {
+		return nil, 0, err
+	}
+	c.stats.Hits.Add(1)
+	c.recordAccess(key)
+	ttl := pttl.Val()
+	if ttl < 0 {
+		ttl = cache.NoExpiry
+	}
+	return val, ttl, nil
+}
+
+// Touch -
+// Accepts a cache key identifier and resets the time to live of the item to ttl without
+// rewriting its value, a zero ttl resets the item to the cache window. Returns redis.Nil
+// when the key does not exist
+func (c *RedisCache) Touch(key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = c.window
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("unable to touch %s without a ttl or cache window", key)
+	}
+	ctx, cancel := c.ctx(c.putTimeout)
+	defer cancel()
+	var ok bool
+	err := c.do(ctx, func() (err error) {
+		ok, err = c.c.PExpire(ctx, c.key(key), ttl).Result()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return redis.Nil
+	}
+	return nil
+}
+
+// Persist -
+// Accepts a cache key identifier and removes the expiry of the item, pinning it so that
+// FlushStale and the cleaner keep it. Writing the key again with a time to live unpins it.
+// Returns redis.Nil when the key does not exist
+func (c *RedisCache) Persist(key string) error {
+	ctx, cancel := c.ctx(c.putTimeout)
+	defer cancel()
+	var exists *redis.IntCmd
+	err := c.do(ctx, func() error {
+		_, err := c.c.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			// the marker is set first, so the cleaner never sees the key without expiry and without marker
+			pipe.Set(ctx, c.pinKey(key), 1, 0)
+			exists = pipe.Exists(ctx, c.key(key))
+			pipe.Persist(ctx, c.key(key))
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if exists.Val() == 0 {
+		c.del(ctx, c.c, c.pinKey(key))
+		return redis.Nil
+	}
+	return nil
+}
+
+// pinKey -
+// Returns the key of the marker recording that a cache key was pinned through Persist
+func (c *RedisCache) pinKey(key string) string {
+	return c.prefix + pinnedKeyPrefix + key
+}
+
+// Pop -
+// Accepts a cache key identifier, fetches the value of the corresponding cache key and
+// removes it atomically using GETDEL so only a single caller receives it. Returns redis.Nil
+// when the key does not exist
+func (c *RedisCache) Pop(key string) ([]byte, error) {
+	if c.near != nil {
+		c.near.invalidate(c.key(key))
+	}
+	ctx, cancel := c.ctx(c.deleteTimeout)
+	defer cancel()
+	var val []byte
+	err := c.doOnce(ctx, func() (err error) {
+		val, err = c.c.GetDel(ctx, c.key(key)).Bytes()
+		return err
+	})
+	if err == redis.Nil {
+		c.stats.Misses.Add(1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.stats.Hits.Add(1)
+	c.stats.Deletes.Add(1)
+	if c.onEvicted != nil {
+		c.onEvicted(key, val)
+	}
+	return val, nil
+}
+
 // Delete -
 // Accepts a cache item key identifier and deletes the value of the corresponding cache key
 func (c *RedisCache) Delete(key string) error {
-	if err := c.c.Del(context.Background(), key).Err(); err != nil {
+	if c.near != nil {
+		c.near.invalidate(c.key(key))
+	}
+	ctx, cancel := c.ctx(c.deleteTimeout)
+	defer cancel()
+	var n int64
+	err := c.do(ctx, func() (err error) {
+		n, err = c.evict(ctx, []string{c.key(key)}, false)
+		return err
+	})
+	if err != nil {
 		return err
 	}
+	c.stats.Deletes.Add(uint64(n))
 	return nil
 }
 
 // Flush -
-// Empties the entire cache
+// Empties the entire cache in pipelined batches, restricted to the keys starting with the key prefix when one is configured.
+// Keys held under "go-cache:" by other components, such as locks, are left alone
 func (c *RedisCache) Flush() error {
-	ctx := context.Background()
-	iter := c.c.Scan(ctx, 0, "", 0).Iterator()
-	for iter.Next(ctx) {
-		key := iter.Val()
-		if err := c.c.Del(ctx, key).Err(); err != nil {
-			return err
-		}
+	if c.near != nil {
+		c.near.clear()
 	}
-	if err := iter.Err(); err != nil {
+	ctx, cancel := c.ctx(c.flushTimeout)
+	defer cancel()
+	return c.scan(ctx, escapePattern(c.prefix)+"*", func(keys []string) error {
+		_, err := c.evict(ctx, c.owned(keys), false)
 		return err
-	}
-	return nil
+	})
 }
 
 // FlushStale -
 // Iterates over all cache key-value items and removes all stale cache items
 func (c *RedisCache) FlushStale() error {
-	ctx := context.Background()
-	iter := c.c.Scan(ctx, 0, "", 0).Iterator()
-	for iter.Next(ctx) {
-		key := iter.Val()
-		d, err := c.c.TTL(ctx, key).Result()
-		if err != nil {
+	return c.FlushStaleByPrefix("")
+}
+
+// FlushStaleByPrefix -
+// Iterates over all cache key-value items starting with prefix and removes the stale ones,
+// checking TTLs and deleting keys in pipelined batches
+func (c *RedisCache) FlushStaleByPrefix(prefix string) error {
+	c.stats.StaleFlushes.Add(1)
+	ctx, cancel := c.ctx(c.flushTimeout)
+	defer cancel()
+	markers := c.prefix + pinnedKeyPrefix
+	evicted := 0
+	err := c.scan(ctx, escapePattern(c.key(prefix))+"*", func(keys []string) error {
+		pipe := c.c.Pipeline()
+		ttls := make([]*redis.DurationCmd, len(keys))
+		targets := make([]*redis.IntCmd, len(keys))
+		for i, key := range keys {
+			if strings.HasPrefix(key, markers) {
+				// markers outlive the keys they pin, they are dropped once their key is gone
+				targets[i] = pipe.Exists(ctx, c.prefix+strings.TrimPrefix(key, markers))
+				continue
+			}
+			ttls[i] = pipe.TTL(ctx, key)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
 			return err
 		}
-
-		if d == -1 { // -1 means no TTL
-			if err := c.c.Del(ctx, key).Err(); err != nil {
+		var candidates, orphans []string
+		for i, key := range keys {
+			switch {
+			case targets[i] != nil && targets[i].Val() == 0:
+				orphans = append(orphans, key)
+			case ttls[i] != nil && ttls[i].Val() == -1: // -1 means no TTL
+				candidates = append(candidates, key)
+			}
+		}
+		// markers are only looked up for the keys without expiry, leaving writes free of any index
+		stale := make([]string, 0, len(candidates))
+		if len(candidates) > 0 {
+			pipe := c.c.Pipeline()
+			pinned := make([]*redis.IntCmd, len(candidates))
+			for i, key := range candidates {
+				pinned[i] = pipe.Exists(ctx, c.pinKey(strings.TrimPrefix(key, c.prefix)))
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
 				return err
 			}
+			for i, key := range candidates {
+				if pinned[i].Val() == 0 {
+					stale = append(stale, key)
+				}
+			}
 		}
-	}
-	if err := iter.Err(); err != nil {
+		if len(orphans) > 0 {
+			if _, err := c.evict(ctx, orphans, false); err != nil {
+				return err
+			}
+		}
+		n, err := c.evict(ctx, stale, true)
+		evicted += int(n)
+		return err
+	})
+	c.stats.Evictions.Add(uint64(evicted))
+	if err != nil {
 		return err
 	}
+	c.logger.Debug("flushed stale cache items", "prefix", prefix, "evicted", evicted)
 	return nil
 }
 
 // RunCleaner -
 // Initialises and starts a new cleaner process in a separate go routine
 // this process flushes cache items inside the cache which are older than the configured cache window
-func (c *RedisCache) RunCleaner() {
-	j := c.initCleaner()
-	j.run(c)
-	runtime.SetFinalizer(c, j.stopCleaner)
+// and runs until ctx is done or the cache is closed
+func (c *RedisCache) RunCleaner(ctx context.Context) {
+	c.lifecycle.Lock()
+	defer c.lifecycle.Unlock()
+	if c.closed || c.cleaner.running() {
+		return
+	}
+	if c.cleanerInterval() <= 0 {
+		c.logger.Error("cache cleaner not started, no clean interval or window configured")
+		return
+	}
+	c.cleaner = c.initCleaner()
+	c.cleaner.run(ctx, c)
+}
+
+// stopCleaner -
+// Stops the running cleaner, if any, and waits for it to exit
+func (c *RedisCache) stopCleaner() {
+	c.lifecycle.Lock()
+	j := c.cleaner
+	c.cleaner = nil
+	c.lifecycle.Unlock()
+	if j != nil {
+		j.stopCleaner()
+	}
+}
+
+// Close -
+// Stops the cleaner process and closes the underlying redis client, it is safe to call more than once
+func (c *RedisCache) Close() error {
+	c.lifecycle.Lock()
+	if c.closed {
+		c.lifecycle.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.lifecycle.Unlock()
+	c.stopCleaner()
+	if c.near != nil {
+		if err := c.near.close(); err != nil {
+			return err
+		}
+	}
+	return c.c.Close()
+}
+
+// lastCleanerRun -
+// Returns the time the cleaner last ran, the zero time when it never ran
+func (c *RedisCache) lastCleanerRun() time.Time {
+	ns := c.lastClean.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// cleanerInterval -
+// Returns how often the cleaner runs, the configured clean interval or else the cache window
+func (c *RedisCache) cleanerInterval() time.Duration {
+	if c.cleanInterval > 0 {
+		return c.cleanInterval
+	}
+	return c.window
 }
 
 // initCleaner -
 // Initialises a new cleaner
 func (c *RedisCache) initCleaner() *cleaner {
 	return &cleaner{
-		Interval: c.window,
-		stop:     make(chan bool),
+		Interval: c.cleanerInterval(),
+		Jitter:   c.cleanJitter,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
 	}
 }
 
 // runCleaner -
 // Runs the cleaner inside a go routine
-func (j *cleaner) run(c *RedisCache) {
-	go j.cleanup(c)
+func (j *cleaner) run(ctx context.Context, c *RedisCache) {
+	go j.cleanup(ctx, c)
 }
 
 // cleanup -
 // Calls the underlying FlushStale method of the cache which clears
 // stale cache items
-func (j *cleaner) cleanup(c *RedisCache) {
-	ticker := time.NewTicker(j.Interval)
+func (j *cleaner) cleanup(ctx context.Context, c *RedisCache) {
+	defer close(j.done)
+	timer := time.NewTimer(j.next())
 	for {
 		select {
-		case <-ticker.C:
-			c.FlushStale()
+		case <-timer.C:
+			if err := c.FlushStale(); err != nil {
+				c.logger.Error("cache cleaner failed to flush stale items", "error", err)
+				if c.onCleanerError != nil {
+					c.onCleanerError(err)
+				}
+			}
+			c.lastClean.Store(time.Now().UnixNano())
+			timer.Reset(j.next())
 		case <-j.stop:
-			ticker.Stop()
+			timer.Stop()
+			return
+		case <-ctx.Done():
+			timer.Stop()
 			return
 		}
 	}
 }
 
+// next -
+// Returns the delay until the next cleaner run, the interval randomised by ±Jitter
+func (j *cleaner) next() time.Duration {
+	if j.Jitter <= 0 {
+		return j.Interval
+	}
+	d := time.Duration(float64(j.Interval) * (1 + j.Jitter*(2*rand.Float64()-1)))
+	if d <= 0 {
+		return j.Interval
+	}
+	return d
+}
+
+// running -
+// Reports whether the cleaner go-routine is still running, a nil cleaner never runs
+func (j *cleaner) running() bool {
+	if j == nil {
+		return false
+	}
+	select {
+	case <-j.done:
+		return false
+	default:
+		return true
+	}
+}
+
 // stopCleaner -
-// Sends a stop signal to the go-routine running the cleaner process
+// Signals the go-routine running the cleaner process to stop and waits for it to exit,
+// it is safe to call more than once
 func (j *cleaner) stopCleaner() {
-	j.stop <- true
+	j.once.Do(func() {
+		close(j.stop)
+	})
+	<-j.done
+}
+
+// DeleteByPrefix -
+// Accepts a key prefix and deletes every cache item whose key starts with it
+func (c *RedisCache) DeleteByPrefix(prefix string) error {
+	return c.DeleteByPattern(escapePattern(prefix) + "*")
+}
+
+// DeleteByPattern -
+// Accepts a redis glob pattern, scans for all matching keys and deletes them in pipelined batches, leaving
+// the keys held under "go-cache:" by other components, such as locks, alone
+func (c *RedisCache) DeleteByPattern(pattern string) error {
+	ctx, cancel := c.ctx(c.deleteTimeout)
+	defer cancel()
+	return c.scan(ctx, escapePattern(c.prefix)+pattern, func(keys []string) error {
+		n, err := c.evict(ctx, c.owned(keys), false)
+		c.stats.Deletes.Add(uint64(n))
+		return err
+	})
+}
+
+// Scan -
+// Calls fn for every key under the configured key prefix, with the prefix trimmed, until fn returns an
+// error or ctx is done. The tag and pin indexes maintained by the cache are skipped
+func (c *RedisCache) Scan(ctx context.Context, fn func(key string) error) error {
+	internal := c.prefix + "go-cache:"
+	return c.scan(ctx, escapePattern(c.prefix)+"*", func(keys []string) error {
+		for _, key := range keys {
+			if strings.HasPrefix(key, internal) {
+				continue
+			}
+			if err := fn(strings.TrimPrefix(key, c.prefix)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// scan -
+// Iterates over every key matching pattern, handing them to fn in batches of the configured batch size.
+// On a cluster every master node and on a ring every shard is scanned, fn is never called concurrently
+func (c *RedisCache) scan(ctx context.Context, pattern string, fn func(keys []string) error) error {
+	var mutex sync.Mutex
+	each := func(ctx context.Context, node *redis.Client) error {
+		return c.scanNode(ctx, node, pattern, func(keys []string) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return fn(keys)
+		})
+	}
+	switch client := c.c.(type) {
+	case *redis.ClusterClient:
+		return client.ForEachMaster(ctx, each)
+	case *redis.Ring:
+		return client.ForEachShard(ctx, each)
+	default:
+		return c.scanNode(ctx, c.c, pattern, fn)
+	}
+}
+
+// scanNode -
+// Iterates over every key of a single node matching pattern, handing them to fn in batches
+func (c *RedisCache) scanNode(ctx context.Context, node redis.Cmdable, pattern string, fn func(keys []string) error) error {
+	iter := node.Scan(ctx, 0, pattern, int64(c.batchSize)).Iterator()
+	keys := make([]string, 0, c.batchSize)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == c.batchSize {
+			if err := fn(keys); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return fn(keys)
+}
+
+// dbSize -
+// Returns the number of keys inside the selected redis database, -1 when it can not be determined
+func (c *RedisCache) dbSize() int64 {
+	n, err := c.c.DBSize(context.Background()).Result()
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// ctx -
+// Returns a context bounded by timeout, or a background context when timeout is zero
+func (c *RedisCache) ctx(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.Background(), func() {}
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// Client -
+// Returns the underlying redis client, e.g. to share its connection pool with a cachebus.Bus
+func (c *RedisCache) Client() redis.UniversalClient {
+	return c.c
+}
+
+// Stats -
+// Returns a snapshot of the hit, miss, put, delete and eviction counters recorded by this client
+func (c *RedisCache) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// evict -
+// Removes the given keys through a single pipeline round trip with UNLINK, or DEL when disabled, and returns how many
+// existed. When eviction callbacks are registered the values are fetched and removed atomically
+// with GETDEL instead and passed to the callbacks, expired marks keys removed for being stale. The
+// internal indexes and keys holding no string are deleted without reaching the callbacks
+func (c *RedisCache) evict(ctx context.Context, keys []string, expired bool) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	pipe := c.c.Pipeline()
+	if c.onEvicted == nil && c.onExpired == nil {
+		cmds := make([]*redis.IntCmd, len(keys))
+		for i, key := range keys {
+			cmds[i] = c.del(ctx, pipe, key)
+		}
+		c.forgetAccess(ctx, pipe, keys...)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return 0, err
+		}
+		var n int64
+		for _, cmd := range cmds {
+			n += cmd.Val()
+		}
+		return n, nil
+	}
+
+	internal := c.prefix + "go-cache:"
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		if strings.HasPrefix(key, internal) {
+			c.del(ctx, pipe, key) // the indexes and sidecars are no strings, GETDEL refuses them
+			continue
+		}
+		cmds[i] = pipe.GetDel(ctx, key)
+	}
+	c.forgetAccess(ctx, pipe, keys...)
+	res, err := pipe.Exec(ctx)
+	if err != nil && len(res) == 0 {
+		return 0, err
+	}
+	var wrongType []string
+	for _, cmd := range res {
+		switch err := cmd.Err(); {
+		case err == nil || err == redis.Nil:
+		case redis.HasErrorPrefix(err, "WRONGTYPE"):
+			// a key of another type written next to the cache, deleted without its value
+			if get, ok := cmd.(*redis.StringCmd); ok {
+				wrongType = append(wrongType, get.Args()[1].(string))
+			}
+		default:
+			return 0, err
+		}
+	}
+	if len(wrongType) > 0 {
+		_, err := c.c.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range wrongType {
+				c.del(ctx, pipe, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	var n int64
+	for i, cmd := range cmds {
+		if cmd == nil {
+			continue
+		}
+		val, err := cmd.Bytes()
+		if err != nil {
+			continue
+		}
+		n++
+		key := strings.TrimPrefix(keys[i], c.prefix)
+		if expired && c.onExpired != nil {
+			c.onExpired(key, val)
+		}
+		if c.onEvicted != nil {
+			c.onEvicted(key, val)
+		}
+	}
+	return n, nil
+}
+
+// owned -
+// Drops the keys held under the internal "go-cache:" prefix by other components, such as the locks of
+// the lock package, so flushing the cache never releases them. The indexes of the cache are kept
+func (c *RedisCache) owned(keys []string) []string {
+	internal := c.prefix + "go-cache:"
+	kept := keys[:0]
+	for _, key := range keys {
+		if strings.HasPrefix(key, internal) &&
+			!strings.HasPrefix(key, c.prefix+tagKeyPrefix) &&
+			!strings.HasPrefix(key, c.prefix+accessKeyPrefix) &&
+			!strings.HasPrefix(key, c.prefix+pinnedKeyPrefix) &&
+			!strings.HasPrefix(key, c.prefix+versionKeyPrefix) {
+			continue
+		}
+		kept = append(kept, key)
+	}
+	return kept
+}
+
+// del -
+// Deletes keys through cmd using UNLINK, or DEL when disabled
+func (c *RedisCache) del(ctx context.Context, cmd redis.Cmdable, keys ...string) *redis.IntCmd {
+	if c.unlink {
+		return cmd.Unlink(ctx, keys...)
+	}
+	return cmd.Del(ctx, keys...)
+}
+
+// escapePattern -
+// Escapes the glob special characters of s so it can be used literally inside a SCAN MATCH pattern
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
@@ -0,0 +1,188 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// invalidationChannel is the channel redis publishes client tracking invalidations on for RESP2 clients
+const invalidationChannel = "__redis__:invalidate"
+
+// nearCache keeps recently read values in process and drops them as soon as redis broadcasts
+// an invalidation for their key, see NearCache
+type nearCache struct {
+	mutex sync.Mutex
+	items map[string]nearItem
+	max   int
+	epoch uint64 // bumped on every invalidation, guards against storing values read before one
+
+	sub    *redis.Client
+	pubsub *redis.PubSub
+}
+
+type nearItem struct {
+	value   []byte
+	expires time.Time // zero when the entry does not expire
+}
+
+// NearCache -
+// Functional option enabling a server-assisted near cache holding up to maxEntries values in process.
+// Redis 6 client tracking broadcasts an invalidation for every modified or expired key, which drops the
+// local copy, so reads of hot keys are served from memory without manual invalidation logic.
+// Only standalone and sentinel clients are supported, on a cluster or ring the option is ignored
+func NearCache(maxEntries int) Option {
+	return func(rc *RedisCache) {
+		rc.nearSize = maxEntries
+	}
+}
+
+// startNearCache -
+// Opens a dedicated RESP2 connection which enables broadcast tracking, redirected to itself,
+// and subscribes to the invalidation channel. Tracking is re-enabled and the local copies
+// dropped whenever the connection is re-established, as invalidations may have been missed
+func (c *RedisCache) startNearCache() {
+	client, ok := c.c.(*redis.Client)
+	if c.nearSize <= 0 || !ok {
+		if c.nearSize > 0 {
+			c.logger.Error("redis near cache requires a standalone or sentinel client, near cache disabled")
+		}
+		return
+	}
+	n := &nearCache{
+		items: map[string]nearItem{},
+		max:   c.nearSize,
+	}
+	opt := *client.Options()
+	opt.Protocol = 2
+	opt.PoolSize = 1
+	opt.MinIdleConns = 0
+	opt.OnConnect = func(ctx context.Context, cn *redis.Conn) error {
+		id, err := cn.ClientID(ctx).Result()
+		if err != nil {
+			return err
+		}
+		args := []any{"CLIENT", "TRACKING", "ON", "REDIRECT", id, "BCAST"}
+		if c.prefix != "" {
+			args = append(args, "PREFIX", c.prefix)
+		}
+		if err := cn.Process(ctx, redis.NewStatusCmd(ctx, args...)); err != nil {
+			return err
+		}
+		n.clear()
+		return nil
+	}
+	n.sub = redis.NewClient(&opt)
+	n.pubsub = n.sub.Subscribe(context.Background(), invalidationChannel)
+	c.near = n
+	go c.listen(n)
+}
+
+// listen -
+// Drops the local copies of every key invalidated by redis until the subscription is closed.
+// Failed receives are retried with a capped exponential backoff, so an unreachable server
+// does not turn the loop into a busy spin
+func (c *RedisCache) listen(n *nearCache) {
+	ctx := context.Background()
+	var backoff time.Duration
+	for {
+		msg, err := n.pubsub.ReceiveMessage(ctx)
+		if errors.Is(err, redis.ErrClosed) {
+			return
+		}
+		if err != nil {
+			// a flush of the whole database is broadcast without keys, and a broken
+			// connection may have missed invalidations, so drop every local copy
+			c.logger.Debug("redis near cache invalidation receive failed", "error", err)
+			n.clear()
+			backoff = nextBackoff(backoff)
+			time.Sleep(backoff)
+			continue
+		}
+		backoff = 0
+		if msg.Payload == "" && len(msg.PayloadSlice) == 0 {
+			n.clear()
+			continue
+		}
+		keys := msg.PayloadSlice
+		if msg.Payload != "" {
+			keys = append(keys, msg.Payload)
+		}
+		n.invalidate(keys...)
+	}
+}
+
+// nextBackoff -
+// Returns the delay before the next receive after a failure, doubling from 10ms up to 5s
+func nextBackoff(backoff time.Duration) time.Duration {
+	if backoff <= 0 {
+		return time.Millisecond * 10
+	}
+	if backoff *= 2; backoff > time.Second*5 {
+		return time.Second * 5
+	}
+	return backoff
+}
+
+// get -
+// Returns the local copy of key, if any, along with the epoch to pass to set after reading from redis
+func (n *nearCache) get(key string, now time.Time) ([]byte, uint64, bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	item, ok := n.items[key]
+	if ok && !item.expires.IsZero() && now.After(item.expires) {
+		delete(n.items, key)
+		ok = false
+	}
+	return item.value, n.epoch, ok
+}
+
+// set -
+// Stores a local copy of key unless an invalidation arrived since epoch was read,
+// evicting an arbitrary entry when the near cache is full
+func (n *nearCache) set(key string, value []byte, expires time.Time, epoch uint64) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.epoch != epoch {
+		return
+	}
+	if _, ok := n.items[key]; !ok && len(n.items) >= n.max {
+		for k := range n.items {
+			delete(n.items, k)
+			break
+		}
+	}
+	n.items[key] = nearItem{value: value, expires: expires}
+}
+
+// invalidate -
+// Drops the local copies of keys
+func (n *nearCache) invalidate(keys ...string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.epoch++
+	for _, key := range keys {
+		delete(n.items, key)
+	}
+}
+
+// clear -
+// Drops every local copy
+func (n *nearCache) clear() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.epoch++
+	n.items = map[string]nearItem{}
+}
+
+// close -
+// Closes the invalidation subscription and its connection
+func (n *nearCache) close() error {
+	if err := n.pubsub.Close(); err != nil {
+		return err
+	}
+	return n.sub.Close()
+}
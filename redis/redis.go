@@ -2,21 +2,105 @@ package redis
 
 import (
 	"context"
-	"log"
+	"crypto/tls"
+	"crypto/x509"
+	"expvar"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/pedreviljoen/go-cache"
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
 // RedisCache represents a redis cache adapter implementation.
 type RedisCache struct {
-	c      *redis.Client
-	window time.Duration
+	c       redis.UniversalClient
+	window  time.Duration
+	prefix  string // namespace of every key, see KeyPrefix
+	unlink  bool   // delete with UNLINK rather than DEL
+	sliding bool   // Get restarts the TTL of the key it reads
+
+	accessRate float64 // fraction of hits recorded into the access sidecar of their key, see TrackAccess
+
+	ttlJitter float64 // fraction by which the TTL of every written key is randomised
+
+	maxValueSize  int  // largest value accepted by writes, unlimited when zero
+	skipOversized bool // oversized writes are dropped rather than rejected
+
+	batchSize int // keys requested per SCAN call and handled per pipeline
+
+	tlsConfig *tls.Config // used by the clients built by the constructors
+	pool      poolOptions // used by the clients built by the constructors
+	tlsErr    error       // failure to apply the TLS options, refuses every connection
+
+	nearSize int        // maximum entries of the near cache, disabled when zero
+	near     *nearCache // nil unless the near cache is enabled
+
+	loads singleflight.Group // deduplicates concurrent GetOrLoad misses
+
+	getTimeout    time.Duration // deadline of reads, none when zero
+	putTimeout    time.Duration // deadline of writes, none when zero
+	deleteTimeout time.Duration // deadline of deletes and invalidations, none when zero
+	flushTimeout  time.Duration // deadline of Flush and FlushStale, none when zero
+
+	retry cache.RetryPolicy // retries of single key operations, none by default
+
+	cleanInterval time.Duration // how often the cleaner runs, defaults to the window
+	cleanJitter   float64       // fraction by which each cleaner interval is randomised
+
+	stats  cache.Counters
+	logger cache.Logger
+
+	onEvicted func(key string, value []byte) // called for every item removed by this client
+	onExpired func(key string, value []byte) // called for items removed by FlushStale
+
+	lastClean      atomic.Int64 // unix nano timestamp of the last cleaner run
+	onCleanerError func(error)  // called when a cleaner run fails
+
+	lifecycle sync.Mutex // guards cleaner and closed
+	cleaner   *cleaner
+	closed    bool
 }
 
+// poolOptions tune the connection pool of the clients built by the constructors, zero values keep the go-redis defaults
+type poolOptions struct {
+	db          int
+	size        int
+	minIdle     int
+	maxIdleTime time.Duration
+}
+
+// defaultBatchSize is the default number of keys requested per SCAN call and handled per pipeline
+const defaultBatchSize = 100
+
+// tagKeyPrefix prefixes the SET index which tracks the keys attached to a tag
+const tagKeyPrefix = "go-cache:tag:"
+
+// accessKeyPrefix prefixes the HASH sidecar holding the access metadata of a key, see TrackAccess
+const accessKeyPrefix = "go-cache:access:"
+
+// defaultAccessTTL is how long an access sidecar outlives its last update without a time window
+const defaultAccessTTL = time.Hour * 24
+
+// pinnedKeyPrefix prefixes the marker of a key pinned through Persist, which FlushStale must keep.
+// Markers are only read for keys without expiry, so writes never touch them
+const pinnedKeyPrefix = "go-cache:pinned:"
+
+// versionKeyPrefix prefixes the counter of the writes made through PutIfVersion to a key, which
+// lives for the cache window or defaultAccessTTL without one
+const versionKeyPrefix = "go-cache:version:"
+
 type cleaner struct {
 	Interval time.Duration
-	stop     chan bool
+	Jitter   float64
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
 }
 
 type Option func(*RedisCache)
@@ -27,28 +111,113 @@ func New(address, username, password string, opts ...Option) *RedisCache {
 	if address == "" {
 		address = "localhost:6379"
 	}
-	rdc := redis.NewClient(&redis.Options{
-		Addr:         address,
-		Username:     username,
-		Password:     password,
-		ReadTimeout:  time.Second * 10, // 10 second default read timeout
-		WriteTimeout: time.Second * 10, // 10 second default write timeout
-		OnConnect: func(ctx context.Context, cn *redis.Conn) error {
-			log.Printf("redis connected")
-			return nil
-		},
-	})
-	redis := &RedisCache{
-		c: rdc,
+	rc := newCache().apply(opts)
+	if rc.c == nil {
+		rc.c = redis.NewClient(&redis.Options{
+			Addr:            address,
+			Username:        username,
+			Password:        password,
+			ReadTimeout:     time.Second * 10, // 10 second default read timeout
+			WriteTimeout:    time.Second * 10, // 10 second default write timeout
+			TLSConfig:       rc.tlsConfig,
+			OnConnect:       rc.onConnect(address),
+			DB:              rc.pool.db,
+			PoolSize:        rc.pool.size,
+			MinIdleConns:    rc.pool.minIdle,
+			ConnMaxIdleTime: rc.pool.maxIdleTime,
+		})
+	}
+	return rc.start()
+}
+
+// NewCluster -
+// Initialises a new Redis Cluster client with a set of default options and the addresses of one or
+// more cluster nodes, Flush and FlushStale scan every master node of the cluster
+func NewCluster(addrs []string, username, password string, opts ...Option) *RedisCache {
+	rc := newCache().apply(opts)
+	if rc.c == nil {
+		rc.c = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:           addrs,
+			Username:        username,
+			Password:        password,
+			ReadTimeout:     time.Second * 10, // 10 second default read timeout
+			WriteTimeout:    time.Second * 10, // 10 second default write timeout
+			TLSConfig:       rc.tlsConfig,
+			OnConnect:       rc.onConnect(strings.Join(addrs, ",")),
+			PoolSize:        rc.pool.size,
+			MinIdleConns:    rc.pool.minIdle,
+			ConnMaxIdleTime: rc.pool.maxIdleTime,
+		})
+	}
+	return rc.start()
+}
+
+// NewFailover -
+// Initialises a new Redis client with a set of default options which discovers the master named masterName
+// through the passed sentinel addresses, and follows it across failovers without restarting the application
+func NewFailover(masterName string, sentinels []string, username, password string, opts ...Option) *RedisCache {
+	rc := newCache().apply(opts)
+	if rc.c == nil {
+		rc.c = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:      masterName,
+			SentinelAddrs:   sentinels,
+			Username:        username,
+			Password:        password,
+			ReadTimeout:     time.Second * 10, // 10 second default read timeout
+			WriteTimeout:    time.Second * 10, // 10 second default write timeout
+			TLSConfig:       rc.tlsConfig,
+			OnConnect:       rc.onConnect(masterName),
+			DB:              rc.pool.db,
+			PoolSize:        rc.pool.size,
+			MinIdleConns:    rc.pool.minIdle,
+			ConnMaxIdleTime: rc.pool.maxIdleTime,
+		})
 	}
+	return rc.start()
+}
+
+// newCache -
+// Returns a RedisCache holding the default settings, without a client
+func newCache() *RedisCache {
+	return &RedisCache{
+		logger:    cache.NopLogger{},
+		unlink:    true,
+		batchSize: defaultBatchSize,
+	}
+}
+
+// start -
+// Starts the background processes requested by the options once the client is built
+func (rc *RedisCache) start() *RedisCache {
+	rc.startNearCache()
+	return rc
+}
+
+// apply -
+// Applies every functional option to the cache
+func (rc *RedisCache) apply(opts []Option) *RedisCache {
 	for _, opt := range opts {
-		opt(redis)
+		opt(rc)
+	}
+	return rc
+}
+
+// onConnect -
+// Returns the connection hook logging new connections to addr, connections are refused
+// when the TLS options could not be applied
+func (rc *RedisCache) onConnect(addr string) func(context.Context, *redis.Conn) error {
+	return func(ctx context.Context, cn *redis.Conn) error {
+		if rc.tlsErr != nil {
+			return rc.tlsErr
+		}
+		rc.logger.Info("redis connected", "addr", addr)
+		return nil
 	}
-	return redis
 }
 
 // ClientWithCustomOptions -
-// Initialises a new Redis client with provided Options
+// Initialises a new Redis client with provided Options. The TLS options do not apply to this client,
+// set clientOpts.TLSConfig instead
 func ClientWithCustomOptions(clientOpts *redis.Options) Option {
 	client := redis.NewClient(clientOpts)
 	return func(rc *RedisCache) {
@@ -56,6 +225,17 @@ func ClientWithCustomOptions(clientOpts *redis.Options) Option {
 	}
 }
 
+// ClientWithRingOptions -
+// Initialises a new Redis Ring client, sharding keys client-side across several standalone
+// redis instances, with provided RingOptions. Flush and FlushStale scan every shard. The TLS options
+// do not apply to this client, set ringOpts.TLSConfig instead
+func ClientWithRingOptions(ringOpts *redis.RingOptions) Option {
+	client := redis.NewRing(ringOpts)
+	return func(rc *RedisCache) {
+		rc.c = client
+	}
+}
+
 // Window -
 // Functional option to specify the time window of the cache
 func Window(t time.Duration) Option {
@@ -63,3 +243,263 @@ func Window(t time.Duration) Option {
 		rc.window = t
 	}
 }
+
+// Expvar -
+// Functional option which publishes the cache statistics (entries, hits, misses, last cleaner run)
+// through expvar under name, publishing the same name twice panics
+func Expvar(name string) Option {
+	return func(rc *RedisCache) {
+		expvar.Publish(name, expvar.Func(func() any {
+			s := rc.stats.Snapshot()
+			return map[string]any{
+				"entries":          rc.dbSize(),
+				"hits":             s.Hits,
+				"misses":           s.Misses,
+				"puts":             s.Puts,
+				"deletes":          s.Deletes,
+				"evictions":        s.Evictions,
+				"last_cleaner_run": rc.lastCleanerRun(),
+			}
+		}))
+	}
+}
+
+// Logger -
+// Functional option to specify the logger receiving connection, cleaner and eviction diagnostics, defaults to a no-op logger
+func Logger(l cache.Logger) Option {
+	return func(rc *RedisCache) {
+		rc.logger = l
+	}
+}
+
+// OnEvicted -
+// Functional option registering a callback invoked for every item removed by this client, whether by
+// Delete, an invalidation, Flush or FlushStale. Values are fetched with GETDEL which requires redis 6.2,
+// keys expired by redis itself do not invoke the callback
+func OnEvicted(fn func(key string, value []byte)) Option {
+	return func(rc *RedisCache) {
+		rc.onEvicted = fn
+	}
+}
+
+// OnExpired -
+// Functional option registering a callback invoked for every stale item removed by FlushStale
+func OnExpired(fn func(key string, value []byte)) Option {
+	return func(rc *RedisCache) {
+		rc.onExpired = fn
+	}
+}
+
+// CleanInterval -
+// Functional option to specify how often the cleaner flushes stale items, independently of the
+// time window, defaults to the time window
+func CleanInterval(d time.Duration) Option {
+	return func(rc *RedisCache) {
+		rc.cleanInterval = d
+	}
+}
+
+// OnCleanerError -
+// Functional option registering a callback invoked whenever the cleaner fails to flush stale items,
+// e.g. due to a loss of connectivity to redis
+func OnCleanerError(fn func(error)) Option {
+	return func(rc *RedisCache) {
+		rc.onCleanerError = fn
+	}
+}
+
+// CleanJitter -
+// Functional option randomising every cleaner interval by ±fraction (e.g. 0.1 for ±10%), so that
+// many instances sharing one redis do not sweep in lockstep
+func CleanJitter(fraction float64) Option {
+	return func(rc *RedisCache) {
+		rc.cleanJitter = fraction
+	}
+}
+
+// KeyPrefix -
+// Functional option namespacing every key with p, Flush and FlushStale only touch keys
+// starting with p so that several caches can safely share one redis database
+func KeyPrefix(p string) Option {
+	return func(rc *RedisCache) {
+		rc.prefix = p
+	}
+}
+
+// Unlink -
+// Functional option to delete keys with UNLINK, reclaiming memory in a background thread server-side,
+// rather than the blocking DEL. Enabled by default, disable it for redis versions older than 4
+func Unlink(enabled bool) Option {
+	return func(rc *RedisCache) {
+		rc.unlink = enabled
+	}
+}
+
+// TTLJitter -
+// Functional option randomising the TTL of every written key by ±fraction of the window (e.g. 0.1 for ±10%),
+// so entries cached together, for instance at startup, do not all expire in the same second
+func TTLJitter(fraction float64) Option {
+	return func(rc *RedisCache) {
+		rc.ttlJitter = fraction
+	}
+}
+
+// Sliding -
+// Functional option making Get restart the TTL of every key it reads, so items expire after being idle
+// for the window rather than a fixed time after they were saved. Get bypasses the near cache while
+// enabled, as local hits would not reach redis to extend the TTL. Keys without a TTL are left as is
+func Sliding(enabled bool) Option {
+	return func(rc *RedisCache) {
+		rc.sliding = enabled
+	}
+}
+
+// TrackAccess -
+// Functional option recording a sample of the reads which hit redis into a HASH sidecar of their key,
+// holding an estimated hit count and the last sampled read, as reported by Inspect. Every sampled read
+// costs one extra round trip, so keep sampleRate low (e.g. 0.01 for 1%) on busy caches. Near cache hits
+// are not recorded. A sidecar is deleted along with its key, and otherwise expires a window after its
+// last update, or a day after it without a window
+func TrackAccess(sampleRate float64) Option {
+	return func(rc *RedisCache) {
+		rc.accessRate = sampleRate
+	}
+}
+
+// MaxValueSize -
+// Functional option refusing writes of values larger than n bytes with cache.ErrValueTooLarge
+// before they reach redis, so a single runaway payload cannot blow up the server, see SkipOversized
+func MaxValueSize(n int) Option {
+	return func(rc *RedisCache) {
+		rc.maxValueSize = n
+	}
+}
+
+// SkipOversized -
+// Functional option making writes of values larger than MaxValueSize silently succeed without
+// saving anything, rather than failing with cache.ErrValueTooLarge
+func SkipOversized(enabled bool) Option {
+	return func(rc *RedisCache) {
+		rc.skipOversized = enabled
+	}
+}
+
+// BatchSize -
+// Functional option to specify how many keys are requested per SCAN call and checked or deleted per
+// pipeline round trip by Flush, FlushStale and the bulk deletes, defaults to 100
+func BatchSize(n int) Option {
+	return func(rc *RedisCache) {
+		if n > 0 {
+			rc.batchSize = n
+		}
+	}
+}
+
+// WithTLS -
+// Functional option to connect over TLS with the given configuration. The configuration is copied,
+// so WithCAFile and WithInsecureSkipVerify never modify one shared with other clients
+func WithTLS(cfg *tls.Config) Option {
+	return func(rc *RedisCache) {
+		rc.tlsConfig = cfg.Clone()
+	}
+}
+
+// WithCAFile -
+// Functional option to connect over TLS, verifying the server certificate against the PEM encoded
+// certificate authorities inside path. Connections are refused when the file can not be loaded
+func WithCAFile(path string) Option {
+	return func(rc *RedisCache) {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			rc.tlsErr = fmt.Errorf("unable to read redis CA file: %w", err)
+			return
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			rc.tlsErr = fmt.Errorf("no certificates found inside redis CA file %s", path)
+			return
+		}
+		rc.tls().RootCAs = pool
+	}
+}
+
+// WithInsecureSkipVerify -
+// Functional option to connect over TLS without verifying the server certificate, only use it for development
+func WithInsecureSkipVerify() Option {
+	return func(rc *RedisCache) {
+		rc.tls().InsecureSkipVerify = true
+	}
+}
+
+// tls -
+// Returns the TLS configuration of the cache, initialising it when TLS was not configured yet
+func (rc *RedisCache) tls() *tls.Config {
+	if rc.tlsConfig == nil {
+		rc.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return rc.tlsConfig
+}
+
+// GetTimeout -
+// Functional option to specify the deadline of Get and IsWarm, on top of the client read and write timeouts
+func GetTimeout(d time.Duration) Option {
+	return func(rc *RedisCache) {
+		rc.getTimeout = d
+	}
+}
+
+// PutTimeout -
+// Functional option to specify the deadline of Put, PutTagged and GetOrSet, on top of the client read and write timeouts
+func PutTimeout(d time.Duration) Option {
+	return func(rc *RedisCache) {
+		rc.putTimeout = d
+	}
+}
+
+// DeleteTimeout -
+// Functional option to specify the deadline of Delete and the bulk deletes and invalidations, on top of the client read and write timeouts
+func DeleteTimeout(d time.Duration) Option {
+	return func(rc *RedisCache) {
+		rc.deleteTimeout = d
+	}
+}
+
+// FlushTimeout -
+// Functional option to specify the deadline of Flush and FlushStale, on top of the client read and write timeouts
+func FlushTimeout(d time.Duration) Option {
+	return func(rc *RedisCache) {
+		rc.flushTimeout = d
+	}
+}
+
+// PoolSize -
+// Functional option to specify the maximum number of socket connections, per node on a cluster
+func PoolSize(n int) Option {
+	return func(rc *RedisCache) {
+		rc.pool.size = n
+	}
+}
+
+// MinIdleConns -
+// Functional option to specify the minimum number of idle connections kept open
+func MinIdleConns(n int) Option {
+	return func(rc *RedisCache) {
+		rc.pool.minIdle = n
+	}
+}
+
+// ConnMaxIdleTime -
+// Functional option to specify how long a connection may stay idle before it is closed
+func ConnMaxIdleTime(d time.Duration) Option {
+	return func(rc *RedisCache) {
+		rc.pool.maxIdleTime = d
+	}
+}
+
+// DB -
+// Functional option to select the redis database, ignored by cluster clients which only support database 0
+func DB(n int) Option {
+	return func(rc *RedisCache) {
+		rc.pool.db = n
+	}
+}
@@ -0,0 +1,85 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/pedreviljoen/go-cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// transientPrefixes are the redis error replies signalling a temporary condition
+var transientPrefixes = []string{"LOADING ", "MOVED ", "ASK ", "TRYAGAIN ", "CLUSTERDOWN ", "MASTERDOWN ", "READONLY "}
+
+// Retry -
+// Functional option retrying single key operations (Get, Put, PutTagged, Delete, IsWarm and GetOrSet)
+// which fail with a transient error according to policy. When the policy does not classify errors
+// itself, IsTransient is used. Operations whose repetition changes their outcome (Add, Swap, Pop,
+// Append, Increment and PutIfVersion) are only retried when redis surely did not run them: the
+// connection could not be established or the server refused the command
+func Retry(policy cache.RetryPolicy) Option {
+	return func(rc *RedisCache) {
+		if policy.Retryable == nil {
+			policy.Retryable = IsTransient
+		}
+		rc.retry = policy
+	}
+}
+
+// IsTransient -
+// Reports whether err is a connection failure, a timeout or one of the redis replies signalling
+// a temporary condition such as LOADING, MOVED or TRYAGAIN. A missing key is not transient
+func IsTransient(err error) bool {
+	if err == nil || err == redis.Nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	msg := err.Error()
+	for _, prefix := range transientPrefixes {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// do -
+// Runs fn under the configured retry policy
+func (c *RedisCache) do(ctx context.Context, fn func() error) error {
+	return c.retry.Do(ctx, fn)
+}
+
+// doOnce -
+// Runs fn under the configured retry policy for commands which must not run twice, such as SETNX,
+// GETDEL or INCRBY. Only the failures guaranteeing the command was not applied are retried
+func (c *RedisCache) doOnce(ctx context.Context, fn func() error) error {
+	policy := c.retry
+	retryable := policy.Retryable
+	policy.Retryable = func(err error) bool {
+		return notApplied(err) && (retryable == nil || retryable(err))
+	}
+	return policy.Do(ctx, fn)
+}
+
+// notApplied -
+// Reports whether err guarantees the command never ran: redis could not be dialled, or it answered
+// with one of the replies refusing a command during a temporary condition
+func notApplied(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	msg := err.Error()
+	for _, prefix := range transientPrefixes {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
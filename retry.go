@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes how often and how fast failed operations are retried.
+type RetryPolicy struct {
+	MaxAttempts    int                  // total attempts including the first one, 1 or less disables retries
+	InitialBackoff time.Duration        // delay before the first retry, defaults to 10ms
+	MaxBackoff     time.Duration        // upper bound of the delay between attempts, defaults to 1s
+	Multiplier     float64              // growth factor of the delay, defaults to 2
+	Retryable      func(err error) bool // reports whether err is transient, every error is retried when nil
+}
+
+// Do -
+// Calls fn until it succeeds, returns a non-retryable error, the attempts are exhausted or ctx
+// is done. Delays grow exponentially with full jitter. The last error of fn is returned
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	backoff := p.InitialBackoff
+	if backoff <= 0 {
+		backoff = time.Millisecond * 10
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = time.Second
+	}
+	multiplier := p.Multiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt >= p.MaxAttempts || (p.Retryable != nil && !p.Retryable(err)) {
+			return err
+		}
+		timer := time.NewTimer(time.Duration(rand.Int63n(int64(backoff)) + 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff = time.Duration(float64(backoff) * multiplier)
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
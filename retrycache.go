@@ -0,0 +1,68 @@
+package cache
+
+import "context"
+
+// retrying retries the operations of the wrapped cache under a RetryPolicy.
+type retrying struct {
+	Wrapper
+	policy RetryPolicy
+}
+
+// WithRetry -
+// Wraps c so that failed operations are retried under policy, on any backend. As a failed Get or
+// Delete cannot be told apart from a missing key, those are only retried when policy.Retryable is set,
+// for instance to redis.IsTransient, while Put, Flush and FlushStale are retried on every error it allows
+func WithRetry(c Cache, policy RetryPolicy) Cache {
+	return &retrying{
+		Wrapper: Wrapper{Cache: c},
+		policy:  policy,
+	}
+}
+
+// lookups -
+// Returns the policy applied to Get and Delete
+func (r *retrying) lookups() RetryPolicy {
+	if r.policy.Retryable == nil {
+		return RetryPolicy{}
+	}
+	return r.policy
+}
+
+// Put -
+// Saves the value, retrying failures
+func (r *retrying) Put(key string, val []byte) error {
+	return r.policy.Do(context.Background(), func() error {
+		return r.Cache.Put(key, val)
+	})
+}
+
+// Get -
+// Fetches the value, retrying failures the policy reports as retryable
+func (r *retrying) Get(key string) ([]byte, error) {
+	var val []byte
+	err := r.lookups().Do(context.Background(), func() (err error) {
+		val, err = r.Cache.Get(key)
+		return err
+	})
+	return val, err
+}
+
+// Delete -
+// Deletes the value, retrying failures the policy reports as retryable
+func (r *retrying) Delete(key string) error {
+	return r.lookups().Do(context.Background(), func() error {
+		return r.Cache.Delete(key)
+	})
+}
+
+// Flush -
+// Empties the cache, retrying failures
+func (r *retrying) Flush() error {
+	return r.policy.Do(context.Background(), r.Cache.Flush)
+}
+
+// FlushStale -
+// Flushes the stale items, retrying failures
+func (r *retrying) FlushStale() error {
+	return r.policy.Do(context.Background(), r.Cache.FlushStale)
+}
@@ -0,0 +1,89 @@
+package sessionstore
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+// sessionKey is the context key of the session loaded by the Manager
+type sessionKey struct{}
+
+// Manager loads the session of every request into its context and saves it back before the
+// response is written, so handlers need neither Get nor Save. As every request saves its session,
+// sessions expire after being idle for MaxAge rather than MaxAge after they started
+type Manager struct {
+	store *Store
+	name  string
+}
+
+// NewManager -
+// Initialises a new Manager of the sessions named name kept in store
+func NewManager(store *Store, name string) *Manager {
+	return &Manager{
+		store: store,
+		name:  name,
+	}
+}
+
+// Middleware -
+// Returns a handler loading the session before calling next, see FromContext, and saving it when
+// next writes its response. New sessions left empty are not saved, so anonymous requests do not
+// create sessions
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, err := m.store.Get(r, m.name)
+		if err != nil {
+			m.store.logger.Error("unable to load session, starting a new one", "name", m.name, "error", err)
+		}
+		r = r.WithContext(context.WithValue(r.Context(), sessionKey{}, session))
+		sw := &saver{ResponseWriter: w, save: func() {
+			if session.IsNew && len(session.Values) == 0 {
+				return
+			}
+			if err := m.store.Save(r, w, session); err != nil {
+				m.store.logger.Error("unable to save session", "name", m.name, "error", err)
+			}
+		}}
+		next.ServeHTTP(sw, r)
+		sw.commit()
+	})
+}
+
+// FromContext -
+// Returns the session loaded by the Manager middleware, nil outside of it
+func FromContext(ctx context.Context) *sessions.Session {
+	session, _ := ctx.Value(sessionKey{}).(*sessions.Session)
+	return session
+}
+
+// saver saves the session right before the response headers are written.
+type saver struct {
+	http.ResponseWriter
+	save  func()
+	saved bool
+}
+
+// commit -
+// Saves the session once
+func (sw *saver) commit() {
+	if !sw.saved {
+		sw.saved = true
+		sw.save()
+	}
+}
+
+// WriteHeader -
+// Saves the session, then writes the status
+func (sw *saver) WriteHeader(status int) {
+	sw.commit()
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+// Write -
+// Saves the session, then writes the body
+func (sw *saver) Write(p []byte) (int, error) {
+	sw.commit()
+	return sw.ResponseWriter.Write(p)
+}
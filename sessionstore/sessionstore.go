@@ -0,0 +1,192 @@
+// Package sessionstore keeps HTTP sessions in any cache.Cache, implementing the gorilla/sessions
+// Store interface. The session cookie only holds the signed session ID, the values live in the cache.
+package sessionstore
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/pedreviljoen/go-cache"
+)
+
+const defaultMaxAge = 86400 * 30
+
+// toucher is implemented by caches able to set the lifetime of a single item
+type toucher interface {
+	Touch(key string, ttl time.Duration) error
+}
+
+// Store is a gorilla/sessions Store saving session values in a cache under a random session ID.
+// Saving a session restarts its lifetime, so sessions expire after being idle for MaxAge
+type Store struct {
+	c          cache.Cache
+	codecs     []securecookie.Codec
+	options    *sessions.Options
+	prefix     string
+	serializer securecookie.GobEncoder
+	logger     cache.Logger
+}
+
+type Option func(*Store)
+
+// New -
+// Initialises a new Store on top of c. The key pairs sign and optionally encrypt the session ID
+// cookie, as with sessions.NewCookieStore, the first pair being used to encode and every pair to
+// decode so keys can be rotated. Cookies are HttpOnly, Secure and SameSite=Lax by default
+func New(c cache.Cache, keyPairs [][]byte, opts ...Option) *Store {
+	s := &Store{
+		c:      c,
+		codecs: securecookie.CodecsFromPairs(keyPairs...),
+		options: &sessions.Options{
+			Path:     "/",
+			MaxAge:   defaultMaxAge,
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		prefix: "session",
+		logger: cache.NopLogger{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.MaxAge(s.options.MaxAge)
+	return s
+}
+
+// Options -
+// Functional option overriding the cookie options of new sessions
+func Options(o sessions.Options) Option {
+	return func(s *Store) {
+		s.options = &o
+	}
+}
+
+// KeyPrefix -
+// Functional option to specify the prefix of the cache keys of sessions, defaults to "session"
+func KeyPrefix(p string) Option {
+	return func(s *Store) {
+		s.prefix = p
+	}
+}
+
+// Logger -
+// Functional option to log the failures of the Manager to load or save sessions
+func Logger(l cache.Logger) Option {
+	return func(s *Store) {
+		s.logger = l
+	}
+}
+
+// MaxAge -
+// Sets the lifetime of new sessions in seconds, for the cookie and the codecs alike
+func (s *Store) MaxAge(age int) {
+	s.options.MaxAge = age
+	for _, codec := range s.codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(age)
+		}
+	}
+}
+
+// Get -
+// Returns the session registered for name on the request, loading it from the cache once
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New -
+// Returns the session name of the request, loading its values from the cache when the request
+// carries a valid session cookie, or a new session otherwise. An invalid cookie is reported
+// along with the new session
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.options
+	session.Options = &opts
+	session.IsNew = true
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	if err := securecookie.DecodeMulti(name, cookie.Value, &session.ID, s.codecs...); err != nil {
+		return session, err
+	}
+	data, err := s.c.Get(s.key(session.ID))
+	if err != nil {
+		// expired or evicted, the session starts over with a new ID
+		session.ID = ""
+		return session, nil
+	}
+	if err := s.serializer.Deserialize(data, &session.Values); err != nil {
+		session.ID = ""
+		return session, fmt.Errorf("unable to decode session: %w", err)
+	}
+	session.IsNew = false
+	return session, nil
+}
+
+// Save -
+// Saves the values of the session to the cache and writes the signed session ID cookie. A session
+// whose MaxAge is negative is deleted from the cache, if still present, and its cookie always cleared
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		// the expiring cookie is written even when the delete fails, so the browser drops the session
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		if session.ID == "" {
+			return nil
+		}
+		if err := s.c.Delete(s.key(session.ID)); err != nil && !errors.Is(err, cache.ErrKeyNotFound) {
+			return err
+		}
+		return nil
+	}
+	if session.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		session.ID = id
+	}
+	data, err := s.serializer.Serialize(session.Values)
+	if err != nil {
+		return fmt.Errorf("unable to encode session: %w", err)
+	}
+	key := s.key(session.ID)
+	if err := s.c.Put(key, data); err != nil {
+		return err
+	}
+	if t, ok := s.c.(toucher); ok && session.Options.MaxAge > 0 {
+		if err := t.Touch(key, time.Duration(session.Options.MaxAge)*time.Second); err != nil {
+			return err
+		}
+	}
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// key -
+// Returns the cache key of a session ID
+func (s *Store) key(id string) string {
+	return cache.Key(s.prefix, id)
+}
+
+// newID -
+// Returns a random session ID
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate session ID: %w", err)
+	}
+	return strings.TrimRight(base32.StdEncoding.EncodeToString(b), "="), nil
+}
@@ -0,0 +1,56 @@
+package cache
+
+import "sync/atomic"
+
+// Stats is a point in time snapshot of the operation counters of a cache.
+type Stats struct {
+	Hits         uint64 // Get calls which found a value
+	Misses       uint64 // Get calls which found no value
+	Puts         uint64 // values written
+	Deletes      uint64 // values explicitly deleted or invalidated
+	Evictions    uint64 // values removed by the cache itself, e.g. stale items
+	StaleFlushes uint64 // FlushStale runs
+}
+
+// KeyHits is the number of reads which found a key, as reported by caches tracking their hottest keys.
+type KeyHits struct {
+	Key  string
+	Hits uint64
+}
+
+// HitRatio returns the fraction of Get calls which found a value.
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// StatsReporter is implemented by caches which track operation statistics.
+type StatsReporter interface {
+	// Stats returns a snapshot of the operation counters.
+	Stats() Stats
+}
+
+// Counters holds the atomic operation counters backing Stats, it is safe for concurrent use.
+type Counters struct {
+	Hits         atomic.Uint64
+	Misses       atomic.Uint64
+	Puts         atomic.Uint64
+	Deletes      atomic.Uint64
+	Evictions    atomic.Uint64
+	StaleFlushes atomic.Uint64
+}
+
+// Snapshot returns the current value of every counter.
+func (c *Counters) Snapshot() Stats {
+	return Stats{
+		Hits:         c.Hits.Load(),
+		Misses:       c.Misses.Load(),
+		Puts:         c.Puts.Load(),
+		Deletes:      c.Deletes.Load(),
+		Evictions:    c.Evictions.Load(),
+		StaleFlushes: c.StaleFlushes.Load(),
+	}
+}
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Streamer is implemented by caches able to store and fetch values without holding them in memory
+// entirely.
+type Streamer interface {
+	PutReader(key string, r io.Reader) error
+	GetReader(key string) (io.ReadCloser, error)
+}
+
+// PutReader -
+// Saves the content of r under key. When c is a Streamer, such as the cache returned by
+// WithChunking, the content is streamed, otherwise it is read entirely before being saved
+func PutReader(c Cache, key string, r io.Reader) error {
+	if s, ok := c.(Streamer); ok {
+		return s.PutReader(key, r)
+	}
+	val, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("unable to read value of %s: %w", key, err)
+	}
+	return c.Put(key, val)
+}
+
+// GetReader -
+// Returns a reader over the value of key. When c is a Streamer the value is fetched lazily,
+// otherwise it is fetched entirely up front. The reader must be closed
+func GetReader(c Cache, key string) (io.ReadCloser, error) {
+	if s, ok := c.(Streamer); ok {
+		return s.GetReader(key)
+	}
+	val, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(val)), nil
+}
+
+// PutReader -
+// Saves the content of r as chunks, holding a single chunk in memory at a time, followed by their
+// manifest. The chunks already written are deleted when reading r fails
+func (k *chunking) PutReader(key string, r io.Reader) error {
+	if k.size <= 0 {
+		return PutReader(k.Cache, key, r)
+	}
+	old, _, chunked, _ := k.manifest(key)
+	m := manifest{gen: strconv.FormatInt(time.Now().UnixNano(), 36)}
+	for {
+		// a fresh chunk per iteration, caches may keep a reference to the values they are given
+		buf := make([]byte, k.size)
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if err := k.Cache.Put(chunkKey(key, m.gen, m.chunks), buf[:n]); err != nil {
+				k.deleteChunks(key, m)
+				return err
+			}
+			m.chunks++
+			m.length += n
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			k.deleteChunks(key, m)
+			return fmt.Errorf("unable to read value of %s: %w", key, err)
+		}
+	}
+	if err := k.putManifest(key, m); err != nil {
+		k.deleteChunks(key, m)
+		return err
+	}
+	if chunked {
+		k.deleteChunks(key, old)
+	}
+	return nil
+}
+
+// GetReader -
+// Returns a reader fetching the chunks of the value one at a time as it is read
+func (k *chunking) GetReader(key string) (io.ReadCloser, error) {
+	m, val, chunked, err := k.manifest(key)
+	if err != nil {
+		return nil, err
+	}
+	if !chunked {
+		return io.NopCloser(bytes.NewReader(val)), nil
+	}
+	return &chunkReader{k: k, key: key, m: m}, nil
+}
+
+// putManifest -
+// Saves the manifest of a chunked value under key
+func (k *chunking) putManifest(key string, m manifest) error {
+	data := []byte{chunkManifest}
+	data = binary.AppendUvarint(data, uint64(m.chunks))
+	data = binary.AppendUvarint(data, uint64(m.length))
+	data = append(data, m.gen...)
+	return k.Cache.Put(key, data)
+}
+
+// chunkReader reads a chunked value one chunk at a time.
+type chunkReader struct {
+	k      *chunking
+	key    string
+	m      manifest
+	next   int    // index of the next chunk to fetch
+	read   int    // bytes returned so far
+	buf    []byte // unread part of the current chunk
+	closed bool
+}
+
+// Read -
+// Returns the content of the current chunk, fetching the next one once it is consumed
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	if cr.closed {
+		return 0, errors.New("read from closed chunk reader")
+	}
+	for len(cr.buf) == 0 {
+		if cr.next >= cr.m.chunks {
+			if cr.read != cr.m.length {
+				return 0, fmt.Errorf("unable to retrieve %s: chunks hold %d bytes instead of %d", cr.key, cr.read, cr.m.length)
+			}
+			return 0, io.EOF
+		}
+		chunk, err := cr.k.Cache.Get(chunkKey(cr.key, cr.m.gen, cr.next))
+		if err != nil {
+			return 0, fmt.Errorf("unable to retrieve chunk %d of %s: %w", cr.next, cr.key, err)
+		}
+		cr.buf = chunk
+		cr.next++
+	}
+	n := copy(p, cr.buf)
+	cr.buf = cr.buf[n:]
+	cr.read += n
+	return n, nil
+}
+
+// Close -
+// Releases the current chunk
+func (cr *chunkReader) Close() error {
+	cr.closed = true
+	cr.buf = nil
+	return nil
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Source is the system of record behind a read-through / write-through cache.
+type Source interface {
+	// Load fetches the value of key from the source, called on cache misses.
+	Load(key string) ([]byte, error)
+	// Store saves the value of key to the source, called before the value is cached.
+	Store(key string, val []byte) error
+}
+
+// through reads misses from and writes values through to a Source.
+type through struct {
+	Wrapper
+	src   Source
+	loads singleflight.Group
+
+	mutex   sync.Mutex
+	loading map[string]*bool // key -> whether it was written during its load
+}
+
+// Through -
+// Wraps c so that Get loads misses from src and caches them, concurrent misses on a key share a
+// single Load, and Put stores the value to src before caching it. Delete only evicts the cached copy
+func Through(c Cache, src Source) Cache {
+	return &through{
+		Wrapper: Wrapper{Cache: c},
+		src:     src,
+		loading: map[string]*bool{},
+	}
+}
+
+// Put -
+// Stores the value to the source, then caches it
+func (t *through) Put(key string, val []byte) error {
+	t.written(key)
+	if err := t.src.Store(key, val); err != nil {
+		return err
+	}
+	return t.Cache.Put(key, val)
+}
+
+// Delete -
+// Evicts the cached copy of key
+func (t *through) Delete(key string) error {
+	t.written(key)
+	return t.Cache.Delete(key)
+}
+
+// Get -
+// Fetches the cached value, loading and caching it from the source on a miss. A loaded value is not
+// cached when key was written during the load, as it may be older than the written one
+func (t *through) Get(key string) ([]byte, error) {
+	if val, err := t.Cache.Get(key); err == nil {
+		return val, nil
+	}
+	v, err, _ := t.loads.Do(key, func() (any, error) {
+		dirty := false
+		t.mutex.Lock()
+		t.loading[key] = &dirty
+		t.mutex.Unlock()
+		val, err := t.src.Load(key)
+
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		delete(t.loading, key)
+		if err != nil {
+			return nil, err
+		}
+		if !dirty {
+			t.Cache.Put(key, val) // the loaded value is returned even if it could not be cached
+		}
+		return val, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+// written -
+// Marks the load of key in progress, if any, as outdated. Writes landing after a load finished
+// caching its value replace it
+func (t *through) written(key string) {
+	t.mutex.Lock()
+	if dirty, ok := t.loading[key]; ok {
+		*dirty = true
+	}
+	t.mutex.Unlock()
+}
@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Versioned hands out namespaces whose keys embed a generation number, flushing
+// a namespace bumps its generation so every existing key becomes unreachable in O(1).
+// Orphaned keys are left for the time window of the underlying cache to expire, while the
+// generation keys themselves are pinned on caches implementing Persist.
+type Versioned struct {
+	c Cache
+}
+
+// versionedNamespace scopes every key of the wrapped cache to the current generation of a namespace.
+type versionedNamespace struct {
+	Wrapper
+	v  *Versioned
+	ns string
+}
+
+// NewVersioned -
+// Initialises a new set of versioned namespaces on top of c, the generation of each
+// namespace is stored inside c itself so that it is shared between processes
+func NewVersioned(c Cache) *Versioned {
+	return &Versioned{
+		c: c,
+	}
+}
+
+// Namespace -
+// Returns a Cache whose keys are scoped to the current generation of ns
+func (v *Versioned) Namespace(ns string) Cache {
+	return &versionedNamespace{
+		Wrapper: Wrapper{Cache: v.c},
+		v:       v,
+		ns:      ns,
+	}
+}
+
+// FlushNamespace -
+// Logically flushes every key inside ns by starting a new generation
+func (v *Versioned) FlushNamespace(ns string) error {
+	_, err := v.bump(ns)
+	return err
+}
+
+// adder is implemented by caches which save a value only when its key is missing.
+type adder interface {
+	Add(key string, val []byte) error
+}
+
+// persister is implemented by caches which can keep an item beyond their time window.
+type persister interface {
+	Persist(key string) error
+}
+
+// version -
+// Returns the current generation of ns, starting a new one when none is stored yet. Concurrent
+// first readers race to create the generation with Add, the losers read back the winning one
+func (v *Versioned) version(ns string) (string, error) {
+	if val, err := v.c.Get(versionKey(ns)); err == nil && len(val) > 0 {
+		return string(val), nil
+	}
+	a, ok := v.c.(adder)
+	if !ok {
+		return v.bump(ns)
+	}
+	gen := newGeneration()
+	err := a.Add(versionKey(ns), []byte(gen))
+	if errors.Is(err, ErrKeyExists) {
+		val, err := v.c.Get(versionKey(ns))
+		if err != nil {
+			return "", err
+		}
+		return string(val), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return gen, v.pin(ns)
+}
+
+// bump -
+// Stores a new generation for ns, generations are derived from the clock so that an expired
+// generation key can never resurrect keys written under an earlier generation
+func (v *Versioned) bump(ns string) (string, error) {
+	gen := newGeneration()
+	if err := v.c.Put(versionKey(ns), []byte(gen)); err != nil {
+		return "", err
+	}
+	return gen, v.pin(ns)
+}
+
+// pin -
+// Keeps the generation key of ns beyond the time window when the cache supports it, otherwise
+// the namespace starts a new generation whenever its key expires
+func (v *Versioned) pin(ns string) error {
+	if p, ok := v.c.(persister); ok {
+		return p.Persist(versionKey(ns))
+	}
+	return nil
+}
+
+func newGeneration() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+func versionKey(ns string) string {
+	return ns + ":__version"
+}
+
+func (n *versionedNamespace) key(key string) (string, error) {
+	gen, err := n.v.version(n.ns)
+	if err != nil {
+		return "", err
+	}
+	return n.ns + ":" + gen + ":" + key, nil
+}
+
+// Put -
+// Saves the value under the key of the current generation
+func (n *versionedNamespace) Put(key string, val []byte) error {
+	k, err := n.key(key)
+	if err != nil {
+		return err
+	}
+	return n.Cache.Put(k, val)
+}
+
+// Get -
+// Fetches the value of the key inside the current generation
+func (n *versionedNamespace) Get(key string) ([]byte, error) {
+	k, err := n.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return n.Cache.Get(k)
+}
+
+// Delete -
+// Deletes the value of the key inside the current generation
+func (n *versionedNamespace) Delete(key string) error {
+	k, err := n.key(key)
+	if err != nil {
+		return err
+	}
+	return n.Cache.Delete(k)
+}
+
+// IsWarm -
+// Determines if the key inside the current generation holds a value
+func (n *versionedNamespace) IsWarm(key string) bool {
+	k, err := n.key(key)
+	if err != nil {
+		return false
+	}
+	return n.Cache.IsWarm(k)
+}
+
+// Flush -
+// Logically flushes the namespace by starting a new generation
+func (n *versionedNamespace) Flush() error {
+	return n.v.FlushNamespace(n.ns)
+}
+
+// Close -
+// Does nothing, the underlying cache may be shared with other namespaces and is closed by its owner
+func (n *versionedNamespace) Close() error {
+	return nil
+}
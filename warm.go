@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+const defaultWarmConcurrency = 8
+
+// warmConfig holds the settings of a Warm call
+type warmConfig struct {
+	concurrency int
+	progress    func(done, total int)
+}
+
+type WarmOption func(*warmConfig)
+
+// WarmConcurrency -
+// Functional option to specify how many keys are loaded at the same time, defaults to 8
+func WarmConcurrency(n int) WarmOption {
+	return func(w *warmConfig) {
+		if n > 0 {
+			w.concurrency = n
+		}
+	}
+}
+
+// WarmProgress -
+// Functional option to specify a callback invoked after every key, loaded or failed, with the
+// number of keys handled so far and the total, calls are serialised
+func WarmProgress(fn func(done, total int)) WarmOption {
+	return func(w *warmConfig) {
+		w.progress = fn
+	}
+}
+
+// Warm -
+// Pre-populates c by loading every key through loader with bounded parallelism, typically at deploy time
+// before taking traffic. Every key is attempted even when some fail, the first failure is returned.
+// Stops scheduling keys once ctx is done and returns its error
+func Warm(ctx context.Context, c Cache, keys []string, loader func(key string) ([]byte, error), opts ...WarmOption) error {
+	cfg := warmConfig{
+		concurrency: defaultWarmConcurrency,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var (
+		mutex    sync.Mutex
+		done     int
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	finish := func(err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		done++
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if cfg.progress != nil {
+			cfg.progress(done, len(keys))
+		}
+	}
+
+	sem := make(chan struct{}, cfg.concurrency)
+	for _, key := range keys {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func(key string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			val, err := loader(key)
+			if err != nil {
+				finish(fmt.Errorf("unable to load %s: %w", key, err))
+				return
+			}
+			finish(c.Put(key, val))
+		}(key)
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,13 @@
+package cache
+
+// Wrapper forwards every method of the Cache interface to the cache it wraps. Wrappers embed it
+// and only define the methods whose behaviour they change.
+type Wrapper struct {
+	Cache
+}
+
+// Unwrap -
+// Returns the wrapped cache, e.g. for lock.New to find the backend beneath a stack of wrappers
+func (w Wrapper) Unwrap() Cache {
+	return w.Cache
+}
@@ -0,0 +1,269 @@
+// Package writebehind acknowledges writes immediately and applies them to a slower cache in the
+// background, coalescing repeated writes of a key, for write heavy workloads on slow backends.
+package writebehind
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pedreviljoen/go-cache"
+)
+
+const (
+	defaultInterval = time.Second
+	defaultSize     = 1024
+)
+
+// ErrBufferFull is returned by writes rejected because the buffer is full, see Reject.
+var ErrBufferFull = errors.New("writebehind: buffer full")
+
+// Policy decides what happens to a write arriving while the buffer is full
+type Policy int
+
+const (
+	// FlushOnOverflow applies the buffered writes inside the writing call before buffering the new one.
+	FlushOnOverflow Policy = iota
+	// Reject fails the write with ErrBufferFull.
+	Reject
+)
+
+// mutation is a buffered write, the latest one per key
+type mutation struct {
+	op  cache.Op
+	val []byte
+}
+
+// Buffer is a cache.Cache buffering Put and Delete calls and applying them to the wrapped cache every
+// interval. Reads see buffered writes before they are applied
+type Buffer struct {
+	cache.Wrapper
+	interval time.Duration
+	size     int
+	overflow Policy
+	logger   cache.Logger
+
+	mutex    sync.Mutex
+	pending  map[string]mutation
+	inflight map[string]mutation // writes taken by Drain which have not landed yet
+
+	flushing sync.Mutex // serialises applying the buffered writes
+	once     sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type Option func(*Buffer)
+
+// New -
+// Wraps c and starts applying the buffered writes every interval in a separate go routine,
+// Close stops it after applying the remaining writes
+func New(c cache.Cache, opts ...Option) *Buffer {
+	b := &Buffer{
+		Wrapper:  cache.Wrapper{Cache: c},
+		interval: defaultInterval,
+		size:     defaultSize,
+		logger:   cache.NopLogger{},
+		pending:  map[string]mutation{},
+		inflight: map[string]mutation{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	go b.run()
+	return b
+}
+
+// Interval -
+// Functional option to specify how often the buffered writes are applied, defaults to a second
+func Interval(d time.Duration) Option {
+	return func(b *Buffer) {
+		if d > 0 {
+			b.interval = d
+		}
+	}
+}
+
+// Size -
+// Functional option to specify how many keys may have buffered writes, defaults to 1024
+func Size(n int) Option {
+	return func(b *Buffer) {
+		if n > 0 {
+			b.size = n
+		}
+	}
+}
+
+// Overflow -
+// Functional option to specify what happens to writes arriving while the buffer is full, defaults to FlushOnOverflow
+func Overflow(p Policy) Option {
+	return func(b *Buffer) {
+		b.overflow = p
+	}
+}
+
+// Logger -
+// Functional option to specify the logger receiving writes which could not be applied
+func Logger(l cache.Logger) Option {
+	return func(b *Buffer) {
+		b.logger = l
+	}
+}
+
+// run -
+// Applies the buffered writes every interval until the buffer is closed
+func (b *Buffer) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := b.Drain(); err != nil {
+				b.logger.Error("write-behind flush failed", "error", err)
+			}
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// buffer -
+// Buffers a write of key, applying the buffer first or rejecting the write when it is full
+func (b *Buffer) buffer(key string, m mutation) error {
+	for {
+		b.mutex.Lock()
+		if _, ok := b.pending[key]; ok || len(b.pending) < b.size {
+			b.pending[key] = m
+			b.mutex.Unlock()
+			return nil
+		}
+		b.mutex.Unlock()
+		if b.overflow == Reject {
+			return ErrBufferFull
+		}
+		if err := b.Drain(); err != nil {
+			return err
+		}
+	}
+}
+
+// Drain -
+// Applies every buffered write to the wrapped cache now, writes which fail are dropped and the
+// first failure is returned. Reads keep seeing each write until it has landed
+func (b *Buffer) Drain() error {
+	b.flushing.Lock()
+	defer b.flushing.Unlock()
+	b.mutex.Lock()
+	pending := b.pending
+	b.pending = make(map[string]mutation, len(pending))
+	b.inflight = pending
+	b.mutex.Unlock()
+	var first error
+	for key, m := range pending {
+		var err error
+		switch m.op {
+		case cache.OpPut:
+			err = b.Cache.Put(key, m.val)
+		case cache.OpDelete:
+			err = b.Cache.Delete(key)
+			if err != nil && !b.Cache.IsWarm(key) {
+				err = nil // already gone
+			}
+		}
+		b.mutex.Lock()
+		delete(b.inflight, key)
+		b.mutex.Unlock()
+		if err != nil {
+			b.logger.Error("write-behind write dropped", "op", m.op, "key", key, "error", err)
+			if first == nil {
+				first = fmt.Errorf("unable to apply %s of %s: %w", m.op, key, err)
+			}
+		}
+	}
+	return first
+}
+
+// buffered -
+// Returns the latest write of key which has not landed yet, buffered or being applied
+func (b *Buffer) buffered(key string) (mutation, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if m, ok := b.pending[key]; ok {
+		return m, true
+	}
+	m, ok := b.inflight[key]
+	return m, ok
+}
+
+// Pending -
+// Returns the number of keys with buffered writes
+func (b *Buffer) Pending() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.pending)
+}
+
+// Put -
+// Buffers the value, returning before it is applied to the wrapped cache
+func (b *Buffer) Put(key string, val []byte) error {
+	return b.buffer(key, mutation{op: cache.OpPut, val: val})
+}
+
+// Get -
+// Fetches the buffered value of key, or else the value of the wrapped cache
+func (b *Buffer) Get(key string) ([]byte, error) {
+	m, ok := b.buffered(key)
+	if !ok {
+		return b.Cache.Get(key)
+	}
+	if m.op == cache.OpDelete {
+		return nil, fmt.Errorf("unable to retrieve value from cache: %w", cache.ErrKeyNotFound)
+	}
+	return m.val, nil
+}
+
+// Delete -
+// Buffers the deletion of key, returning before it is applied to the wrapped cache
+func (b *Buffer) Delete(key string) error {
+	return b.buffer(key, mutation{op: cache.OpDelete})
+}
+
+// IsWarm -
+// Determines if key has a buffered value, or else if the wrapped cache holds one
+func (b *Buffer) IsWarm(key string) bool {
+	m, ok := b.buffered(key)
+	if !ok {
+		return b.Cache.IsWarm(key)
+	}
+	return m.op == cache.OpPut
+}
+
+// Flush -
+// Discards the buffered writes and empties the wrapped cache
+func (b *Buffer) Flush() error {
+	b.flushing.Lock()
+	defer b.flushing.Unlock()
+	b.mutex.Lock()
+	b.pending = map[string]mutation{}
+	b.mutex.Unlock()
+	return b.Cache.Flush()
+}
+
+// Close -
+// Stops the background flushing, applies the remaining writes and closes the wrapped cache
+func (b *Buffer) Close() error {
+	var err error
+	b.once.Do(func() {
+		close(b.stop)
+		<-b.done
+		err = b.Drain()
+		if cerr := b.Cache.Close(); err == nil {
+			err = cerr
+		}
+	})
+	return err
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// NoExpiry is the lifetime reported by GetWithTTL for items which never expire, such as pinned items.
+const NoExpiry time.Duration = -1
+
+// TTLReader is implemented by caches able to report the remaining lifetime of an item alongside its value.
+type TTLReader interface {
+	// GetWithTTL fetches the value of key along with the time left before it expires, zero for
+	// stale items and NoExpiry for items which never expire.
+	GetWithTTL(key string) ([]byte, time.Duration, error)
+}
+
+// XFetch implements probabilistic early expiration, "Optimal Probabilistic Cache Stampede Prevention"
+// by Vattani et al. Every item records how long it took to compute, and each read recomputes it early
+// with a probability rising as expiry approaches and with the compute cost, so popular items are
+// refreshed by a single caller ahead of time rather than by every caller at once after expiry.
+type XFetch struct {
+	c    Cache
+	beta float64
+}
+
+// NewXFetch -
+// Initialises probabilistic early expiration on top of c, beta scales how eagerly items are
+// recomputed ahead of expiry, 1 being the recommended default and larger values favouring
+// earlier refreshes. Without a TTLReader items are only computed on a miss
+func NewXFetch(c Cache, beta float64) *XFetch {
+	if beta <= 0 {
+		beta = 1
+	}
+	return &XFetch{
+		c:    c,
+		beta: beta,
+	}
+}
+
+// GetOrSet -
+// Fetches the value of key, calling load and storing its result along with its compute cost
+// on a miss or when the item is picked for early recomputation. Items must be written through
+// GetOrSet as the compute cost is stored in front of the value
+func (x *XFetch) GetOrSet(key string, load func() ([]byte, error)) ([]byte, error) {
+	if val, ok := x.get(key); ok {
+		return val, nil
+	}
+	start := time.Now()
+	val, err := load()
+	if err != nil {
+		return nil, err
+	}
+	delta := time.Since(start)
+	buf := make([]byte, 8+len(val))
+	binary.BigEndian.PutUint64(buf, uint64(delta))
+	copy(buf[8:], val)
+	if err := x.c.Put(key, buf); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// get -
+// Returns the stored value of key, reporting false on a miss, for stale items and when the item
+// should be recomputed early. Items which never expire are never recomputed early
+func (x *XFetch) get(key string) ([]byte, bool) {
+	var (
+		buf []byte
+		ttl = NoExpiry
+		err error
+	)
+	if r, ok := x.c.(TTLReader); ok {
+		buf, ttl, err = r.GetWithTTL(key)
+	} else {
+		buf, err = x.c.Get(key)
+	}
+	if err != nil || len(buf) < 8 || ttl == 0 {
+		return nil, false // missing or stale
+	}
+	delta := time.Duration(binary.BigEndian.Uint64(buf))
+	if ttl > 0 && float64(delta)*x.beta*-math.Log(1-rand.Float64()) >= float64(ttl) {
+		return nil, false
+	}
+	return buf[8:], true
+}